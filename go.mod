@@ -29,7 +29,6 @@ require (
 	github.com/gorilla/sessions v1.2.1 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.0 // indirect
-	github.com/hashicorp/golang-lru v0.5.1
 	github.com/hesahesa/pwdbro v0.0.0-20200103124734-0fb34fd61758
 	github.com/jawr/whois-parser-go v1.10.3
 	github.com/jaytaylor/html2text v0.0.0-20200412013138-3577fbdbcff7 // indirect
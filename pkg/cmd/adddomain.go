@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/jawr/whois-bi/pkg/internal/audit"
 	"github.com/jawr/whois-bi/pkg/internal/db"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/user"
@@ -34,7 +35,11 @@ func init() {
 				return errors.WithMessage(err, "Domain.Insert")
 			}
 
-			return nil
+			return recordAudit(dbConn, cmd, audit.Entry{
+				AccountID: usr.ID,
+				DomainID:  dom.ID,
+				Domain:    dom.Domain,
+			})
 		},
 	}
 
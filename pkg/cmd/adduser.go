@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/jawr/whois-bi/pkg/internal/audit"
 	"github.com/jawr/whois-bi/pkg/internal/db"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/pkg/errors"
@@ -31,7 +32,10 @@ func init() {
 				return errors.WithMessage(err, "User.Insert")
 			}
 
-			return nil
+			return recordAudit(dbConn, cmd, audit.Entry{
+				AccountID: u.ID,
+				Details:   map[string]interface{}{"email": u.Email},
+			})
 		},
 	}
 
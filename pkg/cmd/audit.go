@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	osuser "os/user"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/audit"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// recordAudit stores the change cmd made, the actor is the operating
+// system user running it
+func recordAudit(db orm.DB, cmd *cobra.Command, e audit.Entry) error {
+	e.Source = audit.SourceCLI
+	e.Action = cmd.CommandPath()
+	e.Actor = "unknown"
+	if u, err := osuser.Current(); err == nil {
+		e.Actor = u.Username
+	}
+
+	if err := e.Insert(db); err != nil {
+		return errors.Wrap(err, "audit.Insert")
+	}
+
+	return nil
+}
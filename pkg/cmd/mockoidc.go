@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jawr/whois-bi/pkg/internal/oidc"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var addr, issuer, clientID string
+
+	var mockoidcCmd = &cobra.Command{
+		Use:   "mockoidc",
+		Short: "Run a local OpenID Connect provider for testing SSO",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			provider, err := oidc.NewMockProvider(clientID)
+			if err != nil {
+				return errors.WithMessage(err, "NewMockProvider")
+			}
+
+			provider.Issuer = issuer
+			if len(provider.Issuer) == 0 {
+				provider.Issuer = "http://" + addr
+			}
+
+			fmt.Printf("OIDC_ISSUER=%s\nOIDC_CLIENT_ID=%s\n", provider.Issuer, clientID)
+
+			return http.ListenAndServe(addr, provider)
+		},
+	}
+
+	mockoidcCmd.Flags().StringVarP(&addr, "addr", "a", "127.0.0.1:9998", "address to listen on")
+	mockoidcCmd.Flags().StringVarP(&issuer, "issuer", "i", "", "issuer url, defaults to http://addr")
+	mockoidcCmd.Flags().StringVarP(&clientID, "client-id", "c", "whoisbi", "client id to accept")
+
+	rootCmd.AddCommand(mockoidcCmd)
+}
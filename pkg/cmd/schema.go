@@ -3,11 +3,16 @@ package cmd
 import (
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/audit"
 	"github.com/jawr/whois-bi/pkg/internal/db"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/emailer"
 	"github.com/jawr/whois-bi/pkg/internal/job"
 	"github.com/jawr/whois-bi/pkg/internal/list"
+	"github.com/jawr/whois-bi/pkg/internal/notify"
+	"github.com/jawr/whois-bi/pkg/internal/org"
 	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -36,13 +41,30 @@ func setupSchema(db *pg.DB) error {
 	models := []interface{}{
 		(*user.User)(nil),
 		(*user.Recover)(nil),
+		(*user.Token)(nil),
+		(*user.LoginAttempt)(nil),
 		(*domain.Domain)(nil),
+		(*domain.Group)(nil),
 		(*domain.Record)(nil),
+		(*domain.ExpectedRecord)(nil),
 		(*domain.Whois)(nil),
 		(*job.Job)(nil),
+		(*job.Progress)(nil),
 		(*list.List)(nil),
 		(*job.Alert)(nil),
 		(*job.ExpirationAlert)(nil),
+		(*job.Digest)(nil),
+		(*job.Incident)(nil),
+		(*webhook.Webhook)(nil),
+		(*webhook.Delivery)(nil),
+		(*notify.Channel)(nil),
+		(*notify.Rule)(nil),
+		(*notify.Sent)(nil),
+		(*emailer.Message)(nil),
+		(*org.Organisation)(nil),
+		(*org.Member)(nil),
+		(*org.Invitation)(nil),
+		(*audit.Entry)(nil),
 	}
 
 	for idx, model := range models {
@@ -56,5 +78,9 @@ func setupSchema(db *pg.DB) error {
 		}
 	}
 
+	if err := audit.Protect(db); err != nil {
+		return errors.WithMessage(err, "audit.Protect")
+	}
+
 	return nil
 }
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPassword is compared against when a login has no user so that it
+// takes as long as one that does
+var dummyPassword, _ = bcrypt.GenerateFromPassword([]byte(uniuri.New()), bcrypt.DefaultCost)
+
+// how many attempts handleGetLoginAttempts returns
+const attemptLimit = 100
+
+// recordAttempt stores the attempt, failing to do so does not fail the
+// request
+func (s Server) recordAttempt(c *gin.Context, kind user.AttemptKind, email string, success bool) {
+	if err := user.RecordAttempt(s.db, kind, email, c.ClientIP(), success); err != nil {
+		log.Printf("Unable to record %s attempt: %s", kind, err)
+	}
+}
+
+// tooManyAttempts responds with how long to wait before trying again
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(
+		http.StatusTooManyRequests,
+		gin.H{"error": "Too many attempts, please try again later."},
+	)
+}
+
+// throttleLogin responds and returns true if the email can not try to log
+// in from the request's ip yet
+func (s Server) throttleLogin(c *gin.Context, email string) bool {
+	wait, err := user.LoginDelay(s.db, email, c.ClientIP(), time.Now())
+	if err != nil {
+		log.Printf("LoginDelay error: %s", err)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Internal Server Error"},
+		)
+		return true
+	}
+
+	if wait > 0 {
+		log.Printf("Throttled login for %q from %s for %s", email, c.ClientIP(), wait)
+		tooManyAttempts(c, wait)
+		return true
+	}
+
+	return false
+}
+
+// handleGetLoginAttempts returns recent attempts to log in to or recover
+// the user's account
+func (s Server) handleGetLoginAttempts() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		attempts, err := user.GetAttempts(s.db, u.Email, attemptLimit)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetAttempts"))
+		}
+
+		c.JSON(http.StatusOK, &attempts)
+
+		return nil
+	}
+}
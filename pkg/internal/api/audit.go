@@ -0,0 +1,172 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/audit"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/org"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// context key for the id of the account a request acts on, set by
+// handleRole and for organisation changes
+const accountKey = "account_id"
+
+// context key for the domain a request changed
+const auditDomainKey = "audit_domain"
+
+// context key for details a handler adds to its audit entry
+const auditDetailsKey = "audit_details"
+
+// context key set by handlers that do not change anything even though
+// their method would
+const auditSkipKey = "audit_skip"
+
+// auditDomain records the domain the request changed
+func auditDomain(c *gin.Context, d domain.Domain) {
+	c.Set(auditDomainKey, d)
+}
+
+// auditDetail adds a detail to the request's audit entry
+func auditDetail(c *gin.Context, key string, value interface{}) {
+	details, ok := c.Get(auditDetailsKey)
+	if !ok {
+		details = make(map[string]interface{})
+		c.Set(auditDetailsKey, details)
+	}
+	details.(map[string]interface{})[key] = value
+}
+
+// auditSkip stops the request being recorded
+func auditSkip(c *gin.Context) {
+	c.Set(auditSkipKey, true)
+}
+
+// auditEntry returns an entry for the request made by the actor with any
+// domain and details the handler added
+func auditEntry(actor user.User, c *gin.Context) audit.Entry {
+	e := audit.Entry{
+		Source:         audit.SourceAPI,
+		ActorID:        actor.ID,
+		Actor:          actor.Email,
+		IP:             c.ClientIP(),
+		AccountID:      actor.ID,
+		OrganisationID: organisationID(c),
+		Action:         c.Request.Method + " " + c.FullPath(),
+		Details:        make(map[string]interface{}),
+	}
+
+	if id := c.GetInt(accountKey); id > 0 {
+		e.AccountID = id
+	}
+
+	if t, ok := requestToken(c); ok {
+		e.TokenID = t.ID
+	}
+
+	if d, ok := c.Get(auditDomainKey); ok {
+		if d, ok := d.(domain.Domain); ok {
+			e.DomainID = d.ID
+			e.Domain = d.Domain
+		}
+	}
+
+	for _, p := range c.Params {
+		// codes in the path are secrets
+		if p.Key == "code" {
+			continue
+		}
+		e.Details[p.Key] = p.Value
+	}
+
+	if details, ok := c.Get(auditDetailsKey); ok {
+		for k, v := range details.(map[string]interface{}) {
+			e.Details[k] = v
+		}
+	}
+
+	return e
+}
+
+// audit records a change the logged in user made, reads, failed requests
+// and those that called auditSkip are ignored
+func (s Server) audit(actor user.User, c *gin.Context) {
+	if methodRole(c.Request.Method) == org.RoleViewer || c.GetBool(auditSkipKey) || c.Writer.Status() >= http.StatusBadRequest {
+		return
+	}
+
+	s.recordAudit(auditEntry(actor, c))
+}
+
+// recordAudit stores the entry, failing to do so does not fail the request
+func (s Server) recordAudit(e audit.Entry) {
+	if err := e.Insert(s.db); err != nil {
+		log.Printf("Unable to record audit entry %q for %d: %s", e.Action, e.AccountID, err)
+	}
+}
+
+// handleGetAudit returns changes made to the account the request acts on,
+// filtered by the user that made them, domain and time range
+func (s Server) handleGetAudit() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		filter := audit.Filter{
+			AccountID:      u.ID,
+			OrganisationID: organisationID(c),
+			Domain:         strings.ToLower(strings.TrimSpace(c.Query("domain"))),
+		}
+
+		if v := c.Query("user"); len(v) > 0 {
+			if id, err := strconv.Atoi(v); err == nil {
+				filter.ActorID = id
+			} else {
+				filter.Actor = strings.TrimSpace(v)
+			}
+		}
+
+		for key, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			v := c.Query(key)
+			if len(v) == 0 {
+				continue
+			}
+
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return newApiError(http.StatusBadRequest, "Invalid "+key, errors.Wrap(err, "Parse"))
+			}
+			*t = parsed
+		}
+
+		for key, n := range map[string]*int{"before": &filter.Before, "limit": &filter.Limit} {
+			v := c.Query(key)
+			if len(v) == 0 {
+				continue
+			}
+
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return newApiError(http.StatusBadRequest, "Invalid "+key, errors.Wrap(err, "Atoi"))
+			}
+			*n = parsed
+		}
+
+		if err := filter.Normalise(); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Normalise"))
+		}
+
+		entries, err := audit.Find(s.db, filter)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "Find"))
+		}
+
+		c.JSON(http.StatusOK, &entries)
+
+		return nil
+	}
+}
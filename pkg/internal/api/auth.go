@@ -1,19 +1,29 @@
 package api
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/emailer"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	SessionUserKey string = "monere-user-id"
+
+	// a login waiting for a two factor code
+	SessionPendingUserKey     string = "monere-pending-user-id"
+	SessionPendingAtKey       string = "monere-pending-at"
+	SessionPendingAttemptsKey string = "monere-pending-attempts"
+
+	// an SSO login waiting for the provider to redirect back
+	SessionOIDCStateKey    string = "monere-oidc-state"
+	SessionOIDCNonceKey    string = "monere-oidc-nonce"
+	SessionOIDCVerifierKey string = "monere-oidc-verifier"
 )
 
 func (s Server) handleGetStatus() gin.HandlerFunc {
@@ -51,6 +61,8 @@ func (s Server) handlePostRegister() gin.HandlerFunc {
 	type Request struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		// optional, used for emails
+		Locale string `json:"locale"`
 	}
 
 	return func(c *gin.Context) {
@@ -74,6 +86,10 @@ func (s Server) handlePostRegister() gin.HandlerFunc {
 			return
 		}
 
+		if emailer.ValidLocale(request.Locale) {
+			u.Locale = request.Locale
+		}
+
 		if err := u.Insert(s.db); err != nil {
 			log.Println(err)
 			c.JSON(
@@ -83,13 +99,11 @@ func (s Server) handlePostRegister() gin.HandlerFunc {
 			return
 		}
 
-		body := fmt.Sprintf(
-			`Thank you for registering with us. Please complete your registration by clicking <a href="https://%s/verify/%s">here<a/>`,
-			os.Getenv("DOMAIN"),
-			u.VerifiedCode,
-		)
+		s.recordAudit(auditEntry(u, c))
+
+		data := struct{ Code string }{u.VerifiedCode}
 
-		if err := s.emailer.Send(u.Email, "Please verify your account", body); err != nil {
+		if err := s.emailer.Queue(s.db, "verify:"+u.VerifiedCode, u.Email, u.Locale, "verify", data); err != nil {
 			log.Println(err)
 			c.JSON(
 				http.StatusInternalServerError,
@@ -119,10 +133,17 @@ func (s Server) handlePostLogin() gin.HandlerFunc {
 			return
 		}
 
+		if s.throttleLogin(c, request.Email) {
+			return
+		}
+
 		// validate user
 		var u user.User
 
-		if err := s.db.Model(&u).Where("email = ? AND verified_at IS NOT NULL", request.Email).Select(); err != nil {
+		if err := s.db.Model(&u).Where("lower(email) = ? AND verified_at IS NOT NULL", user.NormaliseEmail(request.Email)).Select(); err != nil {
+			// take as long as a real user would
+			bcrypt.CompareHashAndPassword(dummyPassword, []byte(request.Password))
+			s.recordAttempt(c, user.AttemptLogin, request.Email, false)
 			c.JSON(
 				http.StatusUnauthorized,
 				gin.H{"error": "Email or Password is invalid."},
@@ -132,6 +153,7 @@ func (s Server) handlePostLogin() gin.HandlerFunc {
 
 		// validate password
 		if err := bcrypt.CompareHashAndPassword(u.Password, []byte(request.Password)); err != nil {
+			s.recordAttempt(c, user.AttemptLogin, request.Email, false)
 			c.JSON(
 				http.StatusUnauthorized,
 				gin.H{"error": "Email or Password is invalid."},
@@ -139,19 +161,123 @@ func (s Server) handlePostLogin() gin.HandlerFunc {
 			return
 		}
 
-		// successful login! run database updates
-		go func(u user.User) {
-			// can handle ip logging if we want
-			_, err := s.db.Model(&u).Set("last_login_at = now()").WherePK().Update()
-			if err != nil {
-				panic(err)
+		if u.TwoFactorEnabled() {
+			if err := startTwoFactor(u, c); err != nil {
+				c.JSON(
+					http.StatusInternalServerError,
+					gin.H{"error": "Failed to save session"},
+				)
+				return
 			}
-		}(u)
 
-		// set and save session
+			c.JSON(http.StatusOK, gin.H{"status": "Two factor code required", "two_factor": true})
+			return
+		}
+
+		s.recordAttempt(c, user.AttemptLogin, u.Email, true)
+
+		if err := s.login(u, c); err != nil {
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "Failed to save session"},
+			)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "Logged in"})
+	}
+}
+
+// startTwoFactor saves a login that is not complete until
+// handlePostLoginTwoFactor
+func startTwoFactor(u user.User, c *gin.Context) error {
+	session := sessions.Default(c)
+	session.Delete(SessionUserKey)
+	session.Set(SessionPendingUserKey, u.ID)
+	session.Set(SessionPendingAtKey, time.Now().Unix())
+	session.Set(SessionPendingAttemptsKey, 0)
+	return session.Save()
+}
+
+// handlePostLoginTwoFactor completes a login started by handlePostLogin
+// with a TOTP or recovery code
+func (s Server) handlePostLoginTwoFactor() gin.HandlerFunc {
+	type Request struct {
+		Code string `json:"code"`
+	}
+
+	return func(c *gin.Context) {
+		var request Request
+
+		if c.ShouldBind(&request) != nil {
+			c.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": "Missing Code"},
+			)
+			return
+		}
+
 		session := sessions.Default(c)
-		session.Set(SessionUserKey, u.ID)
-		if err := session.Save(); err != nil {
+
+		userID, _ := session.Get(SessionPendingUserKey).(int)
+		at, _ := session.Get(SessionPendingAtKey).(int64)
+		attempts, _ := session.Get(SessionPendingAttemptsKey).(int)
+
+		if userID == 0 || time.Since(time.Unix(at, 0)) > pendingLoginTTL || attempts >= maxTwoFactorAttempts {
+			clearPendingLogin(session)
+			c.JSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "Login expired, please login again."},
+			)
+			return
+		}
+
+		var u user.User
+		if err := s.db.Model(&u).Where("id = ? AND verified_at IS NOT NULL", userID).Select(); err != nil {
+			clearPendingLogin(session)
+			c.JSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "Login expired, please login again."},
+			)
+			return
+		}
+
+		// the session's attempts can be reset by replaying an old cookie,
+		// the account's can not
+		if s.throttleLogin(c, u.Email) {
+			return
+		}
+
+		// the code is stopped from being used again, concurrent requests
+		// with the same code only succeed once
+		ok, err := u.UseTwoFactor(s.db, request.Code, time.Now())
+		if err != nil {
+			log.Printf("Unable to update two factor for %d: %s", u.ID, err)
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "Failed to save session"},
+			)
+			return
+		}
+
+		if !ok {
+			s.recordAttempt(c, user.AttemptTwoFactor, u.Email, false)
+			session.Set(SessionPendingAttemptsKey, attempts+1)
+			if err := session.Save(); err != nil {
+				log.Printf("Unable to save session: %s", err)
+			}
+			c.JSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "Code is invalid."},
+			)
+			return
+		}
+
+		clearPendingLogin(session)
+
+		s.recordAttempt(c, user.AttemptLogin, u.Email, true)
+
+		if err := s.login(u, c); err != nil {
 			c.JSON(
 				http.StatusInternalServerError,
 				gin.H{"error": "Failed to save session"},
@@ -163,6 +289,35 @@ func (s Server) handlePostLogin() gin.HandlerFunc {
 	}
 }
 
+// how long the second step of a login can take
+const pendingLoginTTL = time.Minute * 5
+
+// codes that can be tried before the login has to start again
+const maxTwoFactorAttempts = 5
+
+func clearPendingLogin(session sessions.Session) {
+	session.Delete(SessionPendingUserKey)
+	session.Delete(SessionPendingAtKey)
+	session.Delete(SessionPendingAttemptsKey)
+}
+
+// login saves the session for the user
+func (s Server) login(u user.User, c *gin.Context) error {
+	// successful login! run database updates
+	go func(u user.User) {
+		// can handle ip logging if we want
+		_, err := s.db.Model(&u).Set("last_login_at = now()").WherePK().Update()
+		if err != nil {
+			panic(err)
+		}
+	}(u)
+
+	// set and save session
+	session := sessions.Default(c)
+	session.Set(SessionUserKey, u.ID)
+	return session.Save()
+}
+
 func (s Server) handlePostVerify() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if err := user.VerifyUser(s.db, c.Param("code")); err != nil {
@@ -173,6 +328,11 @@ func (s Server) handlePostVerify() gin.HandlerFunc {
 			)
 			return
 		}
+
+		var u user.User
+		if err := s.db.Model(&u).Where("verified_code = ?", c.Param("code")).Select(); err == nil {
+			s.recordAudit(auditEntry(u, c))
+		}
 		c.JSON(http.StatusOK, gin.H{"status": "Verified. Please login."})
 	}
 }
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/notify"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetChannels() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		channels, err := notify.GetChannels(s.db, u.ID, organisationID(c))
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetChannels"))
+		}
+
+		c.JSON(http.StatusOK, &channels)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostChannel() HandlerFunc {
+	type Request struct {
+		Name   string `json:"name"`
+		Kind   string `json:"kind"`
+		Target string `json:"target"`
+		// optional, limits the channel to a single domain
+		Domain string `json:"domain"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		channel := notify.Channel{
+			OwnerID:        u.ID,
+			OrganisationID: organisationID(c),
+			Name:           request.Name,
+			Kind:           request.Kind,
+			Target:         request.Target,
+		}
+
+		if err := channel.Validate(); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Validate"))
+		}
+
+		if len(request.Domain) > 0 {
+			var d domain.Domain
+			err := s.db.Model(&d).Where("domain = ? AND owner_id = ? AND organisation_id = ?", request.Domain, u.ID, organisationID(c)).Select()
+			if err != nil {
+				return newApiError(http.StatusNotFound, "Domain not found", errors.Wrap(err, "Select Domain"))
+			}
+			channel.DomainID = d.ID
+		}
+
+		if _, err := s.db.Model(&channel).Returning("*").Insert(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Inserting", errors.Wrap(err, "Insert"))
+		}
+
+		c.JSON(http.StatusCreated, &channel)
+
+		return nil
+	}
+}
+
+func (s Server) handleDeleteChannel() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		_, err = s.db.Model((*notify.Channel)(nil)).
+			Where("id = ? AND owner_id = ? AND organisation_id = ?", id, u.ID, organisationID(c)).
+			Delete()
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/job"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetDigests() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		digests, err := job.GetDigests(s.db, u.ID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetDigests"))
+		}
+
+		c.JSON(http.StatusOK, &digests)
+
+		return nil
+	}
+}
+
+func (s Server) handleGetDigest() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		digest, err := job.GetDigest(s.db, u.ID, id)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetDigest"))
+		}
+
+		c.JSON(http.StatusOK, &digest)
+
+		return nil
+	}
+}
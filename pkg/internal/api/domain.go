@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/org"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
@@ -16,13 +17,19 @@ func (s Server) handleGetDomains() HandlerFunc {
 	return func(u user.User, c *gin.Context) error {
 		domains := make([]domain.DisplayDomain, 0)
 
-		err := s.db.Model(&domains).
+		query := s.db.Model(&domains).
 			ColumnExpr("domain.*, max(whois.created_date) as created_at, max(whois.expiration_date) as expires_at, coalesce(count(distinct record.id), 0) as records, coalesce(count(distinct whois.id), 0) as whois").
 			Join("left join records as record on domain.id = record.domain_id left join whois on domain.id = whois.domain_id").
-			Where("domain.owner_id = ?", u.ID).
+			Where("domain.owner_id = ? AND domain.organisation_id = ?", u.ID, organisationID(c)).
 			Group("domain.id").
-			Order("domain.domain").
-			Select()
+			Order("domain.domain")
+
+		// only domains in the group
+		if tag := c.Query("tag"); len(tag) > 0 {
+			query = query.Where("domain.tags @> ?::jsonb", []string{tag})
+		}
+
+		err := query.Select()
 		if err != nil {
 			return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "Select"))
 		}
@@ -35,15 +42,24 @@ func (s Server) handleGetDomains() HandlerFunc {
 
 type DomainHandlerFunc func(domain.Domain, user.User, *gin.Context) error
 
+// handleDomain passes the domain named in the path to fn if it belongs to
+// the account the request acts on, see handleUser
 func (s Server) handleDomain(fn DomainHandlerFunc) gin.HandlerFunc {
-	return s.handleUser(func(u user.User, c *gin.Context) error {
+	return s.handleDomainRole("", fn)
+}
+
+// handleDomainRole is handleDomain requiring at least role in an
+// organisation, see handleRole
+func (s Server) handleDomainRole(role org.Role, fn DomainHandlerFunc) gin.HandlerFunc {
+	return s.handleRole(role, func(u user.User, c *gin.Context) error {
 		var d domain.Domain
 
 		err := s.db.Model(&d).
 			Where(
-				"domain = ? AND owner_id = ?",
+				"domain = ? AND owner_id = ? AND organisation_id = ?",
 				c.Param("domain"),
 				u.ID,
+				organisationID(c),
 			).
 			Select()
 		if err != nil {
@@ -54,6 +70,8 @@ func (s Server) handleDomain(fn DomainHandlerFunc) gin.HandlerFunc {
 			return newApiError(http.StatusNotFound, "Not found", errors.New("Not allowed"))
 		}
 
+		auditDomain(c, d)
+
 		return fn(d, u, c)
 	})
 }
@@ -103,6 +121,7 @@ func (s Server) handlePostDomain() HandlerFunc {
 		}
 
 		d := domain.NewDomain(request.Domain, u)
+		d.OrganisationID = organisationID(c)
 
 		if len(d.Domain) == 0 || !strings.Contains(d.Domain, ".") {
 			return newApiError(
@@ -112,15 +131,24 @@ func (s Server) handlePostDomain() HandlerFunc {
 			)
 		}
 
-		_, err := s.db.Model(&d).
+		// a deleted domain is restored in to the organisation it is added
+		// to, a domain that has not been deleted is left where it is
+		res, err := s.db.Model(&d).
 			OnConflict("(domain, owner_id) DO UPDATE").
-			Set("deleted_at = NULL").
+			Set("deleted_at = NULL, organisation_id = EXCLUDED.organisation_id, next_scan_at = NULL").
+			Where("domain.deleted_at IS NOT NULL").
 			Returning("*").
 			Insert()
 		if err != nil {
 			return newApiError(http.StatusInternalServerError, "Inserting Domain", errors.Wrap(err, "Insert"))
 		}
 
+		if res.RowsAffected() == 0 {
+			return newApiError(http.StatusConflict, "Domain already added", errors.Errorf("Domain exists: '%s'", d.Domain))
+		}
+
+		auditDomain(c, d)
+
 		dd := domain.DisplayDomain{Domain: d}
 		c.JSON(http.StatusCreated, &dd)
 
@@ -207,6 +235,82 @@ func (s Server) handlePutDomainBatch() DomainHandlerFunc {
 			return newApiError(http.StatusBadRequest, "Internal Server Error", errors.Wrap(err, "Update"))
 		}
 
+		auditDetail(c, "dont_batch", request.DontBatch)
+
+		c.JSON(http.StatusOK, &d)
+
+		return nil
+	}
+}
+
+func (s Server) handlePutDomainTags() DomainHandlerFunc {
+	type Request struct {
+		Tags []string `json:"tags"`
+	}
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		tags, err := domain.NormaliseTags(request.Tags)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NormaliseTags"))
+		}
+
+		d.Tags = tags
+
+		if _, err := s.db.Model(&d).Set("tags = ?tags, next_scan_at = NULL").WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		auditDetail(c, "tags", d.Tags)
+
+		c.JSON(http.StatusOK, &d)
+
+		return nil
+	}
+}
+
+func (s Server) handlePutDomainSchedule() DomainHandlerFunc {
+	type Request struct {
+		ScanSchedule  string `json:"scan_schedule"`
+		WhoisSchedule string `json:"whois_schedule"`
+	}
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		limits := u.Limits()
+
+		err := domain.ValidateSchedules(
+			request.ScanSchedule,
+			request.WhoisSchedule,
+			limits.MinScanInterval,
+			limits.MinWhoisInterval,
+		)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.Wrap(err, "ValidateSchedules"))
+		}
+
+		d.ScanSchedule = request.ScanSchedule
+		d.WhoisSchedule = request.WhoisSchedule
+
+		_, err = s.db.Model(&d).
+			Set("scan_schedule = ?scan_schedule, whois_schedule = ?whois_schedule, next_scan_at = NULL").
+			WherePK().
+			Update()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		auditDetail(c, "scan_schedule", d.ScanSchedule)
+		auditDetail(c, "whois_schedule", d.WhoisSchedule)
+
 		c.JSON(http.StatusOK, &d)
 
 		return nil
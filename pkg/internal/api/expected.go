@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetExpectedRecords() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		expected, err := d.GetExpectedRecords(s.db)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "GetExpectedRecords"))
+		}
+
+		if expected == nil {
+			expected = make(domain.ExpectedRecords, 0)
+		}
+
+		c.JSON(http.StatusOK, &expected)
+
+		return nil
+	}
+}
+
+func (s Server) handleGetDomainDrift() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		expected, err := d.GetExpectedRecords(s.db)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "GetExpectedRecords"))
+		}
+
+		current, err := d.GetRecords(s.db)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "GetRecords"))
+		}
+
+		drift := domain.CompareExpected(expected, current)
+
+		c.JSON(http.StatusOK, &drift)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostExpectedRecord() DomainHandlerFunc {
+	type Request struct {
+		Raw       string `json:"raw"`
+		Exclusive bool   `json:"exclusive"`
+	}
+
+	type Response struct {
+		Records []domain.ExpectedRecord `json:"records"`
+		Errors  []string                `json:"errors"`
+	}
+
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		var request Request
+		if c.ShouldBind(&request) != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.New("ShouldBind"))
+		}
+
+		zp := dns.NewZoneParser(strings.NewReader(request.Raw), d.Domain, "")
+
+		response := Response{
+			Records: make([]domain.ExpectedRecord, 0),
+			Errors:  make([]string, 0),
+		}
+
+		for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+			expected := domain.NewExpectedRecord(d, rr, request.Exclusive)
+
+			if err := expected.Insert(s.db); err != nil {
+				response.Errors = append(response.Errors, fmt.Sprintf("Unable to add '%s'", expected.Raw))
+				continue
+			}
+
+			response.Records = append(response.Records, expected)
+		}
+
+		if err := zp.Err(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Unable to parse record(s)", errors.Wrap(err, "zoneParser Err"))
+		}
+
+		c.JSON(http.StatusCreated, &response)
+
+		return nil
+	}
+}
+
+func (s Server) handleDeleteExpectedRecord() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		_, err = s.db.Model((*domain.ExpectedRecord)(nil)).
+			Where("id = ? AND domain_id = ?", id, d.ID).
+			Delete()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Unable to delete expected record", errors.Wrap(err, "Delete"))
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetGroups() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		groups, err := domain.GetGroups(s.db, u.ID, organisationID(c))
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetGroups"))
+		}
+
+		c.JSON(http.StatusOK, &groups)
+
+		return nil
+	}
+}
+
+// handlePutGroup creates or updates the settings shared by the domains
+// tagged with the group
+func (s Server) handlePutGroup() HandlerFunc {
+	type Request struct {
+		ScanSchedule  string `json:"scan_schedule"`
+		WhoisSchedule string `json:"whois_schedule"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		tag := c.Param("tag")
+		if !domain.ValidTag(tag) {
+			return newApiError(http.StatusBadRequest, "Invalid group", errors.Errorf("invalid tag %q", tag))
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		limits := u.Limits()
+
+		err := domain.ValidateSchedules(
+			request.ScanSchedule,
+			request.WhoisSchedule,
+			limits.MinScanInterval,
+			limits.MinWhoisInterval,
+		)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.Wrap(err, "ValidateSchedules"))
+		}
+
+		group := domain.Group{
+			OwnerID:        u.ID,
+			OrganisationID: organisationID(c),
+			Tag:            tag,
+			ScanSchedule:   request.ScanSchedule,
+			WhoisSchedule:  request.WhoisSchedule,
+		}
+
+		// the group's domains work out their next scan with the new
+		// schedules
+		err = s.db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+			_, err := tx.Model(&group).
+				OnConflict("(owner_id, organisation_id, tag) DO UPDATE").
+				Set("scan_schedule = EXCLUDED.scan_schedule, whois_schedule = EXCLUDED.whois_schedule").
+				Returning("*").
+				Insert()
+			if err != nil {
+				return errors.Wrap(err, "Insert")
+			}
+
+			return errors.WithMessage(domain.ResetNextScan(tx, u.ID, group.OrganisationID, tag), "ResetNextScan")
+		})
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", err)
+		}
+
+		c.JSON(http.StatusOK, &group)
+
+		return nil
+	}
+}
+
+// handleDeleteGroup removes the group's settings, domains keep their tags
+func (s Server) handleDeleteGroup() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		err := s.db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+			_, err := tx.Model((*domain.Group)(nil)).
+				Where("owner_id = ? AND organisation_id = ? AND tag = ?", u.ID, organisationID(c), c.Param("tag")).
+				Delete()
+			if err != nil {
+				return errors.Wrap(err, "Delete")
+			}
+
+			return errors.WithMessage(domain.ResetNextScan(tx, u.ID, organisationID(c), c.Param("tag")), "ResetNextScan")
+		})
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
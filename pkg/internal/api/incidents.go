@@ -0,0 +1,115 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/job"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetIncidents() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		state := c.Query("state")
+		if len(state) > 0 && !job.ValidIncidentState(state) {
+			return newApiError(http.StatusBadRequest, "Unknown state", errors.Errorf("unknown state %q", state))
+		}
+
+		incidents, err := job.GetIncidents(s.db, u.ID, organisationID(c), state, c.Query("domain"))
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetIncidents"))
+		}
+
+		c.JSON(http.StatusOK, &incidents)
+
+		return nil
+	}
+}
+
+func (s Server) handlePutIncident() HandlerFunc {
+	type Request struct {
+		State job.IncidentState `json:"state"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		incident, err := job.GetIncident(s.db, u.ID, organisationID(c), id)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetIncident"))
+		}
+
+		if err := incident.Transition(request.State, sessionUser(c).Email, time.Now()); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Transition"))
+		}
+
+		if err := incident.Update(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, &incident)
+
+		return nil
+	}
+}
+
+// handlePostAcknowledge acknowledges an incident using the token from a
+// one click link in an alert, it does not need a session
+func (s Server) handlePostAcknowledge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		incident, err := job.GetIncidentByToken(s.db, c.Param("token"))
+		if err != nil {
+			log.Printf("Acknowledge error: %s", err)
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"error": "Unknown or expired link"},
+			)
+			return
+		}
+
+		// only open incidents are acknowledged, following an old link
+		// should not undo a later change
+		if incident.State == job.IncidentOpen {
+			if err := incident.Transition(job.IncidentAcknowledged, incident.Owner.Email, time.Now()); err == nil {
+				err = incident.Update(s.db)
+			}
+
+			if err != nil {
+				log.Printf("Acknowledge error: %s", err)
+				c.JSON(
+					http.StatusInternalServerError,
+					gin.H{"error": "Unable to acknowledge"},
+				)
+				return
+			}
+
+			c.Set(organisationKey, incident.Domain.OrganisationID)
+			auditDomain(c, incident.Domain)
+
+			e := auditEntry(incident.Owner, c)
+			// the link's token is a secret
+			delete(e.Details, "token")
+			e.Details["incident"] = incident.ID
+			s.recordAudit(e)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "Acknowledged.",
+			"domain": incident.Domain.Domain,
+			"state":  incident.State,
+		})
+	}
+}
@@ -1,22 +1,26 @@
 package api
 
 import (
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/job"
+	"github.com/jawr/whois-bi/pkg/internal/list"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/pkg/errors"
 )
 
-func (s Server) handleGetJobs() HandlerFunc {
-	return func(u user.User, c *gin.Context) error {
+func (s Server) handleGetJobs() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
 		jobs := make([]job.Job, 0)
 
 		err := s.db.Model(&jobs).
 			Relation("Domain").
-			Where("domain.owner_id = ? AND domain.domain = ?", u.ID, c.Param("domain")).
+			Where("job.domain_id = ?", d.ID).
 			Order("job.created_at DESC").
 			Select()
 		if err != nil {
@@ -29,22 +33,198 @@ func (s Server) handleGetJobs() HandlerFunc {
 	}
 }
 
-func (s Server) handlePostJob() HandlerFunc {
-	return func(u user.User, c *gin.Context) error {
-		var d domain.Domain
-
-		err := s.db.Model(&d).Where("domain = ? AND owner_id = ?", c.Param("domain"), u.ID).Select()
-		if err != nil {
-			return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "Select"))
-		}
-
+func (s Server) handlePostJob() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
 		j := job.NewJob(d)
+		j.Priority = job.PriorityHigh
 		if err := j.Insert(s.db); err != nil {
 			return newApiError(http.StatusInternalServerError, "Job already queued", errors.Wrap(err, "Insert"))
 		}
 
+		// dispatch now rather than waiting for the manager, if this fails
+		// the manager will pick it up
+		if err := job.Dispatch(c.Request.Context(), s.db, s.publisher, j); err != nil {
+			log.Printf("Unable to dispatch job %d: %s", j.ID, err)
+		}
+
 		c.JSON(http.StatusOK, &j)
 
 		return nil
 	}
 }
+
+func (s Server) handleGetJobProgress() DomainHandlerFunc {
+	const pollInterval = time.Second
+
+	// polls to wait for the worker's finished progress once the job has
+	// finished, it is published after the worker's response so can be
+	// stored after the job is marked as succeeded
+	const finishedGrace = 5
+
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		j, err := s.jobForDomain(d, c)
+		if err != nil {
+			return err
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		var lastID int
+		var finished bool
+		grace := finishedGrace
+
+		// a job that finished a while ago has all of its progress stored
+		if j.Finished() && time.Since(j.FinishedAt) > time.Minute {
+			grace = 0
+		}
+
+		for {
+			progress, err := job.GetProgress(s.db, j.ID, lastID)
+			if err != nil {
+				return streamError(c, j.ID, errors.Wrap(err, "GetProgress"))
+			}
+
+			for _, p := range progress {
+				c.SSEvent("progress", &p)
+				lastID = p.ID
+				if p.Stage == job.ProgressFinished {
+					finished = true
+				}
+			}
+
+			// progress is read before the job so a job seen as finished
+			// is polled once more before done is sent
+			if j.Finished() && (finished || j.Status == job.StatusDead || grace == 0) {
+				c.SSEvent("done", &j)
+				c.Writer.Flush()
+				return nil
+			}
+
+			if j.Finished() {
+				grace--
+			}
+
+			if err := s.db.Model(&j).WherePK().Select(); err != nil {
+				return streamError(c, j.ID, errors.Wrap(err, "Select"))
+			}
+
+			// failed and waiting for a retry, which may be a while
+			if j.Status == job.StatusQueued && j.Attempts > 0 {
+				c.SSEvent("retry", &j)
+				c.Writer.Flush()
+				return nil
+			}
+
+			c.Writer.Flush()
+
+			select {
+			case <-c.Request.Context().Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// streamError ends a progress stream with an error event, once the stream
+// has started the error can not be returned as json
+func streamError(c *gin.Context, jobID int, err error) error {
+	log.Printf("Error streaming progress for job %d: %s", jobID, err)
+
+	c.SSEvent("error", gin.H{"error": "Internal Server Error"})
+	c.Writer.Flush()
+
+	return nil
+}
+
+// jobForDomain returns the domain's job from the id param
+func (s Server) jobForDomain(d domain.Domain, c *gin.Context) (job.Job, error) {
+	var j job.Job
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return j, newApiError(http.StatusBadRequest, "Bad Request", err)
+	}
+
+	if err := s.db.Model(&j).Where("id = ? AND domain_id = ?", id, d.ID).Select(); err != nil {
+		return j, newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "Select"))
+	}
+
+	return j, nil
+}
+
+// handleGetJobLists returns how the job's changes were evaluated against
+// the user's lists at the time
+func (s Server) handleGetJobLists() DomainHandlerFunc {
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		j, err := s.jobForDomain(d, c)
+		if err != nil {
+			return err
+		}
+
+		trace := j.ListTrace
+		if trace == nil {
+			trace = make([]list.Evaluation, 0)
+		}
+
+		c.JSON(http.StatusOK, &trace)
+
+		return nil
+	}
+}
+
+// handlePostJobLists evaluates the job's changes against a candidate rule
+// set, or the user's current lists if none are given, without changing
+// anything
+func (s Server) handlePostJobLists() DomainHandlerFunc {
+	type request struct {
+		Lists []list.List `json:"lists"`
+	}
+
+	return func(d domain.Domain, u user.User, c *gin.Context) error {
+		// nothing is saved
+		auditSkip(c)
+
+		j, err := s.jobForDomain(d, c)
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := c.ShouldBind(&req); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		lists := req.Lists
+		if len(lists) == 0 {
+			lists, err = list.GetLists(s.db, u.ID, d.OrganisationID)
+			if err != nil {
+				return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetLists"))
+			}
+		}
+
+		for idx := range lists {
+			if err := lists[idx].Validate(); err != nil {
+				return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessagef(err, "Validate list %d", idx))
+			}
+		}
+
+		list.Sort(lists)
+
+		// jobs are only traced once they have been evaluated against lists
+		trace := make([]list.Evaluation, 0, len(j.ListTrace))
+		for _, e := range j.ListTrace {
+			r := e.Record
+			r.Domain = d
+			trace = append(trace, list.Evaluate(lists, &r, e.Removed))
+		}
+
+		c.JSON(http.StatusOK, &trace)
+
+		return nil
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/job"
 	"github.com/jawr/whois-bi/pkg/internal/list"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/pkg/errors"
@@ -15,25 +17,37 @@ func (s *Server) handleGetMatches() HandlerFunc {
 	type response struct {
 		Whitelists []list.List `json:"whitelists"`
 		Blacklists []list.List `json:"blacklists"`
+		// every list including suppress, escalate and tag lists, in the
+		// order they are evaluated
+		Lists []list.List `json:"lists"`
 	}
 
 	return func(u user.User, c *gin.Context) error {
 		whitelists := make([]list.List, 0)
 		blacklists := make([]list.List, 0)
 
-		err := s.db.Model(&whitelists).Where("owner_id = ? AND list_type = ?", u.ID, list.Whitelist).Select()
+		err := s.db.Model(&whitelists).
+			Where("owner_id = ? AND organisation_id = ? AND list_type = ?", u.ID, organisationID(c), list.Whitelist).
+			Select()
 		if err != nil {
 			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select"))
 		}
 
-		err = s.db.Model(&blacklists).Where("owner_id = ? AND list_type = ?", u.ID, list.Blacklist).Select()
+		err = s.db.Model(&blacklists).
+			Where("owner_id = ? AND organisation_id = ? AND list_type = ?", u.ID, organisationID(c), list.Blacklist).
+			Select()
 		if err != nil {
 			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select"))
 		}
 
+		lists, err := list.GetLists(s.db, u.ID, organisationID(c))
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetLists"))
+		}
+
 		c.JSON(
 			http.StatusOK,
-			response{whitelists, blacklists},
+			response{whitelists, blacklists, lists},
 		)
 
 		return nil
@@ -57,9 +71,10 @@ func (s Server) handlePostList() HandlerFunc {
 		}
 
 		l.OwnerID = u.ID
+		l.OrganisationID = organisationID(c)
 
 		_, err := s.db.Model(&l).
-			OnConflict("(list_type, domain, rr_type, record, owner_id) DO NOTHING").
+			OnConflict("(list_type, domain, rr_type, record, fields, min_ttl, max_ttl, source, direction, domain_group, tag, owner_id, organisation_id) DO NOTHING").
 			Insert()
 		if err != nil {
 			return newApiError(http.StatusInternalServerError, "Inserting", errors.Wrap(err, "Insert"))
@@ -67,6 +82,8 @@ func (s Server) handlePostList() HandlerFunc {
 
 		l.AddedAt = time.Now()
 
+		auditDetail(c, "list", l)
+
 		c.JSON(
 			http.StatusCreated,
 			&l,
@@ -76,6 +93,119 @@ func (s Server) handlePostList() HandlerFunc {
 	}
 }
 
+// handlePostListTest reports which of the user's past record changes a
+// candidate list would have matched without saving it
+func (s Server) handlePostListTest() HandlerFunc {
+	// how far back changes are tested by default
+	const defaultWindow = time.Hour * 24 * 30
+
+	type response struct {
+		Since time.Time `json:"since"`
+		// number of changes the list was tested against
+		Tested  int          `json:"tested"`
+		Matches []job.Change `json:"matches"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		// nothing is saved
+		auditSkip(c)
+
+		var l list.List
+
+		if err := c.ShouldBind(&l); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if err := l.Validate(); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Validate"))
+		}
+
+		since := time.Now().Add(-defaultWindow)
+		if v := c.Query("since"); len(v) > 0 {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return newApiError(http.StatusBadRequest, "Invalid since", errors.Wrap(err, "Parse"))
+			}
+			since = t
+		}
+
+		var domains []domain.Domain
+		if err := s.db.Model(&domains).Where("owner_id = ? AND organisation_id = ?", u.ID, organisationID(c)).Select(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select"))
+		}
+
+		byID := make(map[int]domain.Domain, len(domains))
+		ids := make([]int, 0, len(domains))
+		for _, d := range domains {
+			byID[d.ID] = d
+			ids = append(ids, d.ID)
+		}
+
+		changes, err := job.GetChanges(s.db, ids, since)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetChanges"))
+		}
+
+		res := response{
+			Since:   since,
+			Tested:  len(changes),
+			Matches: make([]job.Change, 0),
+		}
+
+		for _, change := range changes {
+			change.Domain = byID[change.DomainID]
+			change.DomainName = change.Domain.Domain
+
+			if l.MatchChange(&change.Record, change.Removed) {
+				res.Matches = append(res.Matches, change)
+			}
+		}
+
+		c.JSON(http.StatusOK, &res)
+
+		return nil
+	}
+}
+
+// handlePutListPosition moves a list within the evaluation order
+func (s Server) handlePutListPosition() HandlerFunc {
+	type request struct {
+		Position int `json:"position"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		var req request
+		if err := c.ShouldBind(&req); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		var l list.List
+		res, err := s.db.Model(&l).
+			Set("position = ?", req.Position).
+			Where("id = ? AND owner_id = ? AND organisation_id = ?", id, u.ID, organisationID(c)).
+			Returning("*").
+			Update()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Updating", errors.Wrap(err, "Update"))
+		}
+
+		if res.RowsAffected() == 0 {
+			return newApiError(http.StatusNotFound, "Not found", errors.New("Not found"))
+		}
+
+		auditDetail(c, "position", l.Position)
+
+		c.JSON(http.StatusOK, &l)
+
+		return nil
+	}
+}
+
 func (s Server) handleDeleteList() HandlerFunc {
 	return func(u user.User, c *gin.Context) error {
 		id, err := strconv.Atoi(c.Param("id"))
@@ -84,7 +214,9 @@ func (s Server) handleDeleteList() HandlerFunc {
 		}
 
 		var deletedIDs []int
-		_, err = s.db.Model((*list.List)(nil)).Where("id = ? AND owner_id = ?", id, u.ID).Delete(&deletedIDs)
+		_, err = s.db.Model((*list.List)(nil)).
+			Where("id = ? AND owner_id = ? AND organisation_id = ?", id, u.ID, organisationID(c)).
+			Delete(&deletedIDs)
 		if err != nil {
 			return newApiError(http.StatusBadRequest, "Bad Request", err)
 		}
@@ -3,13 +3,43 @@ package api
 import (
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/org"
 	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
 )
 
-func handleAuth(c *gin.Context) {
+// handleAuth accepts either a session or an API token passed as an
+// Authorization: Bearer header
+func (s Server) handleAuth(c *gin.Context) {
+	if secret, ok := bearerToken(c); ok {
+		t, err := user.GetToken(s.db, secret, time.Now())
+		if err != nil {
+			log.Printf("Token error: %s", err)
+			c.AbortWithStatusJSON(
+				http.StatusUnauthorized,
+				gin.H{"error": "Not Authorized"},
+			)
+			return
+		}
+
+		go func(t user.Token) {
+			if err := t.Touch(s.db, time.Now()); err != nil {
+				log.Printf("Unable to touch token %d: %s", t.ID, err)
+			}
+		}(t)
+
+		c.Set(tokenKey, t)
+		c.Set(userIDKey, t.UserID)
+		c.Next()
+		return
+	}
+
 	userID := sessions.Default(c).Get(SessionUserKey)
 
 	if userID == nil {
@@ -20,13 +50,114 @@ func handleAuth(c *gin.Context) {
 		return
 	}
 
+	c.Set(userIDKey, userID)
+
 	c.Next()
 }
 
-func (s Server) handleUser(fn HandlerFunc) gin.HandlerFunc {
+// bearerToken returns the secret in the Authorization header
+func bearerToken(c *gin.Context) (string, bool) {
+	const scheme = "Bearer "
+
+	header := c.GetHeader("Authorization")
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return "", false
+	}
+
+	return strings.TrimSpace(header[len(scheme):]), true
+}
+
+// OrganisationHeader selects the organisation a request acts on, without
+// it requests act on the user's own account
+const OrganisationHeader = "X-Organisation"
+
+// context key for the id of the organisation a request acts on
+const organisationKey = "organisation_id"
+
+// context key for the logged in user, which differs from the account a
+// request acts on in an organisation
+const userKey = "user"
+
+// context key for the id of the authenticated user, set by handleAuth
+const userIDKey = "user_id"
+
+// context key for the API token used to authenticate the request
+const tokenKey = "token"
+
+// requestToken returns the API token used to make the request, if any
+func requestToken(c *gin.Context) (user.Token, bool) {
+	t, ok := c.Get(tokenKey)
+	if !ok {
+		return user.Token{}, false
+	}
+	token, ok := t.(user.Token)
+	return token, ok
+}
+
+// sessionUser returns the logged in user making the request
+func sessionUser(c *gin.Context) user.User {
+	u, _ := c.Get(userKey)
+	if u, ok := u.(user.User); ok {
+		return u
+	}
+	return user.User{}
+}
+
+// requireSession returns an error if the request was made with an API token,
+// used for anything that changes how the user logs in
+func requireSession(c *gin.Context) error {
+	if t, ok := requestToken(c); ok {
+		return newApiError(http.StatusForbidden, "Not allowed using an API token", errors.Errorf("token %d used on %s", t.ID, c.FullPath()))
+	}
+	return nil
+}
+
+// organisationID returns the organisation the request acts on, 0 for the
+// user's own account
+func organisationID(c *gin.Context) int {
+	return c.GetInt(organisationKey)
+}
+
+// methodRole is the role needed to make a request, anything that is not a
+// read needs to be able to edit
+func methodRole(method string) org.Role {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return org.RoleViewer
+	}
+	return org.RoleEditor
+}
+
+// tokenScope is the role a token needs for a request, reads only need
+// org.RoleViewer whatever role a member needs to make them. Routes with an
+// explicit org.RoleViewer do not change anything even though their method
+// would
+func tokenScope(role org.Role, method string) org.Role {
+	if role == org.RoleViewer {
+		return org.RoleViewer
+	}
+	return methodRole(method)
+}
+
+// handleSelf passes the logged in user to fn, used for the user's own
+// settings regardless of any organisation. Organisation tokens can not be
+// used
+func (s Server) handleSelf(fn HandlerFunc) gin.HandlerFunc {
+	return s.handleLogin("", func(u user.User, c *gin.Context) error {
+		if t, ok := requestToken(c); ok && t.OrganisationID > 0 {
+			return newApiError(http.StatusForbidden, "Forbidden", errors.Errorf("organisation token %d used on %s", t.ID, c.FullPath()))
+		}
+		return fn(u, c)
+	})
+}
+
+// handleLogin passes the logged in user to fn. A token's scope is checked
+// against what the request does, see tokenScope, membership roles are
+// checked by handleRole. Changes fn makes are audited
+func (s Server) handleLogin(role org.Role, fn HandlerFunc) gin.HandlerFunc {
 	// user cache
 	return func(c *gin.Context) {
-		userID := sessions.Default(c).Get(SessionUserKey)
+		userID, _ := c.Get(userIDKey)
 
 		// use a pool for user objects
 		var u user.User
@@ -46,10 +177,88 @@ func (s Server) handleUser(fn HandlerFunc) gin.HandlerFunc {
 			return
 		}
 
-		handleError(fn)(u, c)
+		c.Set(userKey, u)
+
+		handleError(func(u user.User, c *gin.Context) error {
+			if t, ok := requestToken(c); ok && t.Scope == user.TokenScopeRead && tokenScope(role, c.Request.Method) != org.RoleViewer {
+				return newApiError(http.StatusForbidden, "Token is read only", errors.Errorf("read token %d used to %s %s", t.ID, c.Request.Method, c.FullPath()))
+			}
+
+			if err := fn(u, c); err != nil {
+				return err
+			}
+
+			s.audit(u, c)
+
+			return nil
+		})(u, c)
 	}
 }
 
+// handleUser passes the account the request acts on to fn, see handleRole.
+// Reads need org.RoleViewer and anything else org.RoleEditor
+func (s Server) handleUser(fn HandlerFunc) gin.HandlerFunc {
+	return s.handleRole("", fn)
+}
+
+// handleRole passes the account the request acts on to fn. This is the
+// user's own account unless an organisation is selected with the
+// OrganisationHeader, in which case the user must be a member with at
+// least role and fn is passed the account of the organisation's owner.
+// An empty role uses methodRole. Organisation tokens always act on their
+// organisation
+func (s Server) handleRole(role org.Role, fn HandlerFunc) gin.HandlerFunc {
+	return s.handleLogin(role, func(u user.User, c *gin.Context) error {
+		header := c.GetHeader(OrganisationHeader)
+
+		if t, ok := requestToken(c); ok && t.OrganisationID > 0 {
+			if len(header) > 0 && header != strconv.Itoa(t.OrganisationID) {
+				return newApiError(http.StatusForbidden, "Forbidden", errors.Errorf("organisation token %d used for organisation %s", t.ID, header))
+			}
+			header = strconv.Itoa(t.OrganisationID)
+		}
+
+		if len(header) == 0 {
+			c.Set(organisationKey, 0)
+			c.Set(accountKey, u.ID)
+			return fn(u, c)
+		}
+
+		id, err := strconv.Atoi(header)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "Atoi"))
+		}
+
+		m, err := org.GetMember(s.db, id, u.ID)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetMember"))
+		}
+
+		required := role
+		if len(required) == 0 {
+			required = methodRole(c.Request.Method)
+		}
+
+		if !org.Allows(m.Role, required) {
+			return newApiError(http.StatusForbidden, "Forbidden", errors.Errorf("%s is not allowed to %s %s", m.Role, c.Request.Method, c.FullPath()))
+		}
+
+		if err := requireTwoFactor(m, u); err != nil {
+			return err
+		}
+
+		var owner user.User
+		if err := s.db.Model(&owner).Where("id = ?", m.Organisation.OwnerID).Select(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select owner"))
+		}
+
+		c.Set(organisationKey, m.OrganisationID)
+		c.Set(accountKey, owner.ID)
+
+		return fn(owner, c)
+	})
+}
+
 type apiError struct {
 	statusCode int
 	friendly   string
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// handleGetOIDC tells the frontend whether SSO is available
+func (s Server) handleGetOIDC() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"enabled": s.oidc != nil})
+	}
+}
+
+// handleGetOIDCLogin sends the user to the provider to log in
+func (s Server) handleGetOIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.oidc == nil {
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"error": "SSO is not enabled"},
+			)
+			return
+		}
+
+		state := uniuri.NewLen(32)
+		nonce := uniuri.NewLen(32)
+		verifier := uniuri.NewLen(64)
+
+		session := sessions.Default(c)
+		session.Set(SessionOIDCStateKey, state)
+		session.Set(SessionOIDCNonceKey, nonce)
+		session.Set(SessionOIDCVerifierKey, verifier)
+		if err := session.Save(); err != nil {
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "Failed to save session"},
+			)
+			return
+		}
+
+		c.Redirect(http.StatusFound, s.oidc.AuthCodeURL(state, nonce, verifier))
+	}
+}
+
+// handleGetOIDCCallback logs in the user the provider redirected back,
+// creating them if they are new
+func (s Server) handleGetOIDCCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.oidc == nil {
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"error": "SSO is not enabled"},
+			)
+			return
+		}
+
+		// failures are shown by the frontend's login page
+		fail := func(err error) {
+			log.Printf("SSO error: %s", err)
+			c.Redirect(http.StatusFound, s.emailer.URL("/login?error=sso"))
+		}
+
+		session := sessions.Default(c)
+
+		state, _ := session.Get(SessionOIDCStateKey).(string)
+		nonce, _ := session.Get(SessionOIDCNonceKey).(string)
+		verifier, _ := session.Get(SessionOIDCVerifierKey).(string)
+
+		// the state can only be used once
+		session.Delete(SessionOIDCStateKey)
+		session.Delete(SessionOIDCNonceKey)
+		session.Delete(SessionOIDCVerifierKey)
+		if err := session.Save(); err != nil {
+			fail(errors.Wrap(err, "Save"))
+			return
+		}
+
+		if e := c.Query("error"); len(e) > 0 {
+			fail(errors.Errorf("provider error %s: %s", e, c.Query("error_description")))
+			return
+		}
+
+		if len(state) == 0 || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+			fail(errors.New("state does not match"))
+			return
+		}
+
+		claims, err := s.oidc.Exchange(c.Query("code"), nonce, verifier, time.Now())
+		if err != nil {
+			fail(errors.WithMessage(err, "Exchange"))
+			return
+		}
+
+		email, err := s.oidc.Email(claims)
+		if err != nil {
+			fail(errors.WithMessagef(err, "Email %s", claims))
+			return
+		}
+
+		u, err := s.ssoUser(c, email, claims.Subject())
+		if err != nil {
+			fail(errors.WithMessagef(err, "ssoUser %s", claims))
+			return
+		}
+
+		if u.TwoFactorEnabled() {
+			if err := startTwoFactor(u, c); err != nil {
+				fail(errors.Wrap(err, "startTwoFactor"))
+				return
+			}
+			c.Redirect(http.StatusFound, s.emailer.URL("/login?two_factor=1"))
+			return
+		}
+
+		s.recordAttempt(c, user.AttemptLogin, u.Email, true)
+
+		if err := s.login(u, c); err != nil {
+			fail(errors.Wrap(err, "login"))
+			return
+		}
+
+		c.Redirect(http.StatusFound, s.emailer.URL("/"))
+	}
+}
+
+// ssoUser returns the user for the provider's subject and email, linking
+// an existing account the first time or creating one if provisioning is
+// enabled
+func (s Server) ssoUser(c *gin.Context, email, subject string) (user.User, error) {
+	u, err := user.GetUser(s.db, email)
+	switch {
+	case err == pg.ErrNoRows:
+		if !s.oidc.Provision() {
+			return u, errors.Errorf("%q has no account and provisioning is disabled", email)
+		}
+
+		u, err = user.NewSSOUser(email, subject, time.Now())
+		if err != nil {
+			return u, errors.WithMessage(err, "NewSSOUser")
+		}
+
+		if err := u.Insert(s.db); err != nil {
+			return u, errors.WithMessage(err, "Insert")
+		}
+
+		log.Printf("SSO created user %d for %q", u.ID, email)
+
+		e := auditEntry(u, c)
+		e.Details["sso"] = "created"
+		s.recordAudit(e)
+
+		return u, nil
+
+	case err != nil:
+		return u, errors.WithMessage(err, "GetUser")
+	}
+
+	// another identity at the provider now has this email
+	if len(u.OIDCSubject) > 0 && u.OIDCSubject != subject {
+		return u, errors.Errorf("user %d is linked to another subject", u.ID)
+	}
+
+	if len(u.OIDCSubject) > 0 && !u.VerifiedAt.IsZero() {
+		return u, nil
+	}
+
+	// the provider has verified the email, so an unverified account may
+	// have been registered by someone else and its password is replaced
+	query := s.db.Model(&u).Set("oidc_subject = ?", subject)
+	if u.VerifiedAt.IsZero() {
+		replacement, err := user.NewSSOUser(email, subject, time.Now())
+		if err != nil {
+			return u, errors.WithMessage(err, "NewSSOUser")
+		}
+		query = query.Set("password = ?, verified_at = now()", replacement.Password)
+	}
+
+	if _, err := query.WherePK().Update(); err != nil {
+		return u, errors.Wrap(err, "Update")
+	}
+
+	u.OIDCSubject = subject
+
+	log.Printf("SSO linked user %d", u.ID)
+
+	e := auditEntry(u, c)
+	e.Details["sso"] = "linked"
+	s.recordAudit(e)
+
+	return u, nil
+}
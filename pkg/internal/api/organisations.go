@@ -0,0 +1,398 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/list"
+	"github.com/jawr/whois-bi/pkg/internal/notify"
+	"github.com/jawr/whois-bi/pkg/internal/org"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
+	"github.com/pkg/errors"
+)
+
+type OrganisationHandlerFunc func(org.Member, user.User, *gin.Context) error
+
+// handleOrganisation passes the user's membership of the organisation in
+// the path to fn if they have at least role
+func (s Server) handleOrganisation(role org.Role, fn OrganisationHandlerFunc) gin.HandlerFunc {
+	return s.handleSelf(func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		m, err := org.GetMember(s.db, id, u.ID)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetMember"))
+		}
+
+		if !org.Allows(m.Role, role) {
+			return newApiError(http.StatusForbidden, "Forbidden", errors.Errorf("%s is not %s", m.Role, role))
+		}
+
+		if err := requireTwoFactor(m, u); err != nil {
+			return err
+		}
+
+		// changes are audited against the organisation
+		c.Set(organisationKey, m.OrganisationID)
+		c.Set(accountKey, m.Organisation.OwnerID)
+
+		return fn(m, u, c)
+	})
+}
+
+// handleGetOrganisations returns the user's memberships
+func (s Server) handleGetOrganisations() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		members, err := org.GetMemberships(s.db, u.ID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetMemberships"))
+		}
+
+		c.JSON(http.StatusOK, &members)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostOrganisation() HandlerFunc {
+	type Request struct {
+		Name string `json:"name"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		o, err := org.NewOrganisation(request.Name, u)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NewOrganisation"))
+		}
+
+		err = s.db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+			return o.Insert(tx)
+		})
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Inserting", errors.WithMessage(err, "Insert"))
+		}
+
+		c.Set(organisationKey, o.ID)
+
+		c.JSON(http.StatusCreated, &o)
+
+		return nil
+	}
+}
+
+func (s Server) handlePutOrganisation() OrganisationHandlerFunc {
+	type Request struct {
+		Name string `json:"name"`
+	}
+
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		name, err := org.NormaliseName(request.Name)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NormaliseName"))
+		}
+
+		m.Organisation.Name = name
+
+		if _, err := s.db.Model(&m.Organisation).Set("name = ?name").WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, &m.Organisation)
+
+		return nil
+	}
+}
+
+// handleDeleteOrganisation deletes the organisation, its domains are kept
+// by its owner and its lists, channels, rules, webhooks and groups are
+// deleted
+func (s Server) handleDeleteOrganisation() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		err := s.db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+			_, err := tx.Model((*domain.Domain)(nil)).
+				Set("organisation_id = 0").
+				Where("organisation_id = ?", m.OrganisationID).
+				Update()
+			if err != nil {
+				return errors.Wrap(err, "Update domains")
+			}
+
+			settings := map[string]interface{}{
+				"lists":    (*list.List)(nil),
+				"channels": (*notify.Channel)(nil),
+				"rules":    (*notify.Rule)(nil),
+				"webhooks": (*webhook.Webhook)(nil),
+				"groups":   (*domain.Group)(nil),
+			}
+
+			for name, model := range settings {
+				if _, err := tx.Model(model).Where("organisation_id = ?", m.OrganisationID).Delete(); err != nil {
+					return errors.Wrapf(err, "Delete %s", name)
+				}
+			}
+
+			if _, err := tx.Model((*org.Member)(nil)).Where("organisation_id = ?", m.OrganisationID).Delete(); err != nil {
+				return errors.Wrap(err, "Delete members")
+			}
+
+			if _, err := tx.Model((*org.Invitation)(nil)).Where("organisation_id = ?", m.OrganisationID).Delete(); err != nil {
+				return errors.Wrap(err, "Delete invitations")
+			}
+
+			if _, err := tx.Model(&m.Organisation).WherePK().Delete(); err != nil {
+				return errors.Wrap(err, "Delete organisation")
+			}
+
+			return nil
+		})
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
+
+func (s Server) handleGetMembers() OrganisationHandlerFunc {
+	type Response struct {
+		UserID    int       `json:"user_id"`
+		Email     string    `json:"email"`
+		Role      org.Role  `json:"role"`
+		TwoFactor bool      `json:"two_factor"`
+		AddedAt   time.Time `json:"added_at"`
+	}
+
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		members, err := org.GetMembers(s.db, m.OrganisationID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetMembers"))
+		}
+
+		response := make([]Response, 0, len(members))
+		for _, member := range members {
+			response = append(response, Response{
+				UserID:    member.UserID,
+				Email:     member.User.Email,
+				Role:      member.Role,
+				TwoFactor: member.User.TwoFactorEnabled(),
+				AddedAt:   member.AddedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, &response)
+
+		return nil
+	}
+}
+
+// memberFromPath returns the organisation's member with the user id in the
+// path, the owner can not be changed
+func (s Server) memberFromPath(m org.Member, c *gin.Context) (org.Member, error) {
+	userID, err := strconv.Atoi(c.Param("user"))
+	if err != nil {
+		return org.Member{}, newApiError(http.StatusBadRequest, "Bad Request", err)
+	}
+
+	member, err := org.GetMember(s.db, m.OrganisationID, userID)
+	if err != nil {
+		return org.Member{}, newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetMember"))
+	}
+
+	if member.Role == org.RoleOwner {
+		return org.Member{}, newApiError(http.StatusBadRequest, "The owner can not be changed", errors.New("member is the owner"))
+	}
+
+	return member, nil
+}
+
+func (s Server) handlePutMember() OrganisationHandlerFunc {
+	type Request struct {
+		Role org.Role `json:"role"`
+	}
+
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if !org.ValidRole(request.Role) || request.Role == org.RoleOwner {
+			return newApiError(http.StatusBadRequest, "Invalid role", errors.Errorf("invalid role %q", request.Role))
+		}
+
+		member, err := s.memberFromPath(m, c)
+		if err != nil {
+			return err
+		}
+
+		member.Role = request.Role
+
+		if _, err := s.db.Model(&member).Set("role = ?role").WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		auditDetail(c, "role", member.Role)
+
+		c.JSON(http.StatusOK, &member)
+
+		return nil
+	}
+}
+
+// handleDeleteMember removes a member, admins can remove anyone but the
+// owner and members can remove themselves
+func (s Server) handleDeleteMember() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		member, err := s.memberFromPath(m, c)
+		if err != nil {
+			return err
+		}
+
+		if member.UserID != u.ID && !org.Allows(m.Role, org.RoleAdmin) {
+			return newApiError(http.StatusForbidden, "Forbidden", errors.Errorf("%s can not remove members", m.Role))
+		}
+
+		if _, err := s.db.Model(&member).WherePK().Delete(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Delete"))
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
+
+func (s Server) handleGetInvitations() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		invitations := make([]org.Invitation, 0)
+
+		err := s.db.Model(&invitations).
+			Where("organisation_id = ? AND accepted_at IS NULL", m.OrganisationID).
+			Order("created_at DESC").
+			Select()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select"))
+		}
+
+		c.JSON(http.StatusOK, &invitations)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostInvitation() OrganisationHandlerFunc {
+	type Request struct {
+		Email string   `json:"email"`
+		Role  org.Role `json:"role"`
+	}
+
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		invitation, err := org.NewInvitation(m.OrganisationID, request.Email, request.Role, u, time.Now())
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NewInvitation"))
+		}
+
+		if err := invitation.Insert(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Inserting", errors.WithMessage(err, "Insert"))
+		}
+
+		data := struct {
+			Organisation string
+			Role         org.Role
+			InvitedBy    string
+			Code         string
+		}{
+			m.Organisation.Name,
+			invitation.Role,
+			u.Email,
+			invitation.Code,
+		}
+
+		// the invitee may not have an account so use the inviter's locale
+		if err := s.emailer.Queue(s.db, "invite:"+invitation.Code, invitation.Email, u.Locale, "invite", data); err != nil {
+			log.Println(err)
+			return newApiError(http.StatusInternalServerError, "Unable to send invitation email", errors.WithMessage(err, "Queue"))
+		}
+
+		c.JSON(http.StatusCreated, &invitation)
+
+		return nil
+	}
+}
+
+func (s Server) handleDeleteInvitation() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("invitation"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		_, err = s.db.Model((*org.Invitation)(nil)).Where("id = ? AND organisation_id = ?", id, m.OrganisationID).Delete()
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
+
+// handlePostAcceptInvitation adds the logged in user to the organisation
+// using the code from an invitation email
+func (s Server) handlePostAcceptInvitation() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		invitation, err := org.GetInvitation(s.db, c.Param("code"))
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Unknown invitation", errors.WithMessage(err, "GetInvitation"))
+		}
+
+		if err := invitation.Usable(u, time.Now()); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Usable"))
+		}
+
+		err = s.db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+			return invitation.Accept(tx, u, time.Now())
+		})
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "Accept"))
+		}
+
+		c.Set(organisationKey, invitation.OrganisationID)
+		c.Set(accountKey, invitation.Organisation.OwnerID)
+
+		c.JSON(http.StatusOK, &invitation.Organisation)
+
+		return nil
+	}
+}
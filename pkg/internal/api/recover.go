@@ -1,10 +1,9 @@
 package api
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jawr/whois-bi/pkg/internal/user"
@@ -26,42 +25,60 @@ func (s Server) handlePostRecover() gin.HandlerFunc {
 			return
 		}
 
-		var u user.User
-		if err := s.db.Model(&u).Where("email = ?", request.Email).Select(); err != nil {
+		wait, send, err := user.RecoverDelay(s.db, request.Email, c.ClientIP(), time.Now())
+		if err != nil {
+			log.Printf("RecoverDelay error: %s", err)
 			c.JSON(
-				http.StatusBadRequest,
-				gin.H{"error": "Unknown email"},
+				http.StatusInternalServerError,
+				gin.H{"error": "Internal Server Error"},
 			)
 			return
 		}
 
-		rec := user.NewRecover(u)
-
-		if err := rec.Insert(s.db); err != nil {
-			c.JSON(
-				http.StatusBadRequest,
-				gin.H{"error": "Recovery already in progress"},
-			)
+		if wait > 0 {
+			tooManyAttempts(c, wait)
 			return
 		}
 
-		body := fmt.Sprintf(
-			`Account recover requested, if this was you please continue to <a href="https://%s/recover/%s">reset your password<a/>.\nIf you did not request a password reset, please ignore this email.`,
-			os.Getenv("DOMAIN"),
-			rec.Code,
-		)
+		// the response is the same whether or not an email was sent so it
+		// does not give away which emails have accounts
+		sent := send && s.startRecover(request.Email)
 
-		if err := s.emailer.Send(u.Email, "Account Recovery", body); err != nil {
-			log.Println(err)
-			c.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Unable to send recovery email"},
-			)
-			return
-		}
+		s.recordAttempt(c, user.AttemptRecover, request.Email, sent)
+
+		c.JSON(http.StatusOK, gin.H{"status": "Recovery process started. If an account exists for that email a recovery link has been sent."})
+	}
+}
+
+// startRecover emails a recovery code to the user with the email, any
+// recovery already in progress is replaced. Returns true if an email was
+// sent
+func (s Server) startRecover(email string) bool {
+	var u user.User
+	if err := s.db.Model(&u).Where("lower(email) = ?", user.NormaliseEmail(email)).Select(); err != nil {
+		return false
+	}
+
+	if _, err := s.db.Model((*user.Recover)(nil)).Where("user_id = ?", u.ID).Delete(); err != nil {
+		log.Printf("Unable to delete recover for %d: %s", u.ID, err)
+		return false
+	}
+
+	rec := user.NewRecover(u)
 
-		c.JSON(http.StatusOK, gin.H{"status": "Recovery process started."})
+	if err := rec.Insert(s.db); err != nil {
+		log.Printf("Unable to insert recover for %d: %s", u.ID, err)
+		return false
 	}
+
+	data := struct{ Code string }{rec.Code}
+
+	if err := s.emailer.Queue(s.db, "recover:"+rec.Code, u.Email, u.Locale, "recover", data); err != nil {
+		log.Printf("Unable to send recover for %d: %s", u.ID, err)
+		return false
+	}
+
+	return true
 }
 
 func (s Server) handlePostRecoverCode() gin.HandlerFunc {
@@ -101,6 +118,10 @@ func (s Server) handlePostRecoverCode() gin.HandlerFunc {
 			return
 		}
 
+		// the recovery is removed once used so it is found first to audit
+		var rec user.Recover
+		s.db.Model(&rec).Relation("User").Where("code = ?", request.Code).Select()
+
 		err = user.RecoverPassword(s.db, request.Code, newPassword)
 		if err != nil {
 			c.JSON(
@@ -110,6 +131,8 @@ func (s Server) handlePostRecoverCode() gin.HandlerFunc {
 			return
 		}
 
+		s.recordAudit(auditEntry(rec.User, c))
+
 		c.JSON(http.StatusOK, gin.H{"status": "Recovery complete. Please login with your new credentials!"})
 	}
 }
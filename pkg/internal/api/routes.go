@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/org"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 )
 
@@ -14,36 +15,132 @@ func (s Server) setupRoutes() {
 	// authentication
 	base.POST("/register", s.handlePostRegister())
 	base.POST("/login", s.handlePostLogin())
+	base.POST("/login/two-factor", s.handlePostLoginTwoFactor())
 	base.GET("/logout", s.handleGetLogout())
 	base.POST("/verify/:code", s.handlePostVerify())
 
+	// SSO
+	base.GET("/oidc", s.handleGetOIDC())
+	base.GET("/oidc/login", s.handleGetOIDCLogin())
+	base.GET("/oidc/callback", s.handleGetOIDCCallback())
+
 	base.POST("/recover", s.handlePostRecover())
 	base.POST("/recover/code", s.handlePostRecoverCode())
 
+	// one click links in alerts
+	base.POST("/acknowledge/:token", s.handlePostAcknowledge())
+
 	// user routes
 	user := base.Group("/user/")
-	user.Use(handleAuth)
+	user.Use(s.handleAuth)
 
 	user.GET("/status", s.handleGetStatus())
 
+	// settings
+	user.PUT("/locale", s.handleSelf(s.handlePutLocale()))
+	user.PUT("/timezone", s.handleSelf(s.handlePutTimezone()))
+	user.PUT("/digest", s.handleSelf(s.handlePutDigest()))
+	user.GET("/alerts/settings", s.handleSelf(s.handleGetAlertSettings()))
+	user.PUT("/alerts/settings", s.handleSelf(s.handlePutAlertSettings()))
+
+	// recent attempts to log in to or recover the account
+	user.GET("/login-attempts", s.handleSelf(s.handleGetLoginAttempts()))
+
+	// changes made to the account or organisation
+	user.GET("/audit", s.handleRole(org.RoleAdmin, s.handleGetAudit()))
+
+	// two factor authentication
+	user.GET("/two-factor", s.handleSelf(s.handleGetTwoFactor()))
+	user.POST("/two-factor/totp", s.handleSelf(s.handlePostTOTP()))
+	user.PUT("/two-factor/totp", s.handleSelf(s.handlePutTOTP()))
+	user.DELETE("/two-factor/totp", s.handleSelf(s.handleDeleteTOTP()))
+	user.POST("/two-factor/recovery-codes", s.handleSelf(s.handlePostRecoveryCodes()))
+
+	// organisations, other routes act on the organisation selected with
+	// the X-Organisation header
+	user.GET("/organisations", s.handleSelf(s.handleGetOrganisations()))
+	user.POST("/organisations", s.handleSelf(s.handlePostOrganisation()))
+	user.PUT("/organisations/:id", s.handleOrganisation(org.RoleAdmin, s.handlePutOrganisation()))
+	user.DELETE("/organisations/:id", s.handleOrganisation(org.RoleOwner, s.handleDeleteOrganisation()))
+	user.PUT("/organisations/:id/two-factor", s.handleOrganisation(org.RoleAdmin, s.handlePutOrganisationTwoFactor()))
+	user.GET("/organisations/:id/members", s.handleOrganisation(org.RoleViewer, s.handleGetMembers()))
+	user.PUT("/organisations/:id/members/:user", s.handleOrganisation(org.RoleAdmin, s.handlePutMember()))
+	user.DELETE("/organisations/:id/members/:user", s.handleOrganisation(org.RoleViewer, s.handleDeleteMember()))
+	user.GET("/organisations/:id/invitations", s.handleOrganisation(org.RoleAdmin, s.handleGetInvitations()))
+	user.POST("/organisations/:id/invitations", s.handleOrganisation(org.RoleAdmin, s.handlePostInvitation()))
+	user.DELETE("/organisations/:id/invitations/:invitation", s.handleOrganisation(org.RoleAdmin, s.handleDeleteInvitation()))
+	user.POST("/invitations/:code", s.handleSelf(s.handlePostAcceptInvitation()))
+	user.GET("/organisations/:id/tokens", s.handleOrganisation(org.RoleAdmin, s.handleGetOrganisationTokens()))
+	user.POST("/organisations/:id/tokens", s.handleOrganisation(org.RoleAdmin, s.handlePostOrganisationToken()))
+	user.DELETE("/organisations/:id/tokens/:token", s.handleOrganisation(org.RoleAdmin, s.handleDeleteOrganisationToken()))
+
+	// API tokens, used with an Authorization: Bearer header
+	user.GET("/tokens", s.handleSelf(s.handleGetTokens()))
+	user.POST("/tokens", s.handleSelf(s.handlePostToken()))
+	user.DELETE("/tokens/:id", s.handleSelf(s.handleDeleteToken()))
+
+	// incidents
+	user.GET("/incidents", s.handleUser(s.handleGetIncidents()))
+	user.PUT("/incidents/:id", s.handleUser(s.handlePutIncident()))
+
+	// digests
+	user.GET("/digests", s.handleSelf(s.handleGetDigests()))
+	user.GET("/digests/:id", s.handleSelf(s.handleGetDigest()))
+
 	// domain read
 	user.GET("/domains", s.handleUser(s.handleGetDomains()))
 	user.GET("/domain/:domain", s.handleDomain(s.handleGetDomain()))
-	user.DELETE("/domain/:domain", s.handleDomain(s.handleDeleteDomain()))
+	user.DELETE("/domain/:domain", s.handleDomainRole(org.RoleAdmin, s.handleDeleteDomain()))
 	user.GET("/domain/:domain/records", s.handleDomain(s.handleGetDomainRecords()))
 	user.GET("/domain/:domain/whois", s.handleDomain(s.handleGetDomainWhois()))
 	user.PUT("/domain/:domain/batch", s.handleDomain(s.handlePutDomainBatch()))
+	user.PUT("/domain/:domain/schedule", s.handleDomain(s.handlePutDomainSchedule()))
+	user.PUT("/domain/:domain/tags", s.handleDomain(s.handlePutDomainTags()))
+
+	// domain groups
+	user.GET("/groups", s.handleUser(s.handleGetGroups()))
+	user.PUT("/groups/:tag", s.handleUser(s.handlePutGroup()))
+	user.DELETE("/groups/:tag", s.handleUser(s.handleDeleteGroup()))
+
+	// expected records
+	user.GET("/domain/:domain/expected", s.handleDomain(s.handleGetExpectedRecords()))
+	user.POST("/domain/:domain/expected", s.handleDomain(s.handlePostExpectedRecord()))
+	user.DELETE("/domain/:domain/expected/:id", s.handleDomain(s.handleDeleteExpectedRecord()))
+	user.GET("/domain/:domain/drift", s.handleDomain(s.handleGetDomainDrift()))
 
 	// lists
 	user.GET("/lists", s.handleUser(s.handleGetMatches()))
 	user.POST("/lists", s.handleUser(s.handlePostList()))
+	user.POST("/lists/test", s.handleRole(org.RoleViewer, s.handlePostListTest()))
+	user.PUT("/lists/:id/position", s.handleUser(s.handlePutListPosition()))
 	user.DELETE("/lists/:id", s.handleUser(s.handleDeleteList()))
 
+	// notification channels
+	user.GET("/channels", s.handleUser(s.handleGetChannels()))
+	user.POST("/channels", s.handleUser(s.handlePostChannel()))
+	user.DELETE("/channels/:id", s.handleUser(s.handleDeleteChannel()))
+
+	// notification routing rules
+	user.GET("/rules", s.handleUser(s.handleGetRules()))
+	user.POST("/rules", s.handleUser(s.handlePostRule()))
+	user.PUT("/rules/:id", s.handleUser(s.handlePutRule()))
+	user.DELETE("/rules/:id", s.handleUser(s.handleDeleteRule()))
+
+	// webhooks
+	user.GET("/webhooks", s.handleUser(s.handleGetWebhooks()))
+	user.POST("/webhooks", s.handleUser(s.handlePostWebhook()))
+	user.DELETE("/webhooks/:id", s.handleWebhook(s.handleDeleteWebhook()))
+	user.GET("/webhooks/:id/deliveries", s.handleWebhook(s.handleGetWebhookDeliveries()))
+	user.POST("/webhooks/:id/ping", s.handleWebhook(s.handlePostWebhookPing()))
+
 	// domain create
 	user.POST("/domain", s.handleUser(s.handlePostDomain()))
 	user.POST("/domain/:domain/record", s.handleDomain(s.handlePostRecord()))
 
 	// job read
-	user.GET("/jobs/:domain", s.handleUser(s.handleGetJobs()))
-	user.POST("/jobs/:domain", s.handleUser(s.handlePostJob()))
+	user.GET("/jobs/:domain", s.handleDomain(s.handleGetJobs()))
+	user.POST("/jobs/:domain", s.handleDomain(s.handlePostJob()))
+	user.GET("/jobs/:domain/:id/progress", s.handleDomain(s.handleGetJobProgress()))
+	user.GET("/jobs/:domain/:id/lists", s.handleDomain(s.handleGetJobLists()))
+	user.POST("/jobs/:domain/:id/lists", s.handleDomainRole(org.RoleViewer, s.handlePostJobLists()))
 }
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/jawr/whois-bi/pkg/internal/notify"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handleGetRules() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		rules, err := notify.GetRules(s.db, u.ID, organisationID(c))
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetRules"))
+		}
+
+		c.JSON(http.StatusOK, &rules)
+
+		return nil
+	}
+}
+
+// bindRule binds and validates a rule from the request
+func (s Server) bindRule(u user.User, c *gin.Context) (notify.Rule, error) {
+	var rule notify.Rule
+
+	if err := c.ShouldBind(&rule); err != nil {
+		return rule, newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+	}
+
+	rule.OwnerID = u.ID
+	rule.OrganisationID = organisationID(c)
+
+	if len(rule.MinSeverity) == 0 {
+		rule.MinSeverity = notify.SeverityInfo
+	}
+
+	if rule.ChangeTypes == nil {
+		rule.ChangeTypes = make([]string, 0)
+	}
+
+	if rule.ChannelIDs == nil {
+		rule.ChannelIDs = make([]int, 0)
+	}
+
+	if rule.Recipients == nil {
+		rule.Recipients = make([]string, 0)
+	}
+
+	if err := rule.Validate(); err != nil {
+		return rule, newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "Validate"))
+	}
+
+	// channels must belong to the account or organisation
+	if len(rule.ChannelIDs) > 0 {
+		count, err := s.db.Model((*notify.Channel)(nil)).
+			Where("owner_id = ? AND organisation_id = ? AND id IN (?)", u.ID, rule.OrganisationID, pg.In(rule.ChannelIDs)).
+			Count()
+		if err != nil {
+			return rule, newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Count"))
+		}
+
+		if count != len(rule.ChannelIDs) {
+			return rule, newApiError(http.StatusBadRequest, "Unknown channel", errors.New("Unknown channel"))
+		}
+	}
+
+	return rule, nil
+}
+
+func (s Server) handlePostRule() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		rule, err := s.bindRule(u, c)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.db.Model(&rule).Returning("*").Insert(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Inserting", errors.Wrap(err, "Insert"))
+		}
+
+		c.JSON(http.StatusCreated, &rule)
+
+		return nil
+	}
+}
+
+func (s Server) handlePutRule() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		rule, err := s.bindRule(u, c)
+		if err != nil {
+			return err
+		}
+
+		rule.ID = id
+
+		res, err := s.db.Model(&rule).
+			ExcludeColumn("added_at").
+			Where("id = ? AND owner_id = ? AND organisation_id = ?", id, u.ID, rule.OrganisationID).
+			Returning("*").
+			Update()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Updating", errors.Wrap(err, "Update"))
+		}
+
+		if res.RowsAffected() == 0 {
+			return newApiError(http.StatusNotFound, "Not found", errors.New("Not found"))
+		}
+
+		c.JSON(http.StatusOK, &rule)
+
+		return nil
+	}
+}
+
+func (s Server) handleDeleteRule() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		_, err = s.db.Model((*notify.Rule)(nil)).
+			Where("id = ? AND owner_id = ? AND organisation_id = ?", id, u.ID, organisationID(c)).
+			Delete()
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v10"
 	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/jawr/whois-bi/pkg/internal/oidc"
+	"github.com/jawr/whois-bi/pkg/internal/queue"
 )
 
 type Server struct {
@@ -17,9 +19,15 @@ type Server struct {
 	router *gin.Engine
 
 	emailer *emailer.Emailer
+
+	// used to dispatch user triggered jobs straight away
+	publisher queue.Publisher
+
+	// nil unless SSO is configured
+	oidc *oidc.Provider
 }
 
-func NewServer(db *pg.DB, emailer *emailer.Emailer) *Server {
+func NewServer(db *pg.DB, emailer *emailer.Emailer, publisher queue.Publisher, provider *oidc.Provider) *Server {
 	router := gin.Default()
 
 	store := cookie.NewStore([]byte(os.Getenv("HTTP_COOKIE_SECRET")))
@@ -34,9 +42,11 @@ func NewServer(db *pg.DB, emailer *emailer.Emailer) *Server {
 	router.Use(sessions.Sessions(os.Getenv("HTTP_SESSION_ID"), store))
 
 	server := Server{
-		db:      db,
-		router:  router,
-		emailer: emailer,
+		db:        db,
+		router:    router,
+		emailer:   emailer,
+		publisher: publisher,
+		oidc:      provider,
 	}
 
 	return &server
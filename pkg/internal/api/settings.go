@@ -0,0 +1,177 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+func (s Server) handlePutLocale() HandlerFunc {
+	type Request struct {
+		Locale string `json:"locale"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if !emailer.ValidLocale(request.Locale) {
+			return newApiError(http.StatusBadRequest, "Invalid locale", errors.Errorf("invalid locale %q", request.Locale))
+		}
+
+		if _, err := s.db.Model(&u).Set("locale = ?", request.Locale).WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"locale": request.Locale})
+
+		return nil
+	}
+}
+
+func (s Server) handlePutTimezone() HandlerFunc {
+	type Request struct {
+		Timezone string `json:"timezone"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if err := user.ValidateTimezone(request.Timezone); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "ValidateTimezone"))
+		}
+
+		if _, err := s.db.Model(&u).Set("timezone = ?", request.Timezone).WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"timezone": request.Timezone})
+
+		return nil
+	}
+}
+
+func (s Server) handlePutDigest() HandlerFunc {
+	type Request struct {
+		Frequency user.DigestFrequency `json:"frequency"`
+		Time      string               `json:"time"`
+		Weekday   int                  `json:"weekday"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if request.Frequency != user.DigestOff && len(request.Time) == 0 {
+			request.Time = u.DigestTime
+		}
+
+		if err := user.ValidateDigest(request.Frequency, request.Time, request.Weekday); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "ValidateDigest"))
+		}
+
+		u.DigestFrequency = request.Frequency
+		u.DigestWeekday = request.Weekday
+		if len(request.Time) > 0 {
+			u.DigestTime = request.Time
+		}
+
+		// start from the next scheduled digest rather than sending one
+		// straight away
+		u.LastDigestAt = time.Now()
+
+		_, err := s.db.Model(&u).
+			Set("digest_frequency = ?digest_frequency, digest_time = ?digest_time, digest_weekday = ?digest_weekday, last_digest_at = ?last_digest_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"frequency": u.DigestFrequency,
+			"time":      u.DigestTime,
+			"weekday":   u.DigestWeekday,
+			"timezone":  u.Location().String(),
+		})
+
+		return nil
+	}
+}
+
+type alertSettings struct {
+	MinDomains      int    `json:"min_domains"`
+	MaxDelay        int    `json:"max_delay"`
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	Timezone        string `json:"timezone"`
+}
+
+func newAlertSettings(u user.User) alertSettings {
+	return alertSettings{
+		MinDomains:      u.AlertMinDomains,
+		MaxDelay:        u.AlertMaxDelay,
+		QuietHoursStart: u.QuietHoursStart,
+		QuietHoursEnd:   u.QuietHoursEnd,
+		Timezone:        u.Location().String(),
+	}
+}
+
+func (s Server) handleGetAlertSettings() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		c.JSON(http.StatusOK, newAlertSettings(u))
+		return nil
+	}
+}
+
+func (s Server) handlePutAlertSettings() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		// missing fields keep their current values
+		request := newAlertSettings(u)
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		err := user.ValidateAlertSettings(request.MinDomains, request.MaxDelay, request.QuietHoursStart, request.QuietHoursEnd)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "ValidateAlertSettings"))
+		}
+
+		if err := user.ValidateTimezone(request.Timezone); err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "ValidateTimezone"))
+		}
+
+		u.AlertMinDomains = request.MinDomains
+		u.AlertMaxDelay = request.MaxDelay
+		u.QuietHoursStart = request.QuietHoursStart
+		u.QuietHoursEnd = request.QuietHoursEnd
+		u.Timezone = request.Timezone
+
+		_, err = s.db.Model(&u).
+			Set("alert_min_domains = ?alert_min_domains, alert_max_delay = ?alert_max_delay, quiet_hours_start = ?quiet_hours_start, quiet_hours_end = ?quiet_hours_end, timezone = ?timezone").
+			WherePK().
+			Update()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, newAlertSettings(u))
+
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/org"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// tokenRequest creates a token, ExpiresIn is in days and defaults to
+// user.DefaultTokenTTL
+type tokenRequest struct {
+	Name      string          `json:"name"`
+	Scope     user.TokenScope `json:"scope"`
+	ExpiresIn int             `json:"expires_in"`
+}
+
+// createToken creates and stores a token for the user from the request,
+// responding with the token and its secret which is only ever shown once
+func (s Server) createToken(u user.User, organisationID int, c *gin.Context) error {
+	// stop a leaked token from being used to create more
+	if err := requireSession(c); err != nil {
+		return err
+	}
+
+	var request tokenRequest
+
+	if err := c.ShouldBind(&request); err != nil {
+		return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+	}
+
+	ttl := time.Duration(request.ExpiresIn) * time.Hour * 24
+
+	t, secret, err := user.NewToken(u.ID, organisationID, request.Name, request.Scope, ttl, time.Now())
+	if err != nil {
+		return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NewToken"))
+	}
+
+	if err := t.Insert(s.db); err != nil {
+		return newApiError(http.StatusInternalServerError, "Inserting", errors.WithMessage(err, "Insert"))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": &t, "secret": secret})
+
+	return nil
+}
+
+// revokeToken revokes the token in the path if it matches the where
+// clause
+func (s Server) revokeToken(param string, c *gin.Context, where string, params ...interface{}) error {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		return newApiError(http.StatusBadRequest, "Bad Request", err)
+	}
+
+	var t user.Token
+	err = s.db.Model(&t).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Where(where, params...).
+		Select()
+	if err != nil {
+		return newApiError(http.StatusNotFound, "Not found", errors.Wrap(err, "Select"))
+	}
+
+	if err := t.Revoke(s.db, time.Now()); err != nil {
+		return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "Revoke"))
+	}
+
+	c.JSON(http.StatusOK, &t)
+
+	return nil
+}
+
+// handleGetTokens returns the user's personal tokens
+func (s Server) handleGetTokens() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		tokens, err := user.GetTokens(s.db, u.ID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetTokens"))
+		}
+
+		c.JSON(http.StatusOK, &tokens)
+
+		return nil
+	}
+}
+
+// handlePostToken creates a personal token
+func (s Server) handlePostToken() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		return s.createToken(u, 0, c)
+	}
+}
+
+// handleDeleteToken revokes one of the user's personal tokens
+func (s Server) handleDeleteToken() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		return s.revokeToken("id", c, "user_id = ? AND organisation_id = 0", u.ID)
+	}
+}
+
+// handleGetOrganisationTokens returns the organisation's tokens
+func (s Server) handleGetOrganisationTokens() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		tokens, err := user.GetOrganisationTokens(s.db, m.OrganisationID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetOrganisationTokens"))
+		}
+
+		c.JSON(http.StatusOK, &tokens)
+
+		return nil
+	}
+}
+
+// handlePostOrganisationToken creates a token acting as the user on the
+// organisation, it can do no more than the user's role allows
+func (s Server) handlePostOrganisationToken() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		return s.createToken(u, m.OrganisationID, c)
+	}
+}
+
+// handleDeleteOrganisationToken revokes any of the organisation's tokens
+func (s Server) handleDeleteOrganisationToken() OrganisationHandlerFunc {
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		return s.revokeToken("token", c, "organisation_id = ?", m.OrganisationID)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/org"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// requireTwoFactor returns an error if the member's organisation requires
+// two factor authentication and the user has not enabled it
+func requireTwoFactor(m org.Member, u user.User) error {
+	if !m.Organisation.Admits(u) {
+		return newApiError(http.StatusForbidden, "Two factor authentication is required by this organisation", errors.Errorf("user %d has not enabled two factor for organisation %d", u.ID, m.OrganisationID))
+	}
+	return nil
+}
+
+// handleGetTwoFactor returns whether the user has enabled two factor
+// authentication and whether an organisation requires it
+func (s Server) handleGetTwoFactor() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		required, err := org.TwoFactorRequired(s.db, u.ID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "TwoFactorRequired"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":        u.TwoFactorEnabled(),
+			"recovery_codes": len(u.RecoveryCodes),
+			"required":       required,
+		})
+
+		return nil
+	}
+}
+
+// handlePostTOTP starts TOTP enrolment, returning the secret to add to an
+// authenticator app
+func (s Server) handlePostTOTP() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		if err := requireSession(c); err != nil {
+			return err
+		}
+
+		secret, err := u.EnrolTOTP()
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "EnrolTOTP"))
+		}
+
+		if err := u.UpdateTwoFactor(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UpdateTwoFactor"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret": secret,
+			"uri":    user.TOTPURI(secret, u.Email),
+		})
+
+		return nil
+	}
+}
+
+// handlePutTOTP confirms TOTP enrolment with a code, returning the
+// recovery codes
+func (s Server) handlePutTOTP() HandlerFunc {
+	type Request struct {
+		Code string `json:"code"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		if err := requireSession(c); err != nil {
+			return err
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		codes, err := u.EnableTOTP(request.Code, time.Now())
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "EnableTOTP"))
+		}
+
+		if err := u.UpdateTwoFactor(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UpdateTwoFactor"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+
+		return nil
+	}
+}
+
+// handleDeleteTOTP disables two factor authentication, it needs a current
+// code and is refused while an organisation requires it
+func (s Server) handleDeleteTOTP() HandlerFunc {
+	type Request struct {
+		Code string `json:"code"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		if err := requireSession(c); err != nil {
+			return err
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		required, err := org.TwoFactorRequired(s.db, u.ID)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "TwoFactorRequired"))
+		}
+
+		if required {
+			return newApiError(http.StatusBadRequest, "Two factor authentication is required by an organisation", errors.Errorf("user %d disabling required two factor", u.ID))
+		}
+
+		ok, err := u.UseTwoFactor(s.db, request.Code, time.Now())
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UseTwoFactor"))
+		}
+
+		if !ok {
+			return newApiError(http.StatusBadRequest, "Code is invalid", errors.Errorf("user %d invalid code", u.ID))
+		}
+
+		u.DisableTOTP()
+
+		if err := u.UpdateTwoFactor(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UpdateTwoFactor"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+
+		return nil
+	}
+}
+
+// handlePostRecoveryCodes replaces the recovery codes, it needs a current
+// code
+func (s Server) handlePostRecoveryCodes() HandlerFunc {
+	type Request struct {
+		Code string `json:"code"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		if err := requireSession(c); err != nil {
+			return err
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		ok, err := u.UseTwoFactor(s.db, request.Code, time.Now())
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UseTwoFactor"))
+		}
+
+		if !ok {
+			return newApiError(http.StatusBadRequest, "Code is invalid", errors.Errorf("user %d invalid code", u.ID))
+		}
+
+		codes := u.RegenerateRecoveryCodes()
+
+		// only the codes are stored so a TOTP step used by a concurrent
+		// request is not undone
+		if err := u.UpdateRecoveryCodes(s.db); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "UpdateRecoveryCodes"))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+
+		return nil
+	}
+}
+
+// handlePutOrganisationTwoFactor sets whether members must use two factor
+// authentication, the admin making the change must already use it
+func (s Server) handlePutOrganisationTwoFactor() OrganisationHandlerFunc {
+	type Request struct {
+		Required bool `json:"required"`
+	}
+
+	return func(m org.Member, u user.User, c *gin.Context) error {
+		if err := requireSession(c); err != nil {
+			return err
+		}
+
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		if request.Required && !u.TwoFactorEnabled() {
+			return newApiError(http.StatusBadRequest, "Enable two factor authentication first", errors.Errorf("user %d requiring two factor without it", u.ID))
+		}
+
+		m.Organisation.RequireTwoFactor = request.Required
+
+		if _, err := s.db.Model(&m.Organisation).Set("require_two_factor = ?require_two_factor").WherePK().Update(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Update"))
+		}
+
+		c.JSON(http.StatusOK, &m.Organisation)
+
+		return nil
+	}
+}
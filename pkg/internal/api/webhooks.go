@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
+	"github.com/pkg/errors"
+)
+
+// number of deliveries returned in a webhook's log
+const webhookDeliveryLimit = 100
+
+func (s Server) handleGetWebhooks() HandlerFunc {
+	return func(u user.User, c *gin.Context) error {
+		webhooks := make([]webhook.Webhook, 0)
+
+		err := s.db.Model(&webhooks).
+			Where("owner_id = ? AND organisation_id = ?", u.ID, organisationID(c)).
+			Order("added_at").
+			Select()
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Select"))
+		}
+
+		c.JSON(http.StatusOK, &webhooks)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostWebhook() HandlerFunc {
+	type Request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	return func(u user.User, c *gin.Context) error {
+		var request Request
+
+		if err := c.ShouldBind(&request); err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", errors.Wrap(err, "ShouldBind"))
+		}
+
+		w, err := webhook.NewWebhook(u, request.URL, request.Events)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, err.Error(), errors.WithMessage(err, "NewWebhook"))
+		}
+
+		w.OrganisationID = organisationID(c)
+
+		if _, err := s.db.Model(&w).Insert(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Inserting", errors.Wrap(err, "Insert"))
+		}
+
+		// the secret is only shown once
+		c.JSON(http.StatusCreated, struct {
+			webhook.Webhook
+			Secret string `json:"secret"`
+		}{w, w.Secret})
+
+		return nil
+	}
+}
+
+// WebhookHandlerFunc is called with a webhook belonging to the user
+type WebhookHandlerFunc func(webhook.Webhook, user.User, *gin.Context) error
+
+func (s Server) handleWebhook(fn WebhookHandlerFunc) gin.HandlerFunc {
+	return s.handleUser(func(u user.User, c *gin.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return newApiError(http.StatusBadRequest, "Bad Request", err)
+		}
+
+		w, err := webhook.GetWebhook(s.db, u.ID, organisationID(c), id)
+		if err != nil {
+			return newApiError(http.StatusNotFound, "Not found", errors.WithMessage(err, "GetWebhook"))
+		}
+
+		return fn(w, u, c)
+	})
+}
+
+func (s Server) handleDeleteWebhook() WebhookHandlerFunc {
+	return func(w webhook.Webhook, u user.User, c *gin.Context) error {
+		if _, err := s.db.Model(&w).WherePK().Delete(); err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.Wrap(err, "Delete"))
+		}
+
+		c.JSON(http.StatusOK, nil)
+
+		return nil
+	}
+}
+
+func (s Server) handleGetWebhookDeliveries() WebhookHandlerFunc {
+	return func(w webhook.Webhook, u user.User, c *gin.Context) error {
+		deliveries, err := w.GetDeliveries(s.db, webhookDeliveryLimit)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "GetDeliveries"))
+		}
+
+		c.JSON(http.StatusOK, &deliveries)
+
+		return nil
+	}
+}
+
+func (s Server) handlePostWebhookPing() WebhookHandlerFunc {
+	return func(w webhook.Webhook, u user.User, c *gin.Context) error {
+		event, err := w.Ping(s.db)
+		if err != nil {
+			return newApiError(http.StatusInternalServerError, "Internal Server Error", errors.WithMessage(err, "Ping"))
+		}
+
+		c.JSON(http.StatusAccepted, &event)
+
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+// Package audit keeps an append-only log of the changes users make through
+// the API and operators make with CLI commands.
+package audit
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+// Source is where a change was made
+type Source = string
+
+const (
+	SourceAPI Source = "api"
+	SourceCLI Source = "cli"
+)
+
+const (
+	// entries returned by Find when no limit is given
+	defaultLimit = 100
+	// most entries Find returns at once
+	maxLimit = 1000
+)
+
+// Entry is a change made to an account. There are no foreign keys so
+// entries outlive the users and domains they refer to
+type Entry struct {
+	tableName struct{} `pg:"audit_entries,alias:audit_entry"`
+
+	ID int `pg:",pk" json:"id"`
+
+	Source Source `pg:",notnull,type:text" json:"source"`
+
+	// the user that made the change, 0 for CLI commands
+	ActorID int `pg:",notnull,use_zero" json:"actor_id"`
+	// the actor's email, or the operating system user for CLI commands
+	Actor string `pg:",notnull,use_zero" json:"actor"`
+	// the API token the change was made with, if any
+	TokenID int    `pg:",notnull,use_zero" json:"token_id,omitempty"`
+	IP      string `pg:",notnull,use_zero" json:"ip,omitempty"`
+
+	// the account and organisation that was changed
+	AccountID      int `pg:",notnull,use_zero" json:"account_id"`
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	// what was done, for the API this is the method and route
+	Action string `pg:",notnull" json:"action"`
+
+	// the domain that was changed, if any
+	DomainID int    `pg:",notnull,use_zero" json:"domain_id,omitempty"`
+	Domain   string `pg:",notnull,use_zero" json:"domain,omitempty"`
+
+	// ids from the route and anything else the action recorded
+	Details map[string]interface{} `pg:",notnull,use_zero" json:"details"`
+
+	CreatedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+}
+
+// Insert appends the entry to the log
+func (e *Entry) Insert(db orm.DB) error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	_, err := db.Model(e).Insert()
+	return err
+}
+
+// Protect stops entries being changed or removed once they are written
+// and indexes the table for Find, it is safe to call more than once
+func Protect(db orm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_entries is append only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries`,
+		`CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+			FOR EACH ROW EXECUTE PROCEDURE audit_entries_append_only()`,
+		`DROP TRIGGER IF EXISTS audit_entries_no_truncate ON audit_entries`,
+		`CREATE TRIGGER audit_entries_no_truncate BEFORE TRUNCATE ON audit_entries
+			FOR EACH STATEMENT EXECUTE PROCEDURE audit_entries_append_only()`,
+		`CREATE INDEX IF NOT EXISTS audit_entries_account_idx
+			ON audit_entries (account_id, organisation_id, created_at)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return errors.Wrap(err, "Exec")
+		}
+	}
+
+	return nil
+}
+
+// Filter selects entries for an account and organisation
+type Filter struct {
+	AccountID      int
+	OrganisationID int
+
+	// only changes made by the user with the id or email
+	ActorID int
+	Actor   string
+
+	// only changes to the domain
+	Domain string
+
+	// only changes made in the range, either can be zero
+	From time.Time
+	To   time.Time
+
+	// only entries older than the entry with this id, used to page
+	Before int
+
+	Limit int
+}
+
+// Normalise checks the range and defaults or caps the limit
+func (f *Filter) Normalise() error {
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return errors.New("to is before from")
+	}
+
+	if f.Limit < 0 {
+		return errors.New("limit is negative")
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultLimit
+	}
+
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+
+	return nil
+}
+
+// Find returns the entries matching the filter, newest first
+func Find(db orm.DB, f Filter) ([]Entry, error) {
+	if err := f.Normalise(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0)
+
+	query := db.Model(&entries).
+		Where("account_id = ? AND organisation_id = ?", f.AccountID, f.OrganisationID)
+
+	if f.ActorID > 0 {
+		query = query.Where("actor_id = ?", f.ActorID)
+	}
+
+	if len(f.Actor) > 0 {
+		query = query.Where("lower(actor) = lower(?)", f.Actor)
+	}
+
+	if len(f.Domain) > 0 {
+		query = query.Where("domain = ?", f.Domain)
+	}
+
+	if !f.From.IsZero() {
+		query = query.Where("created_at >= ?", f.From)
+	}
+
+	if !f.To.IsZero() {
+		query = query.Where("created_at < ?", f.To)
+	}
+
+	if f.Before > 0 {
+		query = query.Where("id < ?", f.Before)
+	}
+
+	err := query.
+		Order("id DESC").
+		Limit(f.Limit).
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "Select")
+	}
+
+	return entries, nil
+}
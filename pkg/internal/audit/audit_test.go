@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_FilterNormalise(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type tcase struct {
+		filter   Filter
+		expected int
+		err      bool
+	}
+
+	tests := []tcase{
+		{Filter{}, defaultLimit, false},
+		{Filter{Limit: 10}, 10, false},
+		{Filter{Limit: maxLimit + 1}, maxLimit, false},
+		{Filter{Limit: -1}, 0, true},
+		{Filter{From: now, To: now.Add(time.Hour)}, defaultLimit, false},
+		{Filter{From: now}, defaultLimit, false},
+		{Filter{To: now}, defaultLimit, false},
+		{Filter{From: now, To: now}, defaultLimit, false},
+		{Filter{From: now, To: now.Add(-time.Hour)}, 0, true},
+	}
+
+	for i, tc := range tests {
+		err := tc.filter.Normalise()
+		if tc.err {
+			if err == nil {
+				t.Errorf("%d: Normalise() expected an error got nil", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: Normalise() expected nil got %q", i, err)
+			continue
+		}
+
+		if tc.filter.Limit != tc.expected {
+			t.Errorf("%d: Normalise() expected limit %d got %d", i, tc.expected, tc.filter.Limit)
+		}
+	}
+}
@@ -11,6 +11,26 @@ type Client interface {
 	GetLive(dom domain.Domain, stored domain.Records) (domain.Records, error)
 }
 
+// Progress is called as live records are gathered with the stage, a
+// message and a count relevant to the stage
+type Progress func(stage, message string, count int)
+
+const (
+	// authoritative nameservers have been found, count is the number found
+	StageNameservers = "nameservers"
+	// a target has been scanned, count is the number of records found so far
+	StageTarget = "target"
+)
+
+// ProgressClient is a Client that can also report its progress
+type ProgressClient interface {
+	Client
+
+	// GetLiveProgress is the same as GetLive but calls progress as it
+	// goes, progress can be nil
+	GetLiveProgress(dom domain.Domain, stored domain.Records, progress Progress) (domain.Records, error)
+}
+
 type DNSClient struct {
 	dns.Client
 }
@@ -37,6 +37,10 @@ var (
 )
 
 func (c *DNSClient) queryIterate(dom domain.Domain, nameservers, targets []string) (domain.Records, error) {
+	return c.iterate(dom, nameservers, targets, nil)
+}
+
+func (c *DNSClient) iterate(dom domain.Domain, nameservers, targets []string, progress Progress) (domain.Records, error) {
 	cache := make(map[string]struct{})
 	for _, t := range targets {
 		cache[t] = struct{}{}
@@ -145,6 +149,10 @@ func (c *DNSClient) queryIterate(dom domain.Domain, nameservers, targets []strin
 				}
 			}
 		}
+
+		if progress != nil {
+			progress(StageTarget, tar, len(records))
+		}
 	}
 
 	return records, nil
@@ -35,12 +35,21 @@ var (
 
 // look at stored records and check for any deltas
 func (c DNSClient) GetLive(dom domain.Domain, stored domain.Records) (domain.Records, error) {
+	return c.GetLiveProgress(dom, stored, nil)
+}
+
+// look at stored records and check for any deltas, reporting progress
+func (c DNSClient) GetLiveProgress(dom domain.Domain, stored domain.Records, progress Progress) (domain.Records, error) {
 	// get authority server for our queries
 	nameservers, err := c.getNameservers(dom.Domain)
 	if err != nil {
 		return nil, errors.WithMessage(err, "getNameserver")
 	}
 
+	if progress != nil {
+		progress(StageNameservers, strings.Join(nameservers, ", "), len(nameservers))
+	}
+
 	// create a list of targets we want to check against
 	for _, r := range stored {
 		name := strings.Replace(r.Name, dns.Fqdn(dom.Domain), "", -1)
@@ -60,7 +69,7 @@ func (c DNSClient) GetLive(dom domain.Domain, stored domain.Records) (domain.Rec
 	var live domain.Records
 
 	for i := 0; i < 10; i++ {
-		live, err = c.queryIterate(dom, nameservers, subdomainsToCheck)
+		live, err = c.iterate(dom, nameservers, subdomainsToCheck, progress)
 		if err != nil {
 			if strings.Contains(err.Error(), "timeout") {
 				time.Sleep(time.Millisecond * 500)
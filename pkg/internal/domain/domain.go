@@ -18,9 +18,21 @@ type Domain struct {
 	OwnerID int       `pg:",notnull,unique:domain_owner_id" json:"owner_id"`
 	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
 
+	// set when the domain belongs to an organisation, OwnerID is then the
+	// organisation's owner
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	// groups the domain belongs to, see Group
+	Tags []string `pg:",use_zero" json:"tags"`
+
 	// settings
 	DontBatch bool `pg:",notnull,use_zero" json:"dont_batch"`
 
+	// interval ("24h") or cron expression ("*/5 * * * *"), empty uses
+	// the defaults
+	ScanSchedule  string `pg:",notnull,use_zero" json:"scan_schedule"`
+	WhoisSchedule string `pg:",notnull,use_zero" json:"whois_schedule"`
+
 	// meta data
 	AddedAt   time.Time   `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
 	DeletedAt pg.NullTime `pg:",type:timestamptz,soft_delete" json:"deleted_at"`
@@ -28,6 +40,11 @@ type Domain struct {
 	// when was this domain last updated, useful for starting jobs
 	LastJobAt     time.Time `pg:",type:timestamptz" json:"last_job_at"`
 	LastUpdatedAt time.Time `pg:",type:timestamptz" json:"last_updated_at"`
+	LastWhoisAt   time.Time `pg:",type:timestamptz" json:"last_whois_at"`
+
+	// when the domain is next due a scan, NULL when it has to be worked
+	// out again after a scan or a change to its schedule
+	NextScanAt time.Time `pg:",type:timestamptz" json:"-"`
 }
 
 // create a new domain attached to an owner
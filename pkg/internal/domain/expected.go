@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/miekg/dns"
+)
+
+// ExpectedRecord is a record that the owner has declared should exist on
+// the domain. If Exclusive is set then no records other than the expected
+// ones are allowed to exist for the same name.
+type ExpectedRecord struct {
+	ID int `pg:",pk" json:"id"`
+
+	// parent data
+	DomainID int    `pg:",notnull,unique:expected_domain_id_hash" json:"domain_id"`
+	Domain   Domain `pg:"fk:domain_id,rel:has-one" json:"-"`
+
+	// textual representaion of the record
+	Raw string `pg:",notnull" json:"raw"`
+
+	Fields string     `pg:",notnull" json:"fields"`
+	Name   string     `pg:",notnull" json:"name"`
+	RRType JsonRRType `pg:",notnull" json:"rr_type"`
+
+	// uses the same hash as Record so we can compare directly
+	Hash uint32 `pg:",notnull,unique:expected_domain_id_hash" json:"hash"`
+
+	// no records other than the expected ones for this name
+	Exclusive bool `pg:",notnull,use_zero" json:"exclusive"`
+
+	// meta data
+	AddedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+}
+
+// helper type
+type ExpectedRecords []ExpectedRecord
+
+// convert a dns.RR to an ExpectedRecord
+func NewExpectedRecord(domain Domain, rr dns.RR, exclusive bool) ExpectedRecord {
+	record := NewRecord(domain, rr, RecordSourceManual)
+
+	return ExpectedRecord{
+		DomainID:  domain.ID,
+		Domain:    domain,
+		Raw:       record.Raw,
+		Fields:    record.Fields,
+		Name:      record.Name,
+		RRType:    record.RRType,
+		Hash:      record.Hash,
+		Exclusive: exclusive,
+	}
+}
+
+// insert an expected record in to the database
+func (e *ExpectedRecord) Insert(db orm.DB) error {
+	_, err := db.Model(e).
+		OnConflict("(domain_id, hash) DO UPDATE").
+		Set("exclusive = EXCLUDED.exclusive").
+		Returning("*").
+		Insert()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// get all expected records for a domain
+func (d Domain) GetExpectedRecords(db orm.DB) (ExpectedRecords, error) {
+	var expected ExpectedRecords
+	err := db.Model(&expected).
+		Where("domain_id = ?", d.ID).
+		Order("name", "rr_type").
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+
+	return expected, nil
+}
+
+// Drift describes how the current records differ from the expected
+// records of a domain
+type Drift struct {
+	// expected records that were not found
+	Missing ExpectedRecords `json:"missing"`
+	// records found for an exclusive name that were not expected
+	Unexpected Records `json:"unexpected"`
+}
+
+// true if there is no drift
+func (d Drift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0
+}
+
+// Lines returns a stable, sorted textual representation of the drift
+// that can be stored and compared between jobs
+func (d Drift) Lines() []string {
+	lines := make([]string, 0, len(d.Missing)+len(d.Unexpected))
+
+	for _, e := range d.Missing {
+		lines = append(lines, fmt.Sprintf("missing\t%s", e.Raw))
+	}
+
+	for _, r := range d.Unexpected {
+		lines = append(lines, fmt.Sprintf("unexpected\t%s", r.Raw))
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+func driftKey(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
+
+// CompareExpected checks the current records against the expected
+// records and returns any drift
+func CompareExpected(expected ExpectedRecords, current Records) Drift {
+	drift := Drift{
+		Missing:    make(ExpectedRecords, 0),
+		Unexpected: make(Records, 0),
+	}
+
+	found := make(map[uint32]struct{}, len(current))
+	for _, r := range current {
+		found[r.Hash] = struct{}{}
+	}
+
+	wanted := make(map[uint32]struct{}, len(expected))
+	exclusive := make(map[string]struct{})
+
+	for _, e := range expected {
+		wanted[e.Hash] = struct{}{}
+
+		if e.Exclusive {
+			exclusive[driftKey(e.Name)] = struct{}{}
+		}
+
+		if _, ok := found[e.Hash]; !ok {
+			drift.Missing = append(drift.Missing, e)
+		}
+	}
+
+	for _, r := range current {
+		if _, ok := exclusive[driftKey(r.Name)]; !ok {
+			continue
+		}
+		if _, ok := wanted[r.Hash]; !ok {
+			drift.Unexpected = append(drift.Unexpected, r)
+		}
+	}
+
+	return drift
+}
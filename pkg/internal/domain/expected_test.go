@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func mustCreateRR(t *testing.T, raw string) dns.RR {
+	t.Helper()
+
+	rr, err := dns.NewRR(raw)
+	if err != nil {
+		t.Fatalf("NewRR() unexpected error: %s", err)
+	}
+	return rr
+}
+
+func Test_CompareExpectedNoDrift(t *testing.T) {
+	t.Parallel()
+
+	dom := Domain{ID: 1, Domain: "whois.bi"}
+
+	expected := ExpectedRecords{
+		NewExpectedRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), true),
+	}
+
+	current := Records{
+		NewRecord(dom, mustCreateRR(t, "whois.bi.	300	IN	MX	10 ehlo.mx.ax."), RecordSourceIterate),
+		NewRecord(dom, mustCreateRR(t, "www.whois.bi.	300	IN	CNAME	traefik.jl.lu."), RecordSourceIterate),
+	}
+
+	drift := CompareExpected(expected, current)
+	if !drift.Empty() {
+		t.Fatalf("CompareExpected() expected no drift got %v", drift.Lines())
+	}
+}
+
+func Test_CompareExpectedMissing(t *testing.T) {
+	t.Parallel()
+
+	dom := Domain{ID: 1, Domain: "whois.bi"}
+
+	expected := ExpectedRecords{
+		NewExpectedRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), false),
+		NewExpectedRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	20 helo.mx.ax."), false),
+	}
+
+	current := Records{
+		NewRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), RecordSourceIterate),
+		NewRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	30 other.mx.ax."), RecordSourceIterate),
+	}
+
+	drift := CompareExpected(expected, current)
+
+	if len(drift.Missing) != 1 {
+		t.Fatalf("expected 1 missing got %d", len(drift.Missing))
+	}
+
+	if drift.Missing[0].Hash != expected[1].Hash {
+		t.Errorf("expected %q to be missing got %q", expected[1].Raw, drift.Missing[0].Raw)
+	}
+
+	// not exclusive so extras are allowed
+	if len(drift.Unexpected) != 0 {
+		t.Fatalf("expected 0 unexpected got %d", len(drift.Unexpected))
+	}
+}
+
+func Test_CompareExpectedExclusive(t *testing.T) {
+	t.Parallel()
+
+	dom := Domain{ID: 1, Domain: "whois.bi"}
+
+	expected := ExpectedRecords{
+		NewExpectedRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), true),
+	}
+
+	current := Records{
+		NewRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), RecordSourceIterate),
+		NewRecord(dom, mustCreateRR(t, "whois.bi.	43200	IN	MX	30 other.mx.ax."), RecordSourceIterate),
+		NewRecord(dom, mustCreateRR(t, `whois.bi.	43200	IN	TXT	"v=spf1 include:spf.mx.ax ~all"`), RecordSourceIterate),
+		NewRecord(dom, mustCreateRR(t, "www.whois.bi.	300	IN	CNAME	traefik.jl.lu."), RecordSourceIterate),
+	}
+
+	drift := CompareExpected(expected, current)
+
+	if len(drift.Missing) != 0 {
+		t.Fatalf("expected 0 missing got %d", len(drift.Missing))
+	}
+
+	// the MX and TXT share the exclusive name, www does not
+	if len(drift.Unexpected) != 2 {
+		t.Fatalf("expected 2 unexpected got %d", len(drift.Unexpected))
+	}
+
+	lines := drift.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines got %d", len(lines))
+	}
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// maximum tags a domain can have
+const maxTags = 20
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+
+// ValidTag returns true if the tag can be used to group domains
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// NormaliseTags lower cases, sorts and removes duplicate tags, returning an
+// error if any are invalid
+func NormaliseTags(tags []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(tags))
+	out := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if !ValidTag(tag) {
+			return nil, errors.Errorf("invalid tag %q", tag)
+		}
+
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+
+		out = append(out, tag)
+	}
+
+	if len(out) > maxTags {
+		return nil, errors.Errorf("a domain can have at most %d tags", maxTags)
+	}
+
+	sort.Strings(out)
+
+	return out, nil
+}
+
+// HasTag returns true if the domain is in the group
+func (d Domain) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Group holds settings shared by every domain with the same tag
+type Group struct {
+	tableName struct{} `pg:"domain_groups,alias:domain_group"`
+
+	ID int `pg:",pk" json:"id"`
+
+	OwnerID int       `pg:",notnull,unique:domain_group_owner_id_tag" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	// 0 for groups in the owner's own account
+	OrganisationID int `pg:",notnull,use_zero,unique:domain_group_owner_id_tag" json:"organisation_id"`
+
+	Tag string `pg:",notnull,unique:domain_group_owner_id_tag" json:"tag"`
+
+	// used by domains in the group without their own schedules
+	ScanSchedule  string `pg:",notnull,use_zero" json:"scan_schedule"`
+	WhoisSchedule string `pg:",notnull,use_zero" json:"whois_schedule"`
+
+	AddedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+}
+
+// GetGroups returns the owner's groups in the organisation, 0 for the
+// owner's own account, ordered by tag
+func GetGroups(db orm.DB, ownerID, organisationID int) ([]Group, error) {
+	groups := make([]Group, 0)
+	err := db.Model(&groups).
+		Where("owner_id = ? AND organisation_id = ?", ownerID, organisationID).
+		Order("tag").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// inherit fills in any schedules the domain does not set from the groups
+// in its account or organisation, in tag order
+func (d *Domain) inherit(groups []Group) {
+	for _, g := range groups {
+		if g.OwnerID != d.OwnerID || g.OrganisationID != d.OrganisationID || !d.HasTag(g.Tag) {
+			continue
+		}
+
+		if len(d.ScanSchedule) == 0 {
+			d.ScanSchedule = g.ScanSchedule
+		}
+
+		if len(d.WhoisSchedule) == 0 {
+			d.WhoisSchedule = g.WhoisSchedule
+		}
+	}
+}
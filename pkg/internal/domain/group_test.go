@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_NormaliseTags(t *testing.T) {
+	t.Parallel()
+
+	tags, err := NormaliseTags([]string{" Prod", "eu-west", "prod", "mail.relay"})
+	if err != nil {
+		t.Fatalf("NormaliseTags() expected nil got %q", err)
+	}
+
+	expected := []string{"eu-west", "mail.relay", "prod"}
+	if len(tags) != len(expected) {
+		t.Fatalf("NormaliseTags() expected %v got %v", expected, tags)
+	}
+
+	for i := range expected {
+		if tags[i] != expected[i] {
+			t.Fatalf("NormaliseTags() expected %v got %v", expected, tags)
+		}
+	}
+
+	for _, invalid := range []string{"", "-prod", "has space", "a/b"} {
+		if _, err := NormaliseTags([]string{invalid}); err == nil {
+			t.Errorf("NormaliseTags(%q) expected an error got nil", invalid)
+		}
+	}
+
+	many := make([]string, 0, maxTags+1)
+	for i := 0; i <= maxTags; i++ {
+		many = append(many, string(rune('a'+i)))
+	}
+
+	if _, err := NormaliseTags(many); err == nil {
+		t.Error("NormaliseTags() expected an error got nil")
+	}
+}
+
+func Test_InheritSchedules(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	groups := []Group{
+		{OwnerID: 1, Tag: "critical", ScanSchedule: "5m"},
+		{OwnerID: 1, Tag: "prod", ScanSchedule: "1h", WhoisSchedule: "12h"},
+		{OwnerID: 2, Tag: "staging", ScanSchedule: "1m"},
+		{OwnerID: 1, OrganisationID: 3, Tag: "prod", ScanSchedule: "1m", WhoisSchedule: "1h"},
+	}
+
+	d := Domain{OwnerID: 1, Tags: []string{"critical", "prod"}, LastJobAt: now.Add(time.Minute * -5)}
+	d.inherit(groups)
+
+	if d.ScanSchedule != "5m" || d.WhoisSchedule != "12h" {
+		t.Fatalf("unexpected schedules %q %q", d.ScanSchedule, d.WhoisSchedule)
+	}
+
+	if !d.ScanDue(now) {
+		t.Error("ScanDue() expected true got false")
+	}
+
+	// the domain's own schedule wins
+	own := Domain{OwnerID: 1, Tags: []string{"prod"}, ScanSchedule: "2h"}
+	own.inherit(groups)
+
+	if own.ScanSchedule != "2h" || own.WhoisSchedule != "12h" {
+		t.Fatalf("unexpected schedules %q %q", own.ScanSchedule, own.WhoisSchedule)
+	}
+
+	// groups belong to their owner
+	other := Domain{OwnerID: 1, Tags: []string{"staging"}}
+	other.inherit(groups)
+
+	if len(other.ScanSchedule) > 0 {
+		t.Fatalf("unexpected schedule %q", other.ScanSchedule)
+	}
+
+	// and to their organisation
+	shared := Domain{OwnerID: 1, OrganisationID: 3, Tags: []string{"critical", "prod"}}
+	shared.inherit(groups)
+
+	if shared.ScanSchedule != "1m" || shared.WhoisSchedule != "1h" {
+		t.Fatalf("unexpected schedules %q %q", shared.ScanSchedule, shared.WhoisSchedule)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/miekg/dns"
 )
 
@@ -56,7 +57,7 @@ type Record struct {
 type Records []Record
 
 // insert all records
-func (r *Records) Insert(db *pg.DB) error {
+func (r *Records) Insert(db orm.DB) error {
 	if len(*r) == 0 {
 		return nil
 	}
@@ -70,7 +71,7 @@ func (r *Records) Insert(db *pg.DB) error {
 	return nil
 }
 
-func (r *Records) Remove(db *pg.DB) error {
+func (r *Records) Remove(db orm.DB) error {
 	if len(*r) == 0 {
 		return nil
 	}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/schedule"
+	"github.com/pkg/errors"
+)
+
+const (
+	// used when a domain has no scan schedule
+	DefaultScanSchedule = "24h"
+	// used when a domain has no whois schedule
+	DefaultWhoisSchedule = "24h"
+)
+
+// parse the spec falling back to the default if it is empty or invalid
+func parseSchedule(spec, fallback string) schedule.Schedule {
+	if s, err := schedule.Parse(spec); err == nil {
+		return s
+	}
+
+	s, err := schedule.Parse(fallback)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func due(spec, fallback string, last, now time.Time) bool {
+	if last.IsZero() {
+		return true
+	}
+
+	next := parseSchedule(spec, fallback).Next(last)
+
+	return !next.IsZero() && !next.After(now)
+}
+
+// ScanDue returns true if the domain should be scanned at now
+func (d Domain) ScanDue(now time.Time) bool {
+	return due(d.ScanSchedule, DefaultScanSchedule, d.LastJobAt, now)
+}
+
+// NextScan returns when the domain is next due a scan, zero if it has never
+// been scanned or its schedule never runs again
+func (d Domain) NextScan() time.Time {
+	if d.LastJobAt.IsZero() {
+		return time.Time{}
+	}
+	return parseSchedule(d.ScanSchedule, DefaultScanSchedule).Next(d.LastJobAt)
+}
+
+// WhoisDue returns true if the domain's whois should be looked up at now
+func (d Domain) WhoisDue(now time.Time) bool {
+	return due(d.WhoisSchedule, DefaultWhoisSchedule, d.LastWhoisAt, now)
+}
+
+// ValidateSchedules checks both schedules parse and respect the provided
+// minimums, empty schedules use the defaults
+func ValidateSchedules(scan, whois string, minScan, minWhois time.Duration) error {
+	if len(scan) > 0 {
+		if err := schedule.Validate(scan, minScan); err != nil {
+			return errors.WithMessage(err, "scan schedule")
+		}
+	}
+
+	if len(whois) > 0 {
+		if err := schedule.Validate(whois, minWhois); err != nil {
+			return errors.WithMessage(err, "whois schedule")
+		}
+	}
+
+	return nil
+}
+
+// ResetNextScan makes the domains with the owner's tag in the organisation
+// work out when they are next due, used when a group's schedule changes
+func ResetNextScan(db orm.DB, ownerID, organisationID int, tag string) error {
+	_, err := db.Model((*Domain)(nil)).
+		Set("next_scan_at = NULL").
+		Where("owner_id = ? AND organisation_id = ? AND tags @> ?::jsonb", ownerID, organisationID, []string{tag}).
+		Update()
+	return err
+}
+
+// get domains that are due a scan at now, domains without their own
+// schedules use their groups' schedules. Only domains whose next scan has
+// passed or is not known are loaded, those that turn out not to be due have
+// their next scan stored
+func GetDomainsDue(db orm.DB, now time.Time) ([]Domain, error) {
+	var domains []Domain
+	if err := db.Model(&domains).Where("next_scan_at IS NULL OR next_scan_at <= ?", now).Select(); err != nil {
+		return nil, err
+	}
+
+	if len(domains) == 0 {
+		return domains, nil
+	}
+
+	owners := make([]int, 0, len(domains))
+	for _, d := range domains {
+		owners = append(owners, d.OwnerID)
+	}
+
+	var groups []Group
+	if err := db.Model(&groups).Where("owner_id IN (?)", pg.In(owners)).Order("tag").Select(); err != nil {
+		return nil, err
+	}
+
+	byOwner := make(map[int][]Group)
+	for _, g := range groups {
+		byOwner[g.OwnerID] = append(byOwner[g.OwnerID], g)
+	}
+
+	due := make([]Domain, 0, len(domains))
+	for _, d := range domains {
+		d.inherit(byOwner[d.OwnerID])
+
+		if d.ScanDue(now) {
+			due = append(due, d)
+			continue
+		}
+
+		next := d.NextScan()
+		if next.IsZero() {
+			continue
+		}
+
+		_, err := db.Model((*Domain)(nil)).
+			Set("next_scan_at = ?", next).
+			Where("id = ?", d.ID).
+			Update()
+		if err != nil {
+			return nil, errors.Wrap(err, "Update next_scan_at")
+		}
+	}
+
+	return due, nil
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ScanDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.March, 1, 10, 7, 0, 0, time.UTC)
+
+	type tcase struct {
+		name     string
+		dom      Domain
+		expected bool
+	}
+
+	cases := []tcase{
+		tcase{"never scanned", Domain{}, true},
+		tcase{"default not due", Domain{LastJobAt: now.Add(time.Hour * -23)}, false},
+		tcase{"default due", Domain{LastJobAt: now.Add(time.Hour * -24)}, true},
+		tcase{"interval due", Domain{ScanSchedule: "5m", LastJobAt: now.Add(time.Minute * -5)}, true},
+		tcase{"interval not due", Domain{ScanSchedule: "5m", LastJobAt: now.Add(time.Minute * -4)}, false},
+		tcase{"cron due", Domain{ScanSchedule: "0 * * * *", LastJobAt: now.Add(time.Minute * -8)}, true},
+		tcase{"cron not due", Domain{ScanSchedule: "0 * * * *", LastJobAt: now.Add(time.Minute * -6)}, false},
+		tcase{"invalid uses default", Domain{ScanSchedule: "nope", LastJobAt: now.Add(time.Hour * -1)}, false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.dom.ScanDue(now); got != tc.expected {
+			t.Errorf("%s: ScanDue() expected %t got %t", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_ValidateSchedules(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidateSchedules("", "", time.Hour, time.Hour); err != nil {
+		t.Errorf("ValidateSchedules() expected nil got %q", err)
+	}
+
+	if err := ValidateSchedules("*/5 * * * *", "@daily", time.Minute*5, time.Hour); err != nil {
+		t.Errorf("ValidateSchedules() expected nil got %q", err)
+	}
+
+	if err := ValidateSchedules("1m", "", time.Minute*5, time.Hour); err == nil {
+		t.Error("ValidateSchedules() expected an error got nil")
+	}
+
+	if err := ValidateSchedules("", "5m", time.Minute*5, time.Hour); err == nil {
+		t.Error("ValidateSchedules() expected an error got nil")
+	}
+}
+
+func Test_NextScan(t *testing.T) {
+	t.Parallel()
+
+	last := time.Date(2021, time.March, 1, 10, 7, 0, 0, time.UTC)
+
+	type tcase struct {
+		name     string
+		dom      Domain
+		expected time.Time
+	}
+
+	cases := []tcase{
+		tcase{"never scanned", Domain{}, time.Time{}},
+		tcase{"default", Domain{LastJobAt: last}, last.Add(time.Hour * 24)},
+		tcase{"interval", Domain{ScanSchedule: "5m", LastJobAt: last}, last.Add(time.Minute * 5)},
+		tcase{"cron", Domain{ScanSchedule: "0 * * * *", LastJobAt: last}, time.Date(2021, time.March, 1, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		if got := tc.dom.NextScan(); !got.Equal(tc.expected) {
+			t.Errorf("%s: NextScan() expected %s got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_ResetNextScan(t *testing.T) {
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	o := createOwner(t, tx)
+
+	next := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tagged := createDomain(t, tx, o, "tagged.com")
+	other := createDomain(t, tx, o, "other.com")
+
+	for _, d := range []Domain{tagged, other} {
+		tags := []string{"staging"}
+		if d.ID == tagged.ID {
+			tags = []string{"critical", "prod"}
+		}
+
+		_, err := tx.Model((*Domain)(nil)).
+			Set("tags = ?, next_scan_at = ?", tags, next).
+			Where("id = ?", d.ID).
+			Update()
+		if err != nil {
+			t.Fatalf("Update() expected nil got %q", err)
+		}
+	}
+
+	if err := ResetNextScan(tx, o.ID, 0, "prod"); err != nil {
+		t.Fatalf("ResetNextScan() expected nil got %q", err)
+	}
+
+	for _, d := range []Domain{tagged, other} {
+		var stored Domain
+		if err := tx.Model(&stored).Where("id = ?", d.ID).Select(); err != nil {
+			t.Fatalf("Select() expected nil got %q", err)
+		}
+
+		if d.ID == tagged.ID && !stored.NextScanAt.IsZero() {
+			t.Errorf("ResetNextScan() expected %s to be reset got %s", d.Domain, stored.NextScanAt)
+		}
+
+		if d.ID == other.ID && stored.NextScanAt.IsZero() {
+			t.Errorf("ResetNextScan() expected %s to keep its next scan", d.Domain)
+		}
+	}
+}
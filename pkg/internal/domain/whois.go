@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/likexian/whois-go"
 	whoisparser "github.com/likexian/whois-parser"
 	"github.com/pkg/errors"
@@ -96,7 +96,7 @@ func NewWhois(domain Domain) (Whois, error) {
 }
 
 // insert a whois record
-func (w *Whois) Insert(db *pg.DB) error {
+func (w *Whois) Insert(db orm.DB) error {
 	_, err := db.Model(w).Returning("*").OnConflict("DO NOTHING").Insert()
 	if err != nil {
 		return err
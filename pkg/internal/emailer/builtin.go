@@ -0,0 +1,138 @@
+package emailer
+
+// built in templates, see Templates for how to override them
+var builtin = map[string]string{
+	"verify.txt": `{{define "subject"}}Please verify your account{{end -}}
+Thank you for registering with us. Please complete your registration by visiting:
+
+{{url "/verify/" .Code}}
+`,
+
+	"verify.html": `<!DOCTYPE html>
+<html>
+<body>
+<p>Thank you for registering with us. Please complete your registration by clicking <a href="{{url "/verify/" .Code}}">here</a>.</p>
+</body>
+</html>
+`,
+
+	"recover.txt": `{{define "subject"}}Account Recovery{{end -}}
+Account recovery requested, if this was you please continue to reset your password:
+
+{{url "/recover/" .Code}}
+
+If you did not request a password reset, please ignore this email.
+`,
+
+	"recover.html": `<!DOCTYPE html>
+<html>
+<body>
+<p>Account recovery requested, if this was you please continue to <a href="{{url "/recover/" .Code}}">reset your password</a>.</p>
+<p>If you did not request a password reset, please ignore this email.</p>
+</body>
+</html>
+`,
+
+	"invite.txt": `{{define "subject"}}You have been invited to {{.Organisation}}{{end -}}
+{{.InvitedBy}} has invited you to join {{.Organisation}} as {{.Role}}. Log in or register with this email address and continue to:
+
+{{url "/invitations/" .Code}}
+
+The invitation expires in 7 days, if you were not expecting it please ignore this email.
+`,
+
+	"invite.html": `<!DOCTYPE html>
+<html>
+<body>
+<p>{{.InvitedBy}} has invited you to join {{.Organisation}} as {{.Role}}. Log in or register with this email address and <a href="{{url "/invitations/" .Code}}">accept the invitation</a>.</p>
+<p>The invitation expires in 7 days, if you were not expecting it please ignore this email.</p>
+</body>
+</html>
+`,
+
+	"alert.txt": `{{define "subject"}}{{.Subject}}{{end -}}
+{{range .Items}}
+{{.Summary}}, please go to: {{.URL}} for more details or find a summary below.
+{{if .AcknowledgeURL}}Acknowledge: {{.AcknowledgeURL}}
+{{end}}{{if or .Additions .Removals .Lines}}
+{{range .Additions}}	+++	{{.Raw}}
+{{end}}{{range .Removals}}	---	{{.Raw}}
+{{end}}{{range .Lines}}	{{.}}
+{{end}}{{end}}{{end}}`,
+
+	"alert.html": `<!DOCTYPE html>
+<html>
+<body>
+{{range .Items}}
+<p>{{.Summary}}, please go to: <a href="{{.URL}}">{{.Domain}}</a> for more details or find a summary below.</p>
+{{if .AcknowledgeURL}}<p><a href="{{.AcknowledgeURL}}">Acknowledge</a></p>
+{{end}}{{if or .Additions .Removals .Lines}}<pre>
+{{- range $idx, $r := .Additions}}{{if eq $idx 0}}
+-------------------------------- / additions start{{end}}
+	+++	{{$r.Raw}}{{end}}
+{{- range $idx, $r := .Removals}}{{if eq $idx 0}}
+-------------------------------- / removals start{{end}}
+	---	{{$r.Raw}}{{end}}
+{{- range $idx, $line := .Lines}}{{if eq $idx 0}}
+-------------------------------- / details start{{end}}
+	{{$line}}{{end}}
+-------------------------------- / end
+</pre>{{end}}
+{{end}}
+</body>
+</html>
+`,
+
+	"digest.txt": `{{define "subject"}}Your {{.Frequency}} whois.bi digest{{end -}}
+Your {{.Frequency}} digest for {{.PeriodStart.Format "2006-01-02 15:04"}} to {{.PeriodEnd.Format "2006-01-02 15:04 MST"}}.
+{{if .Changes}}
+Changes:
+{{range .Changes}}
+{{.Domain}} {{.URL}}{{if .WhoisUpdates}} (whois updated){{end}}
+{{range .Additions}}	+++	{{.}}
+{{end}}{{range .Removals}}	---	{{.}}
+{{end}}{{end}}{{else}}
+No changes.
+{{end}}{{if .Expirations}}
+Expiring soon:
+{{range .Expirations}}
+{{.Domain}} expires on {{.ExpirationDate.Format "2006-01-02"}} ({{.Days}} days) {{.URL}}{{end}}
+{{end}}{{if .Failures}}
+Failed scans:
+{{range .Failures}}
+{{.Domain}} {{.FinishedAt.Format "2006-01-02 15:04"}}{{range .Errors}}
+	{{.}}{{end}}{{end}}
+{{end}}`,
+
+	"digest.html": `<!DOCTYPE html>
+<html>
+<body>
+<p>Your {{.Frequency}} digest for {{.PeriodStart.Format "2006-01-02 15:04"}} to {{.PeriodEnd.Format "2006-01-02 15:04 MST"}}.</p>
+<h3>Changes</h3>
+{{range .Changes}}
+<p><a href="{{.URL}}">{{.Domain}}</a>{{if .WhoisUpdates}} (whois updated){{end}}</p>
+{{if or .Additions .Removals}}<pre>
+{{- range .Additions}}
+	+++	{{.}}{{end}}
+{{- range .Removals}}
+	---	{{.}}{{end}}
+</pre>{{end}}
+{{else}}
+<p>No changes.</p>
+{{end}}
+{{if .Expirations}}
+<h3>Expiring soon</h3>
+<ul>
+{{range .Expirations}}<li><a href="{{.URL}}">{{.Domain}}</a> expires on {{.ExpirationDate.Format "2006-01-02"}} ({{.Days}} days)</li>
+{{end}}</ul>
+{{end}}
+{{if .Failures}}
+<h3>Failed scans</h3>
+<ul>
+{{range .Failures}}<li><a href="{{.URL}}">{{.Domain}}</a> {{.FinishedAt.Format "2006-01-02 15:04"}}{{range .Errors}}<br>{{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`,
+}
@@ -3,19 +3,18 @@ package emailer
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"net/smtp"
 	"os"
 	"strings"
+	"time"
 
-	lru "github.com/hashicorp/golang-lru"
 	"github.com/jhillyerd/enmime"
-	"github.com/segmentio/fasthash/fnv1a"
 )
 
 // Emailer sends emails out using the provided sender and using
-// the provided email / from name. It uses an LRU cache to prevent
-// duplicates being sent out, this can probably be removed once acceptable
-// code coverage appears over the codebase.
+// the provided email / from name. Emails can be sent immediately or queued
+// in the outbox, see Queue.
 type Emailer struct {
 	// runtime required options
 	fromName  string
@@ -23,53 +22,119 @@ type Emailer struct {
 
 	sender enmime.Sender
 
-	// used to prevent duplicates being sent
-	cache *lru.Cache
+	// used to render every email sent
+	templates *Templates
 }
 
-// Creates an SMTP sender using environment variables
-func NewSMTPSenderFromEnv() enmime.Sender {
-	auth := smtp.PlainAuth("", os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_HOST"))
+// Creates an SMTP sender using environment variables, SMTP_TLS is one of
+// starttls, tls or none and defaults to using STARTTLS when available
+func NewSMTPSenderFromEnv() (*SMTPSender, error) {
+	var auth smtp.Auth
+	if len(os.Getenv("SMTP_USER")) > 0 {
+		auth = smtp.PlainAuth("", os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_HOST"))
+	}
 	addr := fmt.Sprintf("%s:%s", os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"))
-	return enmime.NewSMTP(addr, auth)
+	return NewSMTPSender(addr, auth, os.Getenv("SMTP_TLS"))
 }
 
-// Create a new Emailer that sends via the provided sender
-func NewEmailer(fromName, fromEmail string, sender enmime.Sender) (*Emailer, error) {
-	cache, err := lru.New(256)
-	if err != nil {
-		return nil, err
+// Creates the sender chosen by EMAIL_TRANSPORT, one of smtp (the default),
+// ses, sendgrid or sendmail, configured using environment variables
+func NewSenderFromEnv() (enmime.Sender, error) {
+	client := &http.Client{Timeout: time.Second * 30}
+
+	switch transport := os.Getenv("EMAIL_TRANSPORT"); transport {
+	case "", "smtp":
+		return NewSMTPSenderFromEnv()
+
+	case "ses":
+		region := os.Getenv("SES_REGION")
+		if len(region) == 0 {
+			region = os.Getenv("AWS_REGION")
+		}
+		return NewSESSender(client, os.Getenv("SES_ENDPOINT"), region, SESCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+
+	case "sendgrid":
+		key := os.Getenv("SENDGRID_API_KEY")
+		if len(key) == 0 {
+			return nil, errors.New("missing SENDGRID_API_KEY")
+		}
+		return NewSendGridSender(client, os.Getenv("SENDGRID_ENDPOINT"), key), nil
+
+	case "sendmail":
+		return NewSendmailSender(os.Getenv("SENDMAIL_PATH")), nil
+
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_TRANSPORT %q", transport)
 	}
+}
 
+// Create a new Emailer that sends via the provided sender
+func NewEmailer(fromName, fromEmail string, sender enmime.Sender) (*Emailer, error) {
 	emailer := Emailer{
 		fromName:  fromName,
 		fromEmail: fromEmail,
-		cache:     cache,
 		sender:    sender,
+		templates: NewTemplatesFromEnv(),
 	}
 
 	return &emailer, nil
 }
 
-// Send an email. Hash of the entire email is taken and cached to prevent duplicates
-func (s *Emailer) Send(to, subject, body string) error {
-	hash := fnv1a.HashString64(to + subject + body)
-	if s.cache.Contains(hash) {
-		return nil
+// SetTemplates replaces the templates used to render emails
+func (s *Emailer) SetTemplates(templates *Templates) {
+	s.templates = templates
+}
+
+// URL returns an absolute link to the frontend
+func (s *Emailer) URL(path ...string) string {
+	return s.templates.URL(path...)
+}
+
+// SendTemplate renders the named template in the recipient's locale and
+// sends it as a multipart text and html email immediately
+func (s *Emailer) SendTemplate(to, locale, name string, data interface{}) error {
+	email, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return err
 	}
 
-	s.cache.Add(hash, struct{}{})
+	return s.send(to, email)
+}
+
+// Send an html email immediately
+func (s *Emailer) Send(to, subject, body string) error {
+	return s.send(to, Email{Subject: subject, HTML: body})
+}
 
-	// crude validation
+// crude validation
+func validRecipient(to string) error {
 	if len(to) == 0 || !strings.Contains(to, "@") {
 		return errors.New("invalid recipient")
 	}
+	return nil
+}
+
+func (s *Emailer) send(to string, email Email) error {
+	if err := validRecipient(to); err != nil {
+		return err
+	}
 
 	msg := enmime.Builder().
 		From(s.fromName, s.fromEmail).
 		To(to, to).
-		Subject(subject).
-		HTML([]byte(body))
+		Subject(email.Subject)
+
+	if len(email.Text) > 0 {
+		msg = msg.Text([]byte(email.Text))
+	}
+
+	if len(email.HTML) > 0 {
+		msg = msg.HTML([]byte(email.HTML))
+	}
 
 	if err := msg.Send(s.sender); err != nil {
 		return err
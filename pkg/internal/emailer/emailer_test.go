@@ -2,6 +2,7 @@ package emailer
 
 import (
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -65,7 +66,7 @@ func Test_SendError(t *testing.T) {
 	}
 }
 
-func Test_DuplicateSend(t *testing.T) {
+func Test_BadEmail(t *testing.T) {
 	t.Parallel()
 
 	sender := NewMemorySender()
@@ -75,26 +76,23 @@ func Test_DuplicateSend(t *testing.T) {
 		t.Fatalf("NewEmailer() expected nil got %q", err)
 	}
 
-	for i := 0; i < 10; i++ {
-		if err := emailer.Send(toEmail, subject, body); err != nil {
-			t.Fatalf("Send() expected nil got %q", err)
-		}
+	if err := emailer.Send("", subject, body); err == nil {
+		t.Fatal("Send() expected an error got nil")
 	}
+}
 
-	if len(sender.emails) != 1 {
-		t.Fatal("Expected sender.emails to be 1")
-	}
+func Test_BadMemoryIndex(t *testing.T) {
+	t.Parallel()
 
-	if err := emailer.Send("another@whois.bi", subject, body); err != nil {
-		t.Fatalf("Send() expected nil got %q", err)
-	}
+	sender := NewMemorySender()
 
-	if len(sender.emails) != 2 {
-		t.Fatal("Expected sender.emails to be 2")
+	_, err := sender.EmailAt(0)
+	if err == nil {
+		t.Fatal("EmailAt() expected an error got nil")
 	}
 }
 
-func Test_BadEmail(t *testing.T) {
+func Test_SendTemplate(t *testing.T) {
 	t.Parallel()
 
 	sender := NewMemorySender()
@@ -104,18 +102,22 @@ func Test_BadEmail(t *testing.T) {
 		t.Fatalf("NewEmailer() expected nil got %q", err)
 	}
 
-	if err := emailer.Send("", subject, body); err == nil {
-		t.Fatal("Send() expected an error got nil")
+	emailer.SetTemplates(NewTemplates("", "https://whois.bi"))
+
+	if err := emailer.SendTemplate(toEmail, DefaultLocale, "verify", struct{ Code string }{"abc"}); err != nil {
+		t.Fatalf("SendTemplate() expected nil got %q", err)
 	}
-}
 
-func Test_BadMemoryIndex(t *testing.T) {
-	t.Parallel()
+	env, err := sender.EmailAt(0)
+	if err != nil {
+		t.Fatalf("EmailAt() expected nil got %q", err)
+	}
 
-	sender := NewMemorySender()
+	if env.GetHeader("Subject") != "Please verify your account" {
+		t.Fatal("Unexpected subject")
+	}
 
-	_, err := sender.EmailAt(0)
-	if err == nil {
-		t.Fatal("EmailAt() expected an error got nil")
+	if !strings.Contains(env.Text, "https://whois.bi/verify/abc") || !strings.Contains(env.HTML, "https://whois.bi/verify/abc") {
+		t.Fatalf("expected text and html parts got %q and %q", env.Text, env.HTML)
 	}
 }
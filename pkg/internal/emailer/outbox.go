@@ -0,0 +1,194 @@
+package emailer
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// attempts before a message is marked as failed
+	maxAttempts = 10
+	// first retry delay, doubled for each attempt
+	retryBackoff = time.Minute
+	// cap on the retry delay
+	maxRetryBackoff = time.Hour * 2
+	// how often pending messages are checked
+	outboxInterval = time.Second * 10
+	// maximum messages attempted per tick
+	outboxBatch = 100
+	// truncate stored error messages
+	maxErrorLength = 512
+)
+
+type MessageStatus = string
+
+const (
+	MessagePending MessageStatus = "pending"
+	MessageSent    MessageStatus = "sent"
+	MessageFailed  MessageStatus = "failed"
+)
+
+// Message is a rendered email waiting in, or sent from, the outbox
+type Message struct {
+	ID int `pg:",pk" json:"id"`
+
+	// a message is only ever queued once for each key
+	Key string `pg:",notnull,unique" json:"key"`
+
+	To      string `pg:",notnull" json:"to"`
+	Subject string `pg:",notnull,use_zero" json:"subject"`
+	Text    string `pg:",notnull,use_zero" json:"-"`
+	HTML    string `pg:",notnull,use_zero" json:"-"`
+
+	Status   MessageStatus `pg:",notnull" json:"status"`
+	Attempts int           `pg:",notnull,use_zero" json:"attempts"`
+	Error    string        `pg:",notnull,use_zero" json:"error"`
+
+	CreatedAt     time.Time `pg:",notnull,default:now()" json:"created_at"`
+	NextAttemptAt time.Time `pg:",notnull,default:now()" json:"next_attempt_at"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+// Queue renders the named template in the recipient's locale and stores it
+// in the outbox to be sent by the Outbox. Queueing the same key more than
+// once only stores the first message, so callers can safely retry.
+func (s *Emailer) Queue(db orm.DB, key, to, locale, name string, data interface{}) error {
+	if len(key) == 0 {
+		return errors.New("missing key")
+	}
+
+	if err := validRecipient(to); err != nil {
+		return err
+	}
+
+	email, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+
+	msg := Message{
+		Key:     key,
+		To:      to,
+		Subject: email.Subject,
+		Text:    email.Text,
+		HTML:    email.HTML,
+		Status:  MessagePending,
+	}
+
+	if _, err := db.Model(&msg).OnConflict("(key) DO NOTHING").Insert(); err != nil {
+		return errors.WithMessage(err, "Insert message")
+	}
+
+	return nil
+}
+
+// backoff returns how long to wait before the next attempt
+func backoff(attempts int) time.Duration {
+	d := retryBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return d
+}
+
+// deliver sends the message and records the outcome on it. An error is
+// returned if the sender did not accept the message.
+func (s *Emailer) deliver(msg *Message, now time.Time) error {
+	msg.Attempts++
+
+	err := s.send(msg.To, Email{Subject: msg.Subject, Text: msg.Text, HTML: msg.HTML})
+	if err == nil {
+		msg.Status = MessageSent
+		msg.Error = ""
+		msg.SentAt = now
+		return nil
+	}
+
+	msg.Error = err.Error()
+	if len(msg.Error) > maxErrorLength {
+		msg.Error = msg.Error[:maxErrorLength]
+	}
+
+	if msg.Attempts >= maxAttempts {
+		msg.Status = MessageFailed
+	} else {
+		msg.Status = MessagePending
+		msg.NextAttemptAt = now.Add(backoff(msg.Attempts))
+	}
+
+	return err
+}
+
+// Outbox sends queued messages, retrying failures with backoff
+type Outbox struct {
+	db      *pg.DB
+	emailer *Emailer
+}
+
+// NewOutbox creates an Outbox, only one should be run at a time
+func NewOutbox(db *pg.DB, emailer *Emailer) *Outbox {
+	return &Outbox{
+		db:      db,
+		emailer: emailer,
+	}
+}
+
+// Run sends pending messages until the context is cancelled
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := o.flush(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (o *Outbox) flush(ctx context.Context) error {
+	var messages []Message
+	err := o.db.Model(&messages).
+		Where("status = ? AND next_attempt_at <= ?", MessagePending, time.Now()).
+		Order("next_attempt_at", "id").
+		Limit(outboxBatch).
+		Select()
+	if err != nil {
+		return errors.WithMessage(err, "Select messages")
+	}
+
+	for idx := range messages {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		msg := &messages[idx]
+
+		if err := o.emailer.deliver(msg, time.Now()); err != nil {
+			log.Printf("Error sending message %d to %s (attempt %d): %s", msg.ID, msg.To, msg.Attempts, err)
+		}
+
+		_, err := o.db.Model(msg).
+			Set("status = ?status, attempts = ?attempts, error = ?error, next_attempt_at = ?next_attempt_at, sent_at = ?sent_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return errors.WithMessagef(err, "Update message %d", msg.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package emailer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, time.Minute * 2},
+		{5, time.Minute * 16},
+		{7, time.Minute * 64},
+		{8, maxRetryBackoff},
+		{100, maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.expected {
+			t.Errorf("backoff(%d) expected %s got %s", tt.attempts, tt.expected, got)
+		}
+	}
+}
+
+func Test_Deliver(t *testing.T) {
+	t.Parallel()
+
+	sender := NewMemorySender()
+
+	emailer, err := NewEmailer(fromName, fromEmail, sender)
+	if err != nil {
+		t.Fatalf("NewEmailer() expected nil got %q", err)
+	}
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	msg := Message{
+		Key:     "test",
+		To:      toEmail,
+		Subject: subject,
+		Text:    body,
+		Status:  MessagePending,
+	}
+
+	sender.err = errors.New("mock error")
+
+	if err := emailer.deliver(&msg, now); err == nil {
+		t.Fatal("deliver() expected an error got nil")
+	}
+
+	if msg.Status != MessagePending || msg.Attempts != 1 || msg.Error != "mock error" {
+		t.Fatalf("unexpected message after failure %+v", msg)
+	}
+
+	if !msg.NextAttemptAt.Equal(now.Add(retryBackoff)) {
+		t.Fatalf("expected next attempt at %s got %s", now.Add(retryBackoff), msg.NextAttemptAt)
+	}
+
+	if err := emailer.deliver(&msg, now); err != nil {
+		t.Fatalf("deliver() expected nil got %q", err)
+	}
+
+	if msg.Status != MessageSent || msg.Attempts != 2 || len(msg.Error) > 0 || !msg.SentAt.Equal(now) {
+		t.Fatalf("unexpected message after success %+v", msg)
+	}
+
+	env, err := sender.EmailAt(0)
+	if err != nil {
+		t.Fatalf("EmailAt() expected nil got %q", err)
+	}
+
+	if env.GetHeader("Subject") != subject {
+		t.Fatal("Unexpected subject")
+	}
+}
+
+func Test_DeliverFailed(t *testing.T) {
+	t.Parallel()
+
+	sender := NewMemorySender()
+
+	emailer, err := NewEmailer(fromName, fromEmail, sender)
+	if err != nil {
+		t.Fatalf("NewEmailer() expected nil got %q", err)
+	}
+
+	msg := Message{
+		Key:      "test",
+		To:       toEmail,
+		Subject:  subject,
+		Text:     body,
+		Status:   MessagePending,
+		Attempts: maxAttempts - 1,
+	}
+
+	sender.err = errors.New("mock error")
+
+	if err := emailer.deliver(&msg, time.Now()); err == nil {
+		t.Fatal("deliver() expected an error got nil")
+	}
+
+	if msg.Status != MessageFailed {
+		t.Fatalf("expected status %q got %q", MessageFailed, msg.Status)
+	}
+}
+
+func Test_DeliverBadRecipient(t *testing.T) {
+	t.Parallel()
+
+	emailer, err := NewEmailer(fromName, fromEmail, NewMemorySender())
+	if err != nil {
+		t.Fatalf("NewEmailer() expected nil got %q", err)
+	}
+
+	msg := Message{Key: "test", Subject: subject, Status: MessagePending}
+
+	if err := emailer.deliver(&msg, time.Now()); err == nil {
+		t.Fatal("deliver() expected an error got nil")
+	}
+
+	if msg.Attempts != 1 || msg.Status != MessagePending {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
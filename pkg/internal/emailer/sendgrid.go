@@ -0,0 +1,131 @@
+package emailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/mail"
+
+	"github.com/jhillyerd/enmime"
+	"github.com/pkg/errors"
+)
+
+// DefaultSendGridEndpoint is the SendGrid v3 mail send API
+const DefaultSendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridSender sends emails using the SendGrid API, satisfies
+// enmime.Sender
+type SendGridSender struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+// NewSendGridSender creates a SendGridSender, an empty endpoint uses
+// DefaultSendGridEndpoint
+func NewSendGridSender(client *http.Client, endpoint, apiKey string) *SendGridSender {
+	if len(endpoint) == 0 {
+		endpoint = DefaultSendGridEndpoint
+	}
+
+	return &SendGridSender{
+		client:   client,
+		endpoint: endpoint,
+		apiKey:   apiKey,
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// Send the message to the recipients. The API does not accept raw
+// messages so the message is parsed back in to its parts.
+func (s *SendGridSender) Send(from string, to []string, msg []byte) error {
+	env, err := enmime.ReadEnvelope(bytes.NewReader(msg))
+	if err != nil {
+		return errors.Wrap(err, "ReadEnvelope")
+	}
+
+	var request sendGridMessage
+
+	request.From = sendGridAddress{Email: from}
+	if addr, err := mail.ParseAddress(env.GetHeader("From")); err == nil && addr.Address == from {
+		request.From.Name = addr.Name
+	}
+
+	var p sendGridPersonalization
+	for _, rcpt := range to {
+		p.To = append(p.To, sendGridAddress{Email: rcpt})
+	}
+	request.Personalizations = []sendGridPersonalization{p}
+
+	request.Subject = env.GetHeader("Subject")
+
+	// text/plain must come before text/html
+	if len(env.Text) > 0 {
+		request.Content = append(request.Content, sendGridContent{Type: "text/plain", Value: env.Text})
+	}
+
+	if len(env.HTML) > 0 {
+		request.Content = append(request.Content, sendGridContent{Type: "text/html", Value: env.HTML})
+	}
+
+	if len(request.Content) == 0 {
+		return errors.New("message has no content")
+	}
+
+	body, err := json.Marshal(&request)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doAPIRequest(s.client, req)
+}
+
+// doAPIRequest sends the request, any non 2xx response is an error
+func doAPIRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// drain so the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
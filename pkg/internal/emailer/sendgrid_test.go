@@ -0,0 +1,82 @@
+package emailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SendGridSender(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		auth string
+		body sendGridMessage
+	}
+
+	requests := make(chan request, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body sendGridMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		requests <- request{r.Header.Get("Authorization"), body}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	emailer, err := NewEmailer(fromName, fromEmail, NewSendGridSender(server.Client(), server.URL, "key"))
+	if err != nil {
+		t.Fatalf("NewEmailer() expected nil got %q", err)
+	}
+
+	emailer.SetTemplates(NewTemplates("", "https://whois.bi"))
+
+	if err := emailer.SendTemplate(toEmail, DefaultLocale, "verify", struct{ Code string }{"abc"}); err != nil {
+		t.Fatalf("SendTemplate() expected nil got %q", err)
+	}
+
+	r := <-requests
+
+	if r.auth != "Bearer key" {
+		t.Errorf("unexpected authorization %q", r.auth)
+	}
+
+	if r.body.From.Email != fromEmail || r.body.From.Name != fromName {
+		t.Errorf("unexpected from %+v", r.body.From)
+	}
+
+	if len(r.body.Personalizations) != 1 || len(r.body.Personalizations[0].To) != 1 || r.body.Personalizations[0].To[0].Email != toEmail {
+		t.Errorf("unexpected personalizations %+v", r.body.Personalizations)
+	}
+
+	if r.body.Subject != "Please verify your account" {
+		t.Errorf("unexpected subject %q", r.body.Subject)
+	}
+
+	if len(r.body.Content) != 2 || r.body.Content[0].Type != "text/plain" || r.body.Content[1].Type != "text/html" {
+		t.Errorf("unexpected content %+v", r.body.Content)
+	}
+}
+
+func Test_SendGridSenderError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	emailer, err := NewEmailer(fromName, fromEmail, NewSendGridSender(server.Client(), server.URL, "key"))
+	if err != nil {
+		t.Fatalf("NewEmailer() expected nil got %q", err)
+	}
+
+	if err := emailer.Send(toEmail, subject, body); err == nil {
+		t.Fatal("Send() expected an error got nil")
+	}
+}
@@ -0,0 +1,52 @@
+package emailer
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSendmailPath is where sendmail is usually installed
+const DefaultSendmailPath = "/usr/sbin/sendmail"
+
+// SendmailSender pipes emails to a local sendmail compatible binary,
+// satisfies enmime.Sender
+type SendmailSender struct {
+	path string
+}
+
+// NewSendmailSender creates a SendmailSender, an empty path uses
+// DefaultSendmailPath
+func NewSendmailSender(path string) *SendmailSender {
+	if len(path) == 0 {
+		path = DefaultSendmailPath
+	}
+
+	return &SendmailSender{
+		path: path,
+	}
+}
+
+// Send the message to the recipients
+func (s *SendmailSender) Send(from string, to []string, msg []byte) error {
+	// -i stops a line with a single . ending the message early
+	args := []string{"-i", "-f", from, "--"}
+	args = append(args, to...)
+
+	cmd := exec.Command(s.path, args...)
+	cmd.Stdin = bytes.NewReader(msg)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if out := strings.TrimSpace(stderr.String()); len(out) > 0 {
+			return errors.Wrapf(err, "sendmail: %s", out)
+		}
+		return errors.Wrap(err, "sendmail")
+	}
+
+	return nil
+}
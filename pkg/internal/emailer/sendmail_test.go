@@ -0,0 +1,81 @@
+package emailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSendmail creates a stand in sendmail script in a temporary directory
+func writeSendmail(t *testing.T, script string) (string, string) {
+	dir, err := ioutil.TempDir("", "sendmail")
+	if err != nil {
+		t.Fatalf("TempDir() expected nil got %q", err)
+	}
+
+	path := filepath.Join(dir, "sendmail")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("WriteFile() expected nil got %q", err)
+	}
+
+	return dir, path
+}
+
+func Test_SendmailSender(t *testing.T) {
+	t.Parallel()
+
+	dir, path := writeSendmail(t, `echo "$@" > "$(dirname "$0")/args"
+cat > "$(dirname "$0")/msg"
+`)
+	defer os.RemoveAll(dir)
+
+	sender := NewSendmailSender(path)
+
+	msg := "Subject: test\r\n\r\nhello\r\n.\r\nstill here\r\n"
+
+	if err := sender.Send(fromEmail, []string{toEmail}, []byte(msg)); err != nil {
+		t.Fatalf("Send() expected nil got %q", err)
+	}
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatalf("ReadFile() expected nil got %q", err)
+	}
+
+	if strings.TrimSpace(string(args)) != "-i -f "+fromEmail+" -- "+toEmail {
+		t.Errorf("unexpected args %q", args)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "msg"))
+	if err != nil {
+		t.Fatalf("ReadFile() expected nil got %q", err)
+	}
+
+	if string(got) != msg {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func Test_SendmailSenderError(t *testing.T) {
+	t.Parallel()
+
+	dir, path := writeSendmail(t, `echo "no such user" >&2
+exit 67
+`)
+	defer os.RemoveAll(dir)
+
+	err := NewSendmailSender(path).Send(fromEmail, []string{toEmail}, []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "no such user") {
+		t.Fatalf("Send() expected a no such user error got %v", err)
+	}
+}
+
+func Test_SendmailSenderDefaultPath(t *testing.T) {
+	t.Parallel()
+
+	if NewSendmailSender("").path != DefaultSendmailPath {
+		t.Fatal("expected the default sendmail path")
+	}
+}
@@ -0,0 +1,180 @@
+package emailer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SESCredentials are the AWS credentials used to sign requests
+type SESCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// optional, for temporary credentials
+	SessionToken string
+}
+
+// SESSender sends raw emails using the Amazon SES v2 API, satisfies
+// enmime.Sender
+type SESSender struct {
+	client      *http.Client
+	endpoint    string
+	region      string
+	credentials SESCredentials
+
+	// used to sign requests, replaced in tests
+	now func() time.Time
+}
+
+// NewSESSender creates an SESSender for the region, an empty endpoint uses
+// the regional SES endpoint
+func NewSESSender(client *http.Client, endpoint, region string, credentials SESCredentials) (*SESSender, error) {
+	if len(region) == 0 {
+		return nil, errors.New("missing region")
+	}
+
+	if len(credentials.AccessKeyID) == 0 || len(credentials.SecretAccessKey) == 0 {
+		return nil, errors.New("missing credentials")
+	}
+
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", region)
+	}
+
+	return &SESSender{
+		client:      client,
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/v2/email/outbound-emails",
+		region:      region,
+		credentials: credentials,
+		now:         time.Now,
+	}, nil
+}
+
+type sesRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Raw struct {
+			// encoded as base64 by encoding/json
+			Data []byte `json:"Data"`
+		} `json:"Raw"`
+	} `json:"Content"`
+}
+
+// Send the message to the recipients
+func (s *SESSender) Send(from string, to []string, msg []byte) error {
+	var request sesRequest
+	request.FromEmailAddress = from
+	request.Destination.ToAddresses = to
+	request.Content.Raw.Data = msg
+
+	body, err := json.Marshal(&request)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	s.sign(req, body, s.now().UTC())
+
+	return doAPIRequest(s.client, req)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign the request using AWS Signature Version 4
+func (s *SESSender) sign(req *http.Request, body []byte, now time.Time) {
+	const service = "ses"
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if len(s.credentials.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.credentials.SessionToken)
+	}
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if len(s.credentials.SessionToken) > 0 {
+		signed = append(signed, "x-amz-security-token")
+	}
+
+	var headers strings.Builder
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, service, "aws4_request"}, "/")
+
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.credentials.AccessKeyID,
+		scope,
+		strings.Join(signed, ";"),
+		signature,
+	))
+}
+
+func canonicalQuery(values url.Values) string {
+	// url.Values.Encode sorts by key but encodes spaces as +
+	return strings.Replace(values.Encode(), "+", "%20", -1)
+}
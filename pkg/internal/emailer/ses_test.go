@@ -0,0 +1,149 @@
+package emailer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SESSender(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		path    string
+		headers http.Header
+		body    sesRequest
+	}
+
+	requests := make(chan request, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+
+		var body sesRequest
+		if err := json.Unmarshal(b, &body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		requests <- request{r.URL.Path, r.Header, body}
+		w.Write([]byte(`{"MessageId":"abc"}`))
+	}))
+	defer server.Close()
+
+	sender, err := NewSESSender(server.Client(), server.URL, "eu-west-1", SESCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	})
+	if err != nil {
+		t.Fatalf("NewSESSender() expected nil got %q", err)
+	}
+
+	sender.now = func() time.Time {
+		return time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	}
+
+	msg := []byte("Subject: test\r\n\r\nhello\r\n")
+
+	if err := sender.Send(fromEmail, []string{toEmail}, msg); err != nil {
+		t.Fatalf("Send() expected nil got %q", err)
+	}
+
+	r := <-requests
+
+	if r.path != "/v2/email/outbound-emails" {
+		t.Errorf("unexpected path %q", r.path)
+	}
+
+	if r.body.FromEmailAddress != fromEmail || len(r.body.Destination.ToAddresses) != 1 || r.body.Destination.ToAddresses[0] != toEmail {
+		t.Errorf("unexpected addresses %+v", r.body)
+	}
+
+	if string(r.body.Content.Raw.Data) != string(msg) {
+		t.Errorf("unexpected raw message %q", r.body.Content.Raw.Data)
+	}
+
+	if r.headers.Get("X-Amz-Date") != "20210301T123000Z" || r.headers.Get("X-Amz-Security-Token") != "token" {
+		t.Errorf("unexpected amz headers %v", r.headers)
+	}
+
+	auth := r.headers.Get("Authorization")
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20210301/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature="
+	if !strings.HasPrefix(auth, expected) || len(auth) != len(expected)+64 {
+		t.Errorf("unexpected authorization %q", auth)
+	}
+}
+
+func Test_SESSenderError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"bad signature"}`))
+	}))
+	defer server.Close()
+
+	sender, err := NewSESSender(server.Client(), server.URL, "eu-west-1", SESCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewSESSender() expected nil got %q", err)
+	}
+
+	err = sender.Send(fromEmail, []string{toEmail}, []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Fatalf("Send() expected a bad signature error got %v", err)
+	}
+}
+
+func Test_SESSign(t *testing.T) {
+	t.Parallel()
+
+	sender, err := NewSESSender(http.DefaultClient, "", "us-east-1", SESCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewSESSender() expected nil got %q", err)
+	}
+
+	if sender.endpoint != "https://email.us-east-1.amazonaws.com/v2/email/outbound-emails" {
+		t.Fatalf("unexpected endpoint %q", sender.endpoint)
+	}
+
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	sign := func(body string) string {
+		req, _ := http.NewRequest(http.MethodPost, sender.endpoint, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		sender.sign(req, []byte(body), now)
+		return req.Header.Get("Authorization")
+	}
+
+	if sign("a") != sign("a") {
+		t.Error("expected signing to be deterministic")
+	}
+
+	if sign("a") == sign("b") {
+		t.Error("expected the body to change the signature")
+	}
+}
+
+func Test_NewSESSenderMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewSESSender(http.DefaultClient, "", "", SESCredentials{AccessKeyID: "a", SecretAccessKey: "b"}); err == nil {
+		t.Error("NewSESSender() expected a missing region error")
+	}
+
+	if _, err := NewSESSender(http.DefaultClient, "", "us-east-1", SESCredentials{}); err == nil {
+		t.Error("NewSESSender() expected a missing credentials error")
+	}
+}
@@ -0,0 +1,152 @@
+package emailer
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type TLSMode = string
+
+const (
+	// use STARTTLS if the server supports it, the same as smtp.SendMail
+	TLSOpportunistic TLSMode = ""
+	// require STARTTLS
+	TLSStartTLS TLSMode = "starttls"
+	// connect over TLS, usually on port 465
+	TLSImplicit TLSMode = "tls"
+	// never use TLS
+	TLSNone TLSMode = "none"
+)
+
+const (
+	// how long to wait connecting to an SMTP server
+	smtpDialTimeout = time.Second * 30
+	// how long sending a message can take, so a server that stops
+	// responding can not block the outbox
+	smtpTimeout = time.Minute * 2
+)
+
+// SMTPSender sends emails to an SMTP server, satisfies enmime.Sender
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	mode TLSMode
+
+	// used for STARTTLS and implicit TLS, defaults to verifying the host
+	TLSConfig *tls.Config
+}
+
+// NewSMTPSender creates an SMTPSender, auth can be nil
+func NewSMTPSender(addr string, auth smtp.Auth, mode TLSMode) (*SMTPSender, error) {
+	switch mode {
+	case TLSOpportunistic, TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, errors.Errorf("unknown tls mode %q", mode)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "SplitHostPort")
+	}
+
+	return &SMTPSender{
+		addr:      addr,
+		auth:      auth,
+		mode:      mode,
+		TLSConfig: &tls.Config{ServerName: host},
+	}, nil
+}
+
+func (s *SMTPSender) dial() (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	var err error
+
+	if s.mode == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Dial")
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "SetDeadline")
+	}
+
+	c, err := smtp.NewClient(conn, s.TLSConfig.ServerName)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "NewClient")
+	}
+
+	return c, nil
+}
+
+// Send the message to the recipients
+func (s *SMTPSender) Send(from string, to []string, msg []byte) error {
+	c, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return errors.Wrap(err, "Hello")
+	}
+
+	if s.mode == TLSStartTLS || s.mode == TLSOpportunistic {
+		ok, _ := c.Extension("STARTTLS")
+		if ok {
+			if err := c.StartTLS(s.TLSConfig); err != nil {
+				return errors.Wrap(err, "StartTLS")
+			}
+		} else if s.mode == TLSStartTLS {
+			return errors.New("server does not support STARTTLS")
+		}
+	}
+
+	// never send without the credentials we were given, the server may
+	// only be hiding AUTH because it is not the server we expect
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		}
+
+		if err := c.Auth(s.auth); err != nil {
+			return errors.Wrap(err, "Auth")
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return errors.Wrap(err, "Mail")
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return errors.Wrapf(err, "Rcpt %s", rcpt)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "Data")
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "Write")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,276 @@
+package emailer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httptest"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a stand in SMTP server that records what it receives
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	starttls bool
+
+	sync.Mutex
+	noAuth   bool
+	from     string
+	to       []string
+	data     string
+	auth     bool
+	usedTLS  bool
+	received chan struct{}
+}
+
+// newSMTPServer starts a server, implicit wraps every connection in TLS
+// and starttls advertises STARTTLS
+func newSMTPServer(t *testing.T, implicit, starttls bool) (*smtpServer, *x509.CertPool) {
+	// borrow the certificate httptest uses for 127.0.0.1
+	https := httptest.NewTLSServer(nil)
+	https.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(https.Certificate())
+
+	config := &tls.Config{Certificates: https.TLS.Certificates}
+
+	var l net.Listener
+	var err error
+	if implicit {
+		l, err = tls.Listen("tcp", "127.0.0.1:0", config)
+	} else {
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("Listen() expected nil got %q", err)
+	}
+
+	s := &smtpServer{
+		listener: l,
+		tls:      config,
+		starttls: starttls,
+		usedTLS:  implicit,
+		received: make(chan struct{}, 1),
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s, pool
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO":
+			s.Lock()
+			secure := s.usedTLS
+			noAuth := s.noAuth
+			s.Unlock()
+			if s.starttls && !secure {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250-STARTTLS")
+			} else {
+				tp.PrintfLine("250-localhost")
+			}
+			if noAuth {
+				tp.PrintfLine("250 8BITMIME")
+			} else {
+				tp.PrintfLine("250 AUTH PLAIN")
+			}
+
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.Lock()
+			s.usedTLS = true
+			s.Unlock()
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+
+		case "AUTH":
+			s.Lock()
+			s.auth = true
+			s.Unlock()
+			tp.PrintfLine("235 ok")
+
+		case "MAIL":
+			s.Lock()
+			s.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<> ")
+			s.Unlock()
+			tp.PrintfLine("250 ok")
+
+		case "RCPT":
+			s.Lock()
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<> "))
+			s.Unlock()
+			tp.PrintfLine("250 ok")
+
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.Lock()
+			s.data = strings.Join(lines, "\n")
+			s.Unlock()
+			tp.PrintfLine("250 ok")
+			s.received <- struct{}{}
+
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func Test_SMTPSender(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		mode     TLSMode
+		implicit bool
+		starttls bool
+		auth     bool
+		usedTLS  bool
+	}{
+		{"none", TLSNone, false, true, false, false},
+		{"opportunistic without support", TLSOpportunistic, false, false, true, false},
+		{"opportunistic", TLSOpportunistic, false, true, true, true},
+		{"starttls", TLSStartTLS, false, true, true, true},
+		{"implicit", TLSImplicit, true, false, true, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, pool := newSMTPServer(t, tt.implicit, tt.starttls)
+			defer server.listener.Close()
+
+			var auth smtp.Auth
+			if tt.auth {
+				auth = smtp.PlainAuth("", "user", "pass", "127.0.0.1")
+			}
+
+			sender, err := NewSMTPSender(server.listener.Addr().String(), auth, tt.mode)
+			if err != nil {
+				t.Fatalf("NewSMTPSender() expected nil got %q", err)
+			}
+			sender.TLSConfig.RootCAs = pool
+
+			msg := "Subject: test\r\n\r\nhello\r\n"
+
+			if err := sender.Send(fromEmail, []string{toEmail}, []byte(msg)); err != nil {
+				t.Fatalf("Send() expected nil got %q", err)
+			}
+
+			<-server.received
+
+			server.Lock()
+			defer server.Unlock()
+
+			if server.from != fromEmail || len(server.to) != 1 || server.to[0] != toEmail {
+				t.Errorf("unexpected envelope from %q to %v", server.from, server.to)
+			}
+
+			if server.data != "Subject: test\n\nhello" {
+				t.Errorf("unexpected data %q", server.data)
+			}
+
+			if server.auth != tt.auth {
+				t.Errorf("expected auth %t got %t", tt.auth, server.auth)
+			}
+
+			if server.usedTLS != tt.usedTLS {
+				t.Errorf("expected tls %t got %t", tt.usedTLS, server.usedTLS)
+			}
+		})
+	}
+}
+
+func Test_SMTPSenderRequireStartTLS(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newSMTPServer(t, false, false)
+	defer server.listener.Close()
+
+	sender, err := NewSMTPSender(server.listener.Addr().String(), nil, TLSStartTLS)
+	if err != nil {
+		t.Fatalf("NewSMTPSender() expected nil got %q", err)
+	}
+
+	if err := sender.Send(fromEmail, []string{toEmail}, []byte("hello")); err == nil {
+		t.Fatal("Send() expected an error got nil")
+	}
+}
+
+func Test_SMTPSenderRequireAuth(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newSMTPServer(t, false, false)
+	server.Lock()
+	server.noAuth = true
+	server.Unlock()
+	defer server.listener.Close()
+
+	auth := smtp.PlainAuth("", "user", "pass", "127.0.0.1")
+
+	sender, err := NewSMTPSender(server.listener.Addr().String(), auth, TLSNone)
+	if err != nil {
+		t.Fatalf("NewSMTPSender() expected nil got %q", err)
+	}
+
+	if err := sender.Send(fromEmail, []string{toEmail}, []byte("hello")); err == nil {
+		t.Fatal("Send() expected an error got nil")
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	if len(server.from) > 0 {
+		t.Fatalf("expected nothing to be sent got mail from %q", server.from)
+	}
+}
+
+func Test_SMTPSenderUnknownMode(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewSMTPSender("127.0.0.1:25", nil, "ssl"); err == nil {
+		t.Fatal("NewSMTPSender() expected an error got nil")
+	}
+}
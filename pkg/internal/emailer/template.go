@@ -0,0 +1,165 @@
+package emailer
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLocale is used when a user has not chosen a locale
+const DefaultLocale = "en"
+
+// Email is a rendered template
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Templates renders emails from a text/plain and a text/html template.
+// Each text template must define a "subject" template. Templates are loaded
+// from dir, trying "<name>.<locale>.<ext>", "<name>.<language>.<ext>" and
+// then "<name>.<ext>" before falling back to the built in templates.
+// Templates can link back to the frontend using the url function:
+//
+//	{{ url "/verify/" .Code }}
+type Templates struct {
+	dir     string
+	baseURL string
+}
+
+// NewTemplates creates Templates that load overrides from dir, an empty
+// dir only uses the built in templates
+func NewTemplates(dir, baseURL string) *Templates {
+	return &Templates{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// NewTemplatesFromEnv creates Templates using the EMAIL_TEMPLATES directory
+// and BASE_URL, falling back to https://$DOMAIN
+func NewTemplatesFromEnv() *Templates {
+	baseURL := os.Getenv("BASE_URL")
+	if len(baseURL) == 0 {
+		baseURL = "https://" + os.Getenv("DOMAIN")
+	}
+	return NewTemplates(os.Getenv("EMAIL_TEMPLATES"), baseURL)
+}
+
+// URL returns an absolute link to the frontend
+func (t *Templates) URL(path ...string) string {
+	return t.baseURL + strings.Join(path, "")
+}
+
+var validLocale = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})?$`)
+
+// ValidLocale returns true if the locale is in the form "en" or "en-GB"
+func ValidLocale(locale string) bool {
+	return validLocale.MatchString(locale)
+}
+
+// locales returns the locales to try in order
+func locales(locale string) []string {
+	if !ValidLocale(locale) {
+		return nil
+	}
+
+	out := []string{locale}
+	if idx := strings.IndexAny(locale, "-_"); idx > 0 {
+		out = append(out, locale[:idx])
+	}
+
+	return out
+}
+
+// source returns the template source for a name, locale and extension
+func (t *Templates) source(name, locale, ext string) (string, error) {
+	if len(t.dir) > 0 {
+		candidates := make([]string, 0, 3)
+		for _, l := range locales(locale) {
+			candidates = append(candidates, name+"."+l+"."+ext)
+		}
+		candidates = append(candidates, name+"."+ext)
+
+		for _, c := range candidates {
+			b, err := ioutil.ReadFile(filepath.Join(t.dir, c))
+			if err == nil {
+				return string(b), nil
+			}
+
+			if !os.IsNotExist(err) {
+				return "", errors.Wrapf(err, "ReadFile %s", c)
+			}
+		}
+	}
+
+	src, ok := builtin[name+"."+ext]
+	if !ok {
+		return "", errors.Errorf("unknown template %s.%s", name, ext)
+	}
+
+	return src, nil
+}
+
+func (t *Templates) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"url": t.URL,
+	}
+}
+
+// Render the named template for the locale
+func (t *Templates) Render(name, locale string, data interface{}) (Email, error) {
+	var email Email
+
+	textSrc, err := t.source(name, locale, "txt")
+	if err != nil {
+		return email, err
+	}
+
+	htmlSrc, err := t.source(name, locale, "html")
+	if err != nil {
+		return email, err
+	}
+
+	text, err := texttemplate.New(name).Funcs(t.funcs()).Parse(textSrc)
+	if err != nil {
+		return email, errors.Wrapf(err, "Parse %s.txt", name)
+	}
+
+	if text.Lookup("subject") == nil {
+		return email, errors.Errorf("template %s.txt does not define a subject", name)
+	}
+
+	html, err := htmltemplate.New(name).Funcs(t.funcs()).Parse(htmlSrc)
+	if err != nil {
+		return email, errors.Wrapf(err, "Parse %s.html", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return email, errors.Wrapf(err, "Execute %s subject", name)
+	}
+
+	if err := text.Execute(&textBody, data); err != nil {
+		return email, errors.Wrapf(err, "Execute %s.txt", name)
+	}
+
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return email, errors.Wrapf(err, "Execute %s.html", name)
+	}
+
+	email.Subject = strings.TrimSpace(subject.String())
+	email.Text = strings.TrimSpace(textBody.String()) + "\n"
+	email.HTML = htmlBody.String()
+
+	return email, nil
+}
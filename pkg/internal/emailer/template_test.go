@@ -0,0 +1,255 @@
+package emailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	Raw string
+}
+
+type testItem struct {
+	Domain         string
+	URL            string
+	AcknowledgeURL string
+	Additions      []testRecord
+	Removals       []testRecord
+	Lines          []string
+}
+
+func (i testItem) Summary() string {
+	return "New changes have been detected"
+}
+
+type testAlert struct {
+	Subject string
+	Items   []testItem
+}
+
+var alert = testAlert{
+	Subject: "ALARM BELLS - Changes to 1 domains",
+	Items: []testItem{
+		{
+			Domain:         "example.com",
+			URL:            "https://whois.bi/domain/example.com",
+			AcknowledgeURL: "https://whois.bi/acknowledge/abc",
+			Additions:      []testRecord{{"example.com.\t300\tIN\tTXT\t\"<script>alert(1)</script>\""}},
+			Removals:       []testRecord{{"example.com.\t300\tIN\tA\t127.0.0.1"}},
+		},
+	},
+}
+
+func Test_RenderBuiltin(t *testing.T) {
+	templates := NewTemplates("", "https://whois.bi/")
+
+	email, err := templates.Render("verify", DefaultLocale, struct{ Code string }{"abc"})
+	if err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	if email.Subject != "Please verify your account" {
+		t.Fatalf("unexpected subject %q", email.Subject)
+	}
+
+	for _, body := range []string{email.Text, email.HTML} {
+		if !strings.Contains(body, "https://whois.bi/verify/abc") {
+			t.Fatalf("expected link in %q", body)
+		}
+	}
+
+	if _, err := templates.Render("recover", DefaultLocale, struct{ Code string }{"abc"}); err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	invite := struct{ Organisation, Role, InvitedBy, Code string }{"Acme", "editor", "admin@example.com", "abc"}
+
+	email, err = templates.Render("invite", DefaultLocale, invite)
+	if err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	if email.Subject != "You have been invited to Acme" || !strings.Contains(email.HTML, "https://whois.bi/invitations/abc") {
+		t.Fatalf("unexpected invite %+v", email)
+	}
+
+	if _, err := templates.Render("nope", DefaultLocale, nil); err == nil {
+		t.Fatal("Render() with unknown template expected error")
+	}
+}
+
+func Test_RenderAlert(t *testing.T) {
+	templates := NewTemplates("", "https://whois.bi")
+
+	email, err := templates.Render("alert", DefaultLocale, alert)
+	if err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	if email.Subject != alert.Subject {
+		t.Fatalf("unexpected subject %q", email.Subject)
+	}
+
+	if !strings.Contains(email.Text, "+++\texample.com.\t300\tIN\tTXT\t\"<script>alert(1)</script>\"") {
+		t.Fatalf("expected raw additions in text got %q", email.Text)
+	}
+
+	if !strings.Contains(email.Text, "---\texample.com.\t300\tIN\tA\t127.0.0.1") {
+		t.Fatalf("expected removals in text got %q", email.Text)
+	}
+
+	if !strings.Contains(email.Text, "Acknowledge: https://whois.bi/acknowledge/abc") {
+		t.Fatalf("expected acknowledge link in text got %q", email.Text)
+	}
+
+	if strings.Contains(email.HTML, "<script>") || !strings.Contains(email.HTML, "&lt;script&gt;") {
+		t.Fatalf("expected records to be escaped in html got %q", email.HTML)
+	}
+
+	for _, expected := range []string{"/ additions start", "/ removals start", `<a href="https://whois.bi/domain/example.com">`, `<a href="https://whois.bi/acknowledge/abc">`} {
+		if !strings.Contains(email.HTML, expected) {
+			t.Fatalf("expected %q in html got %q", expected, email.HTML)
+		}
+	}
+}
+
+func Test_RenderOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"verify.txt":      `{{define "subject"}}Verify{{end}}text {{.Code}}`,
+		"verify.de.txt":   `{{define "subject"}}Bestätigen{{end}}text {{.Code}}`,
+		"verify.de.html":  `<p>{{.Code}}</p>`,
+		"recover.txt":     `no subject`,
+		"recover.fr.html": `{{ .Code`,
+	}
+
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates := NewTemplates(dir, "https://whois.bi")
+	data := struct{ Code string }{"<b>"}
+
+	tests := []struct {
+		locale  string
+		subject string
+		html    string
+	}{
+		// exact locale
+		{"de", "Bestätigen", "<p>&lt;b&gt;</p>"},
+		// language fallback
+		{"de-AT", "Bestätigen", "<p>&lt;b&gt;</p>"},
+		// default override with built in html
+		{"en", "Verify", "https://whois.bi/verify/%3cb%3e"},
+		// invalid locales are ignored
+		{"../de", "Verify", "https://whois.bi/verify/%3cb%3e"},
+	}
+
+	for _, tt := range tests {
+		email, err := templates.Render("verify", tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%q) expected nil got %q", tt.locale, err)
+		}
+
+		if email.Subject != tt.subject || !strings.Contains(email.HTML, tt.html) {
+			t.Fatalf("Render(%q) unexpected email: %+v", tt.locale, email)
+		}
+	}
+
+	if _, err := templates.Render("recover", "en", data); err == nil {
+		t.Fatal("Render() without a subject expected error")
+	}
+}
+
+type testDigestChanges struct {
+	Domain       string
+	URL          string
+	Additions    []string
+	Removals     []string
+	WhoisUpdates int
+}
+
+type testDigestExpiration struct {
+	Domain         string
+	URL            string
+	ExpirationDate time.Time
+	Days           int
+}
+
+type testDigestFailure struct {
+	Domain     string
+	URL        string
+	Errors     []string
+	FinishedAt time.Time
+}
+
+type testDigest struct {
+	Frequency   string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Changes     []testDigestChanges
+	Expirations []testDigestExpiration
+	Failures    []testDigestFailure
+}
+
+func Test_RenderDigest(t *testing.T) {
+	templates := NewTemplates("", "https://whois.bi")
+
+	end := time.Date(2020, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	digest := testDigest{
+		Frequency:   "daily",
+		PeriodStart: end.AddDate(0, 0, -1),
+		PeriodEnd:   end,
+		Changes: []testDigestChanges{
+			{Domain: "example.com", Additions: []string{"<added>"}, WhoisUpdates: 1},
+		},
+		Expirations: []testDigestExpiration{
+			{Domain: "example.org", ExpirationDate: end.AddDate(0, 0, 3), Days: 3},
+		},
+		Failures: []testDigestFailure{
+			{Domain: "example.net", Errors: []string{"timeout"}, FinishedAt: end},
+		},
+	}
+
+	email, err := templates.Render("digest", DefaultLocale, digest)
+	if err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	if email.Subject != "Your daily whois.bi digest" {
+		t.Fatalf("unexpected subject %q", email.Subject)
+	}
+
+	for _, expected := range []string{"+++\t<added>", "(whois updated)", "example.org expires on 2020-07-04 (3 days)", "timeout"} {
+		if !strings.Contains(email.Text, expected) {
+			t.Fatalf("expected %q in text got %q", expected, email.Text)
+		}
+	}
+
+	if !strings.Contains(email.HTML, "&lt;added&gt;") {
+		t.Fatalf("expected escaped record in html got %q", email.HTML)
+	}
+
+	empty := testDigest{Frequency: "weekly", PeriodStart: end, PeriodEnd: end}
+
+	email, err = templates.Render("digest", DefaultLocale, empty)
+	if err != nil {
+		t.Fatalf("Render() expected nil got %q", err)
+	}
+
+	if !strings.Contains(email.Text, "No changes.") {
+		t.Fatalf("expected no changes in text got %q", email.Text)
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/notify"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/pkg/errors"
+	"github.com/segmentio/fasthash/fnv1a"
 )
 
+// alerts that fail to send are retried until the oldest is this old
+const alertMaxRetryAge = time.Hour * 24
+
+type AlertType = string
+
 const (
-	// minimum amount of domains to send to 5
-	alertMinDomains = 5
-	// maximum age before minimum amount is irrelevant
-	alertMaxAge time.Duration = time.Minute * 60
+	// records or whois have changed
+	AlertTypeChanges AlertType = "changes"
+	// records have drifted from the expected records
+	AlertTypeDrift AlertType = "drift"
 )
 
 type Alert struct {
@@ -26,6 +35,13 @@ type Alert struct {
 	OwnerID int       `pg:",notnull"`
 	Owner   user.User `pg:"fk:owner_id,rel:has-one"`
 
+	AlertType AlertType `pg:",notnull,type:text,default:'changes'"`
+
+	// key of the notification the alert was first sent in, failed batches
+	// are retried as they were so destinations that already have them are
+	// skipped, see notify.Sent
+	Batch string `pg:",notnull,use_zero"`
+
 	Response Job
 
 	CreatedAt time.Time `pg:",notnull,default:now()"`
@@ -50,45 +66,75 @@ func (m *Manager) sendAlerts(ctx context.Context) error {
 		case <-ticker.C:
 			// get all alerts
 			var alerts []Alert
-			if err := m.db.Model(&alerts).Select(); err != nil {
+			if err := m.db.Model(&alerts).Relation("Owner").Select(); err != nil {
 				return err
 			}
 
-			// sort in to owner batches
+			// sort new alerts in to owner batches and failed alerts in
+			// to the batch they were sent in
 			sorted := make(map[int][]Alert, 0)
+			retries := make(map[string][]Alert, 0)
 
 			for _, a := range alerts {
-				if _, ok := sorted[a.OwnerID]; !ok {
-					sorted[a.OwnerID] = []Alert{}
+				if len(a.Batch) > 0 {
+					retries[a.Batch] = append(retries[a.Batch], a)
+					continue
 				}
 				sorted[a.OwnerID] = append(sorted[a.OwnerID], a)
 			}
 
+			now := time.Now()
+
+			batches := make([][]Alert, 0, len(retries))
+			for _, batch := range retries {
+				if batch[0].Owner.InQuietHours(now) {
+					continue
+				}
+				batches = append(batches, batch)
+			}
+
 			for owner, alerts := range sorted {
-				// check minimum threshold
-				if len(alerts) < alertMinDomains {
-					var handle bool
-					for _, a := range alerts {
-						if time.Since(a.CreatedAt) > alertMaxAge {
-							handle = true
-							break
-						}
-					}
+				// check the owner's batching settings
+				if !alerts[0].Owner.AlertsDue(len(alerts), oldestAlert(alerts), now) {
+					continue
+				}
 
-					if !handle {
+				for _, batch := range splitAlerts(alerts) {
+					if err := m.startBatch(batch); err != nil {
+						log.Printf("Error starting alert batch for owner %d: %s", owner, err)
 						continue
 					}
+					batches = append(batches, batch)
 				}
+			}
+
+			for _, batch := range batches {
+				owner := batch[0].OwnerID
+				oldest := oldestAlert(batch)
 
-				if err := m.handleAlerts(alerts); err != nil {
+				// keep the alerts to retry on the next tick, emails are
+				// only queued and chat posts only sent once per
+				// destination so retrying does not send duplicates
+				if err := m.handleAlerts(ctx, batch); err != nil {
 					log.Printf("Error handling alerts for owner %d: %s", owner, err)
+
+					if now.Sub(oldest) < alertMaxRetryAge {
+						continue
+					}
+
+					log.Printf("Dropping %d alerts for owner %d after %s", len(batch), owner, alertMaxRetryAge)
 				}
 
-				if _, err := m.db.Model(&alerts).Delete(); err != nil {
+				if _, err := m.db.Model(&batch).Delete(); err != nil {
 					return err
 				}
 			}
 
+			// alerts are no longer retried so neither are their chat posts
+			if _, err := notify.PruneSent(m.db, now.Add(-alertMaxRetryAge*2)); err != nil {
+				log.Printf("Error pruning sent notifications: %s", err)
+			}
+
 			// find domains about to expire
 			var whois []domain.Whois
 
@@ -107,7 +153,7 @@ func (m *Manager) sendAlerts(ctx context.Context) error {
 				return err
 			}
 
-			if err := m.handleExpirationAlerts(whois); err != nil {
+			if err := m.handleExpirationAlerts(ctx, whois); err != nil {
 				log.Printf("Error handling expiration alerts: %s", err)
 			}
 
@@ -120,99 +166,234 @@ func (m *Manager) sendAlerts(ctx context.Context) error {
 	return nil
 }
 
-func (m *Manager) handleExpirationAlerts(whois []domain.Whois) error {
+// handleExpirationAlerts notifies owners of domains about to expire, trying
+// every domain and returning the first error. A domain is only marked as
+// alerted once it has been notified, the notification key is the same on
+// each attempt so destinations that already have it are skipped.
+func (m *Manager) handleExpirationAlerts(ctx context.Context, whois []domain.Whois) error {
+	var firstErr error
+
 	for _, w := range whois {
-		var ea = ExpirationAlert{
-			DomainID: w.DomainID,
-		}
-		if _, err := m.db.Model(&ea).Insert(); err != nil {
-			return err
+		if err := m.handleExpirationAlert(ctx, w); err != nil {
+			err = errors.WithMessagef(err, "domain %d", w.DomainID)
+			log.Printf("Error handling expiration alert: %s", err)
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
+	}
+
+	return firstErr
+}
+
+func (m *Manager) handleExpirationAlert(ctx context.Context, w domain.Whois) error {
+	key := fmt.Sprintf("expiry:%d:%s", w.DomainID, w.ExpirationDate.Format("2006-01-02"))
+
+	message := fmt.Sprintf("Your domain will expire in 7 days on %s", w.ExpirationDate.Format("2006-01-02"))
+
+	incident, err := Incident{
+		Key:       key,
+		OwnerID:   w.Domain.OwnerID,
+		DomainID:  w.DomainID,
+		AlertType: AlertTypeExpiry,
+		Summary:   message,
+		Additions: make([]string, 0),
+		Removals:  make([]string, 0),
+		Lines:     make([]string, 0),
+		State:     IncidentOpen,
+		Token:     uniuri.NewLen(32),
+	}.open(m.db)
+	if err != nil {
+		return err
+	}
+
+	n := notify.Notification{
+		Key:     key,
+		Subject: fmt.Sprintf("ALARM BELLS - %s expires in 7 days", w.Domain.Domain),
+		Items: []notify.Item{
+			{
+				Type:           notify.ItemExpiry,
+				DomainID:       w.DomainID,
+				Domain:         w.Domain.Domain,
+				Groups:         w.Domain.Tags,
+				URL:            m.domainURL(w.Domain.Domain),
+				AcknowledgeURL: m.acknowledgeURL(incident),
+				Message:        message,
+			},
+		},
+	}
 
-		subject := fmt.Sprintf("ALARM BELLS - %s expires in 7 days", w.Domain.Domain)
+	if err := m.notify(ctx, w.Domain.OwnerID, w.Domain.OrganisationID, n); err != nil {
+		return errors.WithMessage(err, "notify")
+	}
 
-		body := fmt.Sprintf(
-			"Your domain will expire in 7 days for more information visit: https://%s/domain/%s\n\n",
-			os.Getenv("DOMAIN"),
-			w.Domain.Domain,
-		)
+	var ea = ExpirationAlert{
+		DomainID: w.DomainID,
+	}
+	if _, err := m.db.Model(&ea).Insert(); err != nil {
+		return errors.Wrap(err, "Insert ExpirationAlert")
+	}
 
-		var owner user.User
+	m.queueExpirationWebhook(w)
+
+	return nil
+}
 
-		if err := m.db.Model(&owner).Where("id = ?", w.Domain.OwnerID).Select(); err != nil {
-			return errors.WithMessage(err, "Select Owner")
+// oldestAlert returns when the oldest of the alerts was created
+func oldestAlert(alerts []Alert) time.Time {
+	oldest := alerts[0].CreatedAt
+	for _, a := range alerts {
+		if a.CreatedAt.Before(oldest) {
+			oldest = a.CreatedAt
 		}
+	}
+	return oldest
+}
 
-		if err := m.emailer.Send(owner.Email, subject, body); err != nil {
-			return err
+// splitAlerts splits alerts by type and organisation so that each is sent
+// to the organisation's channels, and if need be retried, on its own
+func splitAlerts(alerts []Alert) [][]Alert {
+	type key struct {
+		alertType      AlertType
+		organisationID int
+	}
+
+	batches := make([][]Alert, 0, 2)
+	index := make(map[key]int)
+
+	for _, a := range alerts {
+		k := key{AlertTypeChanges, a.Response.Domain.OrganisationID}
+		if a.AlertType == AlertTypeDrift {
+			k.alertType = AlertTypeDrift
 		}
+
+		idx, ok := index[k]
+		if !ok {
+			idx = len(batches)
+			index[k] = idx
+			batches = append(batches, nil)
+		}
+
+		batches[idx] = append(batches[idx], a)
 	}
 
-	return nil
+	return batches
 }
 
-func (m *Manager) handleAlerts(alerts []Alert) error {
-	subject := fmt.Sprintf("ALARM BELLS - Changes to %d domains", len(alerts))
+// startBatch stores the batch's key on its alerts so that alerts created
+// before it is retried are not added to it
+func (m *Manager) startBatch(alerts []Alert) error {
+	key := alertsKey(alerts)
 
-	var body strings.Builder
+	ids := make([]int, 0, len(alerts))
+	for i := range alerts {
+		alerts[i].Batch = key
+		ids = append(ids, alerts[i].ID)
+	}
 
-	var ownerID int
+	_, err := m.db.Model((*Alert)(nil)).
+		Set("batch = ?", key).
+		Where("id IN (?)", pg.In(ids)).
+		Update()
 
-	for _, alert := range alerts {
-		response := alert.Response
+	return err
+}
 
-		fmt.Fprintf(&body, "<pre>")
+// alertsKey identifies a batch of alerts, a batch that is retried keeps
+// its key. Alerts that are not batched are only stored once they fail to
+// send so the jobs are used instead.
+func alertsKey(alerts []Alert) string {
+	ids := make([]int, 0, len(alerts))
+	for _, a := range alerts {
+		ids = append(ids, a.Response.ID)
+	}
+	sort.Ints(ids)
 
-		fmt.Fprintf(
-			&body,
-			"New changes have been detected, please go to: https://%s/domain/%s for more details or find a summary of the changes below.\n\n",
-			os.Getenv("DOMAIN"),
-			response.Domain.Domain,
-		)
+	var sb strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&sb, "%d,", id)
+	}
 
-		if response.WhoisUpdated {
-			fmt.Fprintf(
-				&body,
-				"Whois has been updated!\n\n",
-			)
-		}
+	return fmt.Sprintf("alerts:%s:%x", alerts[0].AlertType, fnv1a.HashString64(sb.String()))
+}
 
-		for idx, record := range response.RecordAdditions {
-			if idx == 0 {
-				fmt.Fprintf(&body, "-------------------------------- / additions start\n")
-			}
-			fmt.Fprintf(&body, "\t+++\t%s\n", record.Raw)
-		}
+// handleAlerts sends alerts of a single type
+func (m *Manager) handleAlerts(ctx context.Context, alerts []Alert) error {
+	if alerts[0].AlertType == AlertTypeDrift {
+		return m.handleDriftAlerts(ctx, alerts)
+	}
 
-		for idx, record := range response.RecordRemovals {
-			if idx == 0 {
-				fmt.Fprintf(&body, "-------------------------------- / removals start\n")
-			}
-			fmt.Fprintf(&body, "\t---\t%s\n", record.Raw)
-		}
+	return m.handleChangeAlerts(ctx, alerts)
+}
 
-		fmt.Fprintf(&body, "-------------------------------- / end\n")
+func (m *Manager) handleDriftAlerts(ctx context.Context, alerts []Alert) error {
+	n := notify.Notification{
+		Key:     alertsKey(alerts),
+		Subject: fmt.Sprintf("DRIFT - %d domains differ from their expected records", len(alerts)),
+	}
 
-		fmt.Fprintf(&body, "</pre>")
+	for _, alert := range alerts {
+		response := alert.Response
+		message := fmt.Sprintf("Records for %s no longer match the expected records", response.Domain.Domain)
 
-		if ownerID == 0 {
-			ownerID = response.Domain.OwnerID
+		incident, err := NewIncident(alert, message).open(m.db)
+		if err != nil {
+			return err
 		}
-	}
 
-	if ownerID == 0 {
-		return nil
+		n.Items = append(n.Items, notify.Item{
+			Type:           notify.ItemDrift,
+			DomainID:       response.DomainID,
+			Domain:         response.Domain.Domain,
+			Groups:         response.Domain.Tags,
+			URL:            m.domainURL(response.Domain.Domain),
+			AcknowledgeURL: m.acknowledgeURL(incident),
+			Message:        message,
+			Lines:          response.Drift,
+		})
 	}
 
-	var owner user.User
+	response := alerts[0].Response
+	return m.notify(ctx, response.Domain.OwnerID, response.Domain.OrganisationID, n)
+}
 
-	if err := m.db.Model(&owner).Where("id = ?", ownerID).Select(); err != nil {
-		return errors.WithMessage(err, "Select Owner")
+func (m *Manager) handleChangeAlerts(ctx context.Context, alerts []Alert) error {
+	n := notify.Notification{
+		Key:     alertsKey(alerts),
+		Subject: fmt.Sprintf("ALARM BELLS - Changes to %d domains", len(alerts)),
 	}
 
-	if err := m.emailer.Send(owner.Email, subject, body.String()); err != nil {
-		return err
+	for _, alert := range alerts {
+		response := alert.Response
+
+		item := notify.Item{
+			Type:     notify.ItemChanges,
+			DomainID: response.DomainID,
+			Domain:   response.Domain.Domain,
+			Groups:   response.Domain.Tags,
+			URL:      m.domainURL(response.Domain.Domain),
+			Message:  "New changes have been detected",
+			Whois:    response.WhoisUpdated,
+			Tags:     response.Tags,
+		}
+
+		incident, err := NewIncident(alert, item.Summary()).open(m.db)
+		if err != nil {
+			return err
+		}
+		item.AcknowledgeURL = m.acknowledgeURL(incident)
+
+		for _, record := range response.RecordAdditions {
+			item.Additions = append(item.Additions, notify.Record{RRType: record.RRType.String(), Raw: record.Raw})
+		}
+
+		for _, record := range response.RecordRemovals {
+			item.Removals = append(item.Removals, notify.Record{RRType: record.RRType.String(), Raw: record.Raw})
+		}
+
+		n.Items = append(n.Items, item)
 	}
 
-	return nil
+	response := alerts[0].Response
+	return m.notify(ctx, response.Domain.OwnerID, response.Domain.OrganisationID, n)
 }
@@ -0,0 +1,60 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+)
+
+func Test_SplitAlerts(t *testing.T) {
+	t.Parallel()
+
+	alerts := []Alert{
+		{ID: 1, AlertType: AlertTypeChanges, Response: Job{ID: 1}},
+		{ID: 2, AlertType: AlertTypeDrift, Response: Job{ID: 2}},
+		{ID: 3, AlertType: AlertTypeChanges, Response: Job{ID: 3}},
+		{ID: 4, AlertType: AlertTypeChanges, Response: Job{ID: 4, Domain: domain.Domain{OrganisationID: 5}}},
+	}
+
+	batches := splitAlerts(alerts)
+	if len(batches) != 3 {
+		t.Fatalf("splitAlerts() expected 3 batches got %d", len(batches))
+	}
+
+	if len(batches[0]) != 2 || batches[0][0].ID != 1 || batches[0][1].ID != 3 {
+		t.Fatalf("splitAlerts() unexpected changes batch: %+v", batches[0])
+	}
+
+	if len(batches[1]) != 1 || batches[1][0].ID != 2 {
+		t.Fatalf("splitAlerts() unexpected drift batch: %+v", batches[1])
+	}
+
+	if len(batches[2]) != 1 || batches[2][0].ID != 4 {
+		t.Fatalf("splitAlerts() expected the organisation's alert on its own got %+v", batches[2])
+	}
+
+	if keys := alertsKey(batches[0]); keys == alertsKey(batches[1]) {
+		t.Fatalf("alertsKey() expected batches to have different keys got %q", keys)
+	}
+
+	if batches := splitAlerts(alerts[:1]); len(batches) != 1 {
+		t.Fatalf("splitAlerts() expected 1 batch got %d", len(batches))
+	}
+}
+
+func Test_OldestAlert(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	alerts := []Alert{
+		{CreatedAt: now},
+		{CreatedAt: now.Add(-time.Hour)},
+		{CreatedAt: now.Add(-time.Minute)},
+	}
+
+	if oldest := oldestAlert(alerts); !oldest.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("oldestAlert() expected %s got %s", now.Add(-time.Hour), oldest)
+	}
+}
@@ -0,0 +1,316 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maximum records of each kind included in a digest
+	digestMaxRecords = 1000
+	// include domains expiring within this window after the digest
+	digestExpiryWindow = time.Hour * 24 * 30
+)
+
+// DigestChanges are the changes to a single domain during a digest period
+type DigestChanges struct {
+	Domain       string   `json:"domain"`
+	URL          string   `json:"url"`
+	Additions    []string `json:"additions"`
+	Removals     []string `json:"removals"`
+	WhoisUpdates int      `json:"whois_updates"`
+}
+
+// DigestExpiration is a domain that expires soon after the digest period
+type DigestExpiration struct {
+	Domain         string    `json:"domain"`
+	URL            string    `json:"url"`
+	ExpirationDate time.Time `json:"expiration_date"`
+	Days           int       `json:"days"`
+}
+
+// DigestFailure is a scan that failed during the digest period
+type DigestFailure struct {
+	Domain     string    `json:"domain"`
+	URL        string    `json:"url"`
+	JobID      int       `json:"job_id"`
+	Errors     []string  `json:"errors"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Digest summarises a user's portfolio over a period
+type Digest struct {
+	ID int `pg:",pk" json:"id"`
+
+	OwnerID int       `pg:",notnull,unique:digest_owner_period" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	Frequency   user.DigestFrequency `pg:",notnull" json:"frequency"`
+	PeriodStart time.Time            `pg:",type:timestamptz,notnull" json:"period_start"`
+	PeriodEnd   time.Time            `pg:",type:timestamptz,notnull,unique:digest_owner_period" json:"period_end"`
+
+	Changes     []DigestChanges    `pg:",use_zero" json:"changes"`
+	Expirations []DigestExpiration `pg:",use_zero" json:"expirations"`
+	Failures    []DigestFailure    `pg:",use_zero" json:"failures"`
+
+	CreatedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+	SentAt    time.Time `pg:",type:timestamptz" json:"sent_at"`
+}
+
+// Empty returns true if there is nothing to report
+func (d Digest) Empty() bool {
+	return len(d.Changes) == 0 && len(d.Expirations) == 0 && len(d.Failures) == 0
+}
+
+// GetDigests returns the owner's digests, newest first, without their
+// contents
+func GetDigests(db orm.DB, ownerID int) ([]Digest, error) {
+	digests := make([]Digest, 0)
+	err := db.Model(&digests).
+		Column("id", "owner_id", "frequency", "period_start", "period_end", "created_at", "sent_at").
+		Where("owner_id = ?", ownerID).
+		Order("period_end DESC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// GetDigest returns one of the owner's digests
+func GetDigest(db orm.DB, ownerID, id int) (Digest, error) {
+	var d Digest
+	err := db.Model(&d).Where("id = ? AND owner_id = ?", id, ownerID).Select()
+	return d, err
+}
+
+// buildDigest gathers everything that happened to the owner's domains
+// between start and end
+func (m *Manager) buildDigest(owner user.User, start, end time.Time) (Digest, error) {
+	d := Digest{
+		OwnerID:     owner.ID,
+		Frequency:   owner.DigestFrequency,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Changes:     make([]DigestChanges, 0),
+		Expirations: make([]DigestExpiration, 0),
+		Failures:    make([]DigestFailure, 0),
+	}
+
+	changes := make(map[string]*DigestChanges)
+	get := func(name string) *DigestChanges {
+		if c, ok := changes[name]; ok {
+			return c
+		}
+		c := &DigestChanges{
+			Domain:    name,
+			URL:       m.domainURL(name),
+			Additions: make([]string, 0),
+			Removals:  make([]string, 0),
+		}
+		changes[name] = c
+		return c
+	}
+
+	var added, removed domain.Records
+
+	err := m.db.Model(&added).
+		Relation("Domain").
+		Where("domain.owner_id = ?", owner.ID).
+		Where("record.added_at >= ? AND record.added_at < ?", start, end).
+		Order("record.added_at").
+		Limit(digestMaxRecords).
+		Select()
+	if err != nil {
+		return d, errors.WithMessage(err, "Select additions")
+	}
+
+	for _, r := range added {
+		c := get(r.Domain.Domain)
+		c.Additions = append(c.Additions, r.Raw)
+	}
+
+	err = m.db.Model(&removed).
+		Relation("Domain").
+		Where("domain.owner_id = ?", owner.ID).
+		Where("record.removed_at >= ? AND record.removed_at < ?", start, end).
+		Order("record.removed_at").
+		Limit(digestMaxRecords).
+		Select()
+	if err != nil {
+		return d, errors.WithMessage(err, "Select removals")
+	}
+
+	for _, r := range removed {
+		c := get(r.Domain.Domain)
+		c.Removals = append(c.Removals, r.Raw)
+	}
+
+	var whois []domain.Whois
+
+	err = m.db.Model(&whois).
+		Column("whois.id", "whois.domain_id").
+		Relation("Domain").
+		Where("domain.owner_id = ?", owner.ID).
+		Where("whois.added_at >= ? AND whois.added_at < ?", start, end).
+		Select()
+	if err != nil {
+		return d, errors.WithMessage(err, "Select whois")
+	}
+
+	for _, w := range whois {
+		get(w.Domain.Domain).WhoisUpdates++
+	}
+
+	for _, c := range changes {
+		d.Changes = append(d.Changes, *c)
+	}
+
+	sort.Slice(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Domain < d.Changes[j].Domain
+	})
+
+	// latest whois for each domain
+	var latest []domain.Whois
+
+	err = m.db.Model(&latest).
+		Column("whois.domain_id", "whois.expiration_date").
+		DistinctOn("whois.domain_id").
+		Relation("Domain").
+		Where("domain.owner_id = ?", owner.ID).
+		Order("whois.domain_id", "whois.added_at DESC").
+		Select()
+	if err != nil {
+		return d, errors.WithMessage(err, "Select expirations")
+	}
+
+	for _, w := range latest {
+		if w.ExpirationDate.Before(end) || w.ExpirationDate.After(end.Add(digestExpiryWindow)) {
+			continue
+		}
+
+		d.Expirations = append(d.Expirations, DigestExpiration{
+			Domain:         w.Domain.Domain,
+			URL:            m.domainURL(w.Domain.Domain),
+			ExpirationDate: w.ExpirationDate,
+			Days:           int(w.ExpirationDate.Sub(end).Hours() / 24),
+		})
+	}
+
+	sort.Slice(d.Expirations, func(i, j int) bool {
+		return d.Expirations[i].ExpirationDate.Before(d.Expirations[j].ExpirationDate)
+	})
+
+	// jobs whose latest attempt failed
+	var failed []Job
+
+	err = m.db.Model(&failed).
+		Column("job.id", "job.domain_id", "job.errors", "job.finished_at").
+		Relation("Domain").
+		Where("domain.owner_id = ?", owner.ID).
+		Where("jsonb_array_length(job.errors) > 0").
+		Where("job.finished_at >= ? AND job.finished_at < ?", start, end).
+		Order("job.finished_at").
+		Limit(digestMaxRecords).
+		Select()
+	if err != nil {
+		return d, errors.WithMessage(err, "Select failures")
+	}
+
+	for _, j := range failed {
+		d.Failures = append(d.Failures, DigestFailure{
+			Domain:     j.Domain.Domain,
+			URL:        m.domainURL(j.Domain.Domain),
+			JobID:      j.ID,
+			Errors:     j.Errors,
+			FinishedAt: j.FinishedAt,
+		})
+	}
+
+	return d, nil
+}
+
+// sendDigests sends digests to users who have opted in once their
+// scheduled time has passed
+func (m *Manager) sendDigests(ctx context.Context) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		var users []user.User
+		if err := m.db.Model(&users).Where("digest_frequency != ''").Select(); err != nil {
+			return errors.WithMessage(err, "Select users")
+		}
+
+		now := time.Now()
+
+		for _, u := range users {
+			start, end, ok := u.DigestDue(now)
+			if !ok {
+				continue
+			}
+
+			if err := m.sendDigest(u, start, end); err != nil {
+				log.Printf("Error sending digest to user %d: %s", u.ID, err)
+			}
+		}
+	}
+}
+
+func (m *Manager) sendDigest(u user.User, start, end time.Time) error {
+	d, err := m.buildDigest(u, start, end)
+	if err != nil {
+		return err
+	}
+
+	// the period is unique per owner so a digest is never stored twice
+	res, err := m.db.Model(&d).
+		OnConflict("(owner_id, period_end) DO NOTHING").
+		Returning("id").
+		Insert()
+	if err != nil {
+		return errors.WithMessage(err, "Insert digest")
+	}
+
+	// stored by an earlier attempt, queue that one instead
+	if res.RowsAffected() == 0 {
+		err := m.db.Model(&d).Where("owner_id = ? AND period_end = ?", u.ID, end).Select()
+		if err != nil {
+			return errors.WithMessage(err, "Select digest")
+		}
+	}
+
+	if d.SentAt.IsZero() {
+		key := fmt.Sprintf("digest:%d", d.ID)
+		if err := m.emailer.Queue(m.db, key, u.Email, u.Locale, "digest", d); err != nil {
+			return errors.WithMessage(err, "Queue")
+		}
+
+		_, err = m.db.Model(&d).Set("sent_at = now()").WherePK().Update()
+		if err != nil {
+			return errors.WithMessage(err, "Update sent_at")
+		}
+	}
+
+	// only marked once queued so failures are retried
+	_, err = m.db.Model(&u).Set("last_digest_at = ?", end).WherePK().Update()
+	if err != nil {
+		return errors.WithMessage(err, "Update last_digest_at")
+	}
+
+	return nil
+}
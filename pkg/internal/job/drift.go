@@ -0,0 +1,52 @@
+package job
+
+import (
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// handleDrift compares the domain's current records against its expected
+// records, storing the drift on the response. Returns true if the drift
+// has changed since the previous job and is not empty.
+func handleDrift(db orm.DB, response *Job) (bool, error) {
+	expected, err := response.Domain.GetExpectedRecords(db)
+	if err != nil {
+		return false, errors.WithMessage(err, "GetExpectedRecords")
+	}
+
+	if len(expected) == 0 {
+		return false, nil
+	}
+
+	current, err := response.Domain.GetRecords(db)
+	if err != nil {
+		return false, errors.WithMessage(err, "GetRecords")
+	}
+
+	response.Drift = domain.CompareExpected(expected, current).Lines()
+
+	if len(response.Drift) == 0 {
+		return false, nil
+	}
+
+	previous, err := GetPreviousJob(db, response.DomainID, response.ID)
+	if err != nil && err != pg.ErrNoRows {
+		return false, errors.WithMessage(err, "GetPreviousJob")
+	}
+
+	return !driftEqual(previous.Drift, response.Drift), nil
+}
+
+func driftEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
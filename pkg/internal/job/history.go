@@ -0,0 +1,93 @@
+package job
+
+import (
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// maximum additions and removals each returned by GetChanges
+const changeLimit = 5000
+
+// Change is a record that a job found added or removed
+type Change struct {
+	tableName struct{} `pg:"records,alias:record"`
+
+	domain.Record
+
+	// the job that found the change, 0 if it can not be found
+	JobID int `json:"job_id"`
+
+	// the record's domain name, set by callers
+	DomainName string `pg:"-" json:"domain"`
+
+	Removed bool      `pg:"-" json:"removed"`
+	At      time.Time `pg:"-" json:"at"`
+}
+
+// the job that finished most recently before the column
+func jobBefore(column string) string {
+	return "(SELECT j.id FROM jobs AS j WHERE j.domain_id = record.domain_id AND j.finished_at <= record." + column + " ORDER BY j.finished_at DESC LIMIT 1) AS job_id"
+}
+
+// GetChanges returns the domains' record additions and removals since the
+// time, newest first. Records found by a domain's first job are its
+// baseline rather than changes and manually added records were never found
+// by a job so both are left out
+func GetChanges(db orm.DB, domainIDs []int, since time.Time) ([]Change, error) {
+	if len(domainIDs) == 0 {
+		return make([]Change, 0), nil
+	}
+
+	additions := make([]Change, 0)
+	err := db.Model(&additions).
+		ColumnExpr("record.*").
+		ColumnExpr(jobBefore("added_at")).
+		Where("record.domain_id IN (?)", pg.In(domainIDs)).
+		Where("record.added_at >= ?", since).
+		Where("record.record_source != ?", domain.RecordSourceManual).
+		Where("(SELECT count(*) FROM jobs AS j WHERE j.domain_id = record.domain_id AND j.finished_at <= record.added_at) > 1").
+		Order("record.added_at DESC").
+		Limit(changeLimit).
+		Select()
+	if err != nil {
+		return nil, errors.WithMessage(err, "Select additions")
+	}
+
+	removals := make([]Change, 0)
+	err = db.Model(&removals).
+		ColumnExpr("record.*").
+		ColumnExpr(jobBefore("removed_at")).
+		Where("record.domain_id IN (?)", pg.In(domainIDs)).
+		Where("record.removed_at >= ?", since).
+		Where("record.record_source != ?", domain.RecordSourceManual).
+		Order("record.removed_at DESC").
+		Limit(changeLimit).
+		Select()
+	if err != nil {
+		return nil, errors.WithMessage(err, "Select removals")
+	}
+
+	changes := make([]Change, 0, len(additions)+len(removals))
+
+	for _, c := range additions {
+		c.At = c.AddedAt
+		changes = append(changes, c)
+	}
+
+	for _, c := range removals {
+		c.Removed = true
+		c.At = c.RemovedAt
+		changes = append(changes, c)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.After(changes[j].At)
+	})
+
+	return changes, nil
+}
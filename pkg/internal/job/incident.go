@@ -0,0 +1,228 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// the domain is about to expire, only used for incidents
+const AlertTypeExpiry AlertType = "expiry"
+
+// IncidentState tracks whether an incident has been reviewed:
+//
+//	open -> acknowledged -> resolved
+//	     -> muted
+//
+// Any state can be moved to any other, for example to reopen a resolved
+// incident. Drift incidents are resolved automatically once the records
+// match the expected records again.
+type IncidentState = string
+
+const (
+	IncidentOpen         IncidentState = "open"
+	IncidentAcknowledged IncidentState = "acknowledged"
+	IncidentResolved     IncidentState = "resolved"
+	IncidentMuted        IncidentState = "muted"
+)
+
+// maximum incidents returned by GetIncidents
+const incidentLimit = 200
+
+// Incident is an alert that has been sent, kept so that it can be
+// acknowledged
+type Incident struct {
+	ID int `pg:",pk" json:"id"`
+
+	// identifies what raised the incident so it is only stored once
+	Key string `pg:",notnull,unique" json:"-"`
+
+	OwnerID int       `pg:",notnull" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	DomainID int           `pg:",notnull" json:"domain_id"`
+	Domain   domain.Domain `pg:"fk:domain_id,rel:has-one" json:"domain"`
+
+	JobID     int       `pg:",use_zero" json:"job_id"`
+	AlertType AlertType `pg:",notnull" json:"alert_type"`
+	Summary   string    `pg:",notnull" json:"summary"`
+
+	Additions []string `pg:",use_zero" json:"additions"`
+	Removals  []string `pg:",use_zero" json:"removals"`
+	Lines     []string `pg:",use_zero" json:"lines"`
+
+	State IncidentState `pg:",notnull" json:"state"`
+
+	// used for one click acknowledge links
+	Token string `pg:",notnull,unique" json:"-"`
+
+	CreatedAt      time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+	UpdatedAt      time.Time `pg:",type:timestamptz,notnull,default:now()" json:"updated_at"`
+	AcknowledgedAt time.Time `pg:",type:timestamptz" json:"acknowledged_at"`
+	AcknowledgedBy string    `pg:",notnull,use_zero" json:"acknowledged_by"`
+	ResolvedAt     time.Time `pg:",type:timestamptz" json:"resolved_at"`
+}
+
+// NewIncident creates an open incident for an alert
+func NewIncident(a Alert, summary string) Incident {
+	response := a.Response
+
+	i := Incident{
+		Key:       fmt.Sprintf("job:%d:%s", response.ID, a.AlertType),
+		OwnerID:   response.Domain.OwnerID,
+		DomainID:  response.DomainID,
+		JobID:     response.ID,
+		AlertType: a.AlertType,
+		Summary:   summary,
+		Additions: make([]string, 0, len(response.RecordAdditions)),
+		Removals:  make([]string, 0, len(response.RecordRemovals)),
+		Lines:     make([]string, 0),
+		State:     IncidentOpen,
+		Token:     uniuri.NewLen(32),
+	}
+
+	if i.AlertType == AlertTypeDrift {
+		i.Lines = append(i.Lines, response.Drift...)
+		return i
+	}
+
+	for _, r := range response.RecordAdditions {
+		i.Additions = append(i.Additions, r.Raw)
+	}
+
+	for _, r := range response.RecordRemovals {
+		i.Removals = append(i.Removals, r.Raw)
+	}
+
+	return i
+}
+
+// ValidIncidentState returns true if the state is known
+func ValidIncidentState(state IncidentState) bool {
+	switch state {
+	case IncidentOpen, IncidentAcknowledged, IncidentResolved, IncidentMuted:
+		return true
+	}
+	return false
+}
+
+// Transition moves the incident to the state, by is who made the change
+func (i *Incident) Transition(state IncidentState, by string, now time.Time) error {
+	if !ValidIncidentState(state) {
+		return errors.Errorf("unknown state %q", state)
+	}
+
+	switch state {
+	case IncidentOpen:
+		i.AcknowledgedAt = time.Time{}
+		i.AcknowledgedBy = ""
+		i.ResolvedAt = time.Time{}
+	case IncidentAcknowledged:
+		i.AcknowledgedAt = now
+		i.AcknowledgedBy = by
+	case IncidentResolved:
+		i.ResolvedAt = now
+	}
+
+	i.State = state
+	i.UpdatedAt = now
+
+	return nil
+}
+
+// Update stores the incident's state
+func (i *Incident) Update(db orm.DB) error {
+	_, err := db.Model(i).
+		Set("state = ?state, updated_at = ?updated_at, acknowledged_at = ?acknowledged_at, acknowledged_by = ?acknowledged_by, resolved_at = ?resolved_at").
+		WherePK().
+		Update()
+	return err
+}
+
+// open stores the incident unless one already exists for its key, in which
+// case the existing incident is returned
+func (i Incident) open(db orm.DB) (Incident, error) {
+	res, err := db.Model(&i).
+		OnConflict("(key) DO NOTHING").
+		Returning("id").
+		Insert()
+	if err != nil {
+		return i, errors.WithMessage(err, "Insert incident")
+	}
+
+	if res.RowsAffected() == 1 {
+		return i, nil
+	}
+
+	var existing Incident
+	if err := db.Model(&existing).Where("key = ?", i.Key).Select(); err != nil {
+		return i, errors.WithMessage(err, "Select incident")
+	}
+
+	return existing, nil
+}
+
+// GetIncidents returns the owner's most recent incidents for the
+// organisation's domains, 0 for the owner's own domains, optionally
+// filtered by state and domain
+func GetIncidents(db orm.DB, ownerID, organisationID int, state IncidentState, domainName string) ([]Incident, error) {
+	incidents := make([]Incident, 0)
+
+	query := db.Model(&incidents).
+		Relation("Domain").
+		Where("incident.owner_id = ? AND domain.organisation_id = ?", ownerID, organisationID).
+		Order("incident.created_at DESC", "incident.id DESC").
+		Limit(incidentLimit)
+
+	if len(state) > 0 {
+		query = query.Where("incident.state = ?", state)
+	}
+
+	if len(domainName) > 0 {
+		query = query.Where("domain.domain = ?", domainName)
+	}
+
+	if err := query.Select(); err != nil {
+		return nil, err
+	}
+
+	return incidents, nil
+}
+
+// GetIncident returns one of the owner's incidents for the organisation's
+// domains, 0 for the owner's own domains
+func GetIncident(db orm.DB, ownerID, organisationID, id int) (Incident, error) {
+	var i Incident
+	err := db.Model(&i).
+		Relation("Domain").
+		Where("incident.id = ? AND incident.owner_id = ? AND domain.organisation_id = ?", id, ownerID, organisationID).
+		Select()
+	return i, err
+}
+
+// GetIncidentByToken returns the incident for a one click acknowledge link
+func GetIncidentByToken(db orm.DB, token string) (Incident, error) {
+	var i Incident
+	err := db.Model(&i).
+		Relation("Domain").
+		Relation("Owner").
+		Where("incident.token = ?", token).
+		Select()
+	return i, err
+}
+
+// resolveDrift resolves the domain's drift incidents once its records
+// match the expected records again
+func resolveDrift(db orm.DB, domainID int) error {
+	_, err := db.Model((*Incident)(nil)).
+		Set("state = ?, resolved_at = now(), updated_at = now()", IncidentResolved).
+		Where("domain_id = ? AND alert_type = ?", domainID, AlertTypeDrift).
+		Where("state IN (?, ?)", IncidentOpen, IncidentAcknowledged).
+		Update()
+	return err
+}
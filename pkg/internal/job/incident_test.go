@@ -0,0 +1,92 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+)
+
+func Test_NewIncident(t *testing.T) {
+	t.Parallel()
+
+	response := Job{
+		ID:       7,
+		DomainID: 3,
+		Domain:   domain.Domain{ID: 3, Domain: "example.com", OwnerID: 2},
+		RecordAdditions: domain.Records{
+			{Raw: "example.com.\t300\tIN\tA\t127.0.0.1"},
+		},
+		RecordRemovals: domain.Records{
+			{Raw: "example.com.\t300\tIN\tA\t127.0.0.2"},
+		},
+		Drift: []string{"missing A 127.0.0.3"},
+	}
+
+	changes := NewIncident(Alert{AlertType: AlertTypeChanges, Response: response}, "New changes")
+
+	if changes.Key != "job:7:changes" || changes.OwnerID != 2 || changes.DomainID != 3 || changes.JobID != 7 {
+		t.Fatalf("unexpected incident %+v", changes)
+	}
+
+	if changes.State != IncidentOpen || len(changes.Token) != 32 {
+		t.Fatalf("expected an open incident with a token got %+v", changes)
+	}
+
+	if len(changes.Additions) != 1 || len(changes.Removals) != 1 || len(changes.Lines) != 0 {
+		t.Fatalf("unexpected records %+v", changes)
+	}
+
+	drift := NewIncident(Alert{AlertType: AlertTypeDrift, Response: response}, "Drift")
+
+	if drift.Key != "job:7:drift" || len(drift.Additions) != 0 || len(drift.Lines) != 1 {
+		t.Fatalf("unexpected drift incident %+v", drift)
+	}
+
+	if drift.Token == changes.Token {
+		t.Fatal("expected unique tokens")
+	}
+}
+
+func Test_IncidentTransition(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var i Incident
+	i.State = IncidentOpen
+
+	if err := i.Transition("closed", "user@whois.bi", now); err == nil {
+		t.Fatal("Transition() expected an error for an unknown state")
+	}
+
+	if err := i.Transition(IncidentAcknowledged, "user@whois.bi", now); err != nil {
+		t.Fatalf("Transition() expected nil got %q", err)
+	}
+
+	if i.State != IncidentAcknowledged || !i.AcknowledgedAt.Equal(now) || i.AcknowledgedBy != "user@whois.bi" {
+		t.Fatalf("unexpected acknowledged incident %+v", i)
+	}
+
+	later := now.Add(time.Hour)
+
+	if err := i.Transition(IncidentResolved, "user@whois.bi", later); err != nil {
+		t.Fatalf("Transition() expected nil got %q", err)
+	}
+
+	if i.State != IncidentResolved || !i.ResolvedAt.Equal(later) || !i.AcknowledgedAt.Equal(now) {
+		t.Fatalf("unexpected resolved incident %+v", i)
+	}
+
+	if err := i.Transition(IncidentOpen, "user@whois.bi", later); err != nil {
+		t.Fatalf("Transition() expected nil got %q", err)
+	}
+
+	if !i.AcknowledgedAt.IsZero() || len(i.AcknowledgedBy) > 0 || !i.ResolvedAt.IsZero() {
+		t.Fatalf("expected reopening to clear the incident got %+v", i)
+	}
+
+	if err := i.Transition(IncidentMuted, "user@whois.bi", later); err != nil || i.State != IncidentMuted {
+		t.Fatalf("Transition() expected muted got %q %q", i.State, err)
+	}
+}
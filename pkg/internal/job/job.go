@@ -4,10 +4,32 @@ import (
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/list"
 	"github.com/pkg/errors"
 )
 
+type Priority = int
+
+const (
+	// scheduled jobs
+	PriorityNormal Priority = 0
+	// user triggered jobs, drained by workers first
+	PriorityHigh Priority = 10
+)
+
+const (
+	// queues jobs are published to for workers. These replace job.queue,
+	// which was declared without a dead letter queue and can not be
+	// redeclared with one
+	QueueNormal = "job.queue.normal"
+	QueueHigh   = "job.queue.high"
+
+	// queue workers publish responses to, replaces job.response
+	QueueResponse = "job.responses"
+)
+
 type Job struct {
 	ID int `pg:",pk" json:"id"`
 
@@ -16,10 +38,35 @@ type Job struct {
 
 	Errors []string `pg:",use_zero" json:"errors"`
 
+	// a failed whois lookup does not fail the job, only the lookup is
+	// retried
+	WhoisError string `pg:",use_zero" json:"whois_error"`
+
+	// should the worker also perform a whois lookup
+	CheckWhois bool `pg:",notnull,use_zero" json:"check_whois"`
+
 	Additions    int  `pg:",use_zero" json:"additions"`
 	Removals     int  `pg:",use_zero" json:"removals"`
 	WhoisUpdated bool `pg:",use_zero" json:"whois_updated"`
 
+	// differences between the expected and current records
+	Drift []string `pg:",use_zero" json:"drift"`
+
+	// added by tag lists matching the changes
+	Tags []string `pg:",use_zero" json:"tags"`
+
+	// how each change was evaluated against the owner's lists, returned
+	// by its own endpoint as it can be large
+	ListTrace []list.Evaluation `pg:",use_zero" json:"-"`
+
+	Priority Priority `pg:",notnull,use_zero" json:"priority"`
+
+	// dispatch state, see Status
+	Status         Status    `pg:",notnull,type:text,default:'queued'" json:"status"`
+	Attempts       int       `pg:",notnull,use_zero" json:"attempts"`
+	LeaseExpiresAt time.Time `pg:",type:timestamptz" json:"lease_expires_at"`
+	NextAttemptAt  time.Time `pg:",type:timestamptz" json:"next_attempt_at"`
+
 	CreatedAt  time.Time `pg:",notnull,default:now()" json:"created_at"`
 	StartedAt  time.Time `json:"started_at"`
 	FinishedAt time.Time `json:"finished_at"`
@@ -29,13 +76,22 @@ type Job struct {
 	CurrentRecords  domain.Records `pg:"-"`
 	RecordAdditions domain.Records `pg:"-"`
 	RecordRemovals  domain.Records `pg:"-"`
-	Whois           domain.Whois   `pg:"-"`
+
+	// changes matched by suppress lists, they are kept but not alerted on
+	SuppressedAdditions domain.Records `pg:"-"`
+	SuppressedRemovals  domain.Records `pg:"-"`
+
+	Whois domain.Whois `pg:"-"`
+
+	// matched by an escalate list, alert without batching
+	Escalated bool `pg:"-"`
 }
 
 func NewJob(d domain.Domain) Job {
 	j := Job{
 		DomainID: d.ID,
 		Domain:   d,
+		Status:   StatusQueued,
 	}
 
 	return j
@@ -49,20 +105,59 @@ func (j *Job) Insert(db *pg.DB) error {
 
 	// update domain
 	_, err = db.Model(&j.Domain).
-		Set("last_job_at = now()").
+		Set("last_job_at = now(), next_scan_at = NULL").
 		Where("id = ?", j.DomainID).
 		Update()
 	if err != nil {
 		return errors.Wrap(err, "Update Domain")
 	}
 
+	if j.CheckWhois {
+		_, err = db.Model(&j.Domain).
+			Set("last_whois_at = now()").
+			Where("id = ?", j.DomainID).
+			Update()
+		if err != nil {
+			return errors.Wrap(err, "Update Domain last_whois_at")
+		}
+	}
+
 	return nil
 }
 
-// find all jobs that have not yet started
+// Queue returns the queue the job should be published to
+func (j Job) Queue() string {
+	if j.Priority >= PriorityHigh {
+		return QueueHigh
+	}
+	return QueueNormal
+}
+
+// get the most recently finished job for a domain, excluding the
+// provided job id
+func GetPreviousJob(db orm.DB, domainID, jobID int) (Job, error) {
+	var j Job
+	err := db.Model(&j).
+		Where("domain_id = ? AND id != ? AND finished_at IS NOT NULL", domainID, jobID).
+		Order("finished_at DESC").
+		Limit(1).
+		Select()
+	if err != nil {
+		return Job{}, err
+	}
+	return j, nil
+}
+
+// find all jobs that are waiting to be dispatched, either because they
+// are queued or because their lease has expired
 func GetJobs(db *pg.DB) ([]Job, error) {
 	var jobs []Job
-	err := db.Model(&jobs).Relation("Domain").Where("started_at IS NULL OR (started_at < NOW() - INTERVAL '1 HOUR' AND finished_at IS NULL)").Select()
+	err := db.Model(&jobs).
+		Relation("Domain").
+		Where(leasableCondition).
+		Where("job.attempts < ?", maxAttempts).
+		Order("job.priority DESC", "job.created_at").
+		Select()
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package job
 import (
 	"log"
 
+	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/list"
 	"github.com/pkg/errors"
 )
@@ -11,67 +12,80 @@ func (m *Manager) handleLists(response *Job) error {
 	// eventually we will want to cache this, but for now its
 	// better to give the user a real time feeling as db calls
 	// will be cheap
-	var whitelists, blacklists []list.List
-
 	if response.Domain.OwnerID == 0 {
 		return errors.New("expected a user id")
 	}
-	uID := response.Domain.OwnerID
 
-	err := m.db.Model(&whitelists).Where("owner_id = ? AND list_type = ?", uID, list.Whitelist).Select()
+	lists, err := list.GetLists(m.db, response.Domain.OwnerID, response.Domain.OrganisationID)
 	if err != nil {
 		return err
 	}
 
-	err = m.db.Model(&blacklists).Where("owner_id = ? AND list_type = ?", uID, list.Blacklist).Select()
-	if err != nil {
-		return err
-	}
+	handleLists(response, lists)
 
-	return handleLists(response, whitelists, blacklists)
+	return nil
 }
 
-func handleLists(response *Job, whitelists, blacklists []list.List) error {
-	for _, w := range whitelists {
-		// if we match anything, drop out before checking blacklists
-		for _, r := range response.RecordAdditions {
-			if w.Match(&r) {
-				return nil
-			}
+// handleLists evaluates every change against the ordered lists, see
+// list.Evaluate, applying each change's outcome and storing the trace
+// on the job
+func handleLists(response *Job, lists []list.List) {
+	response.ListTrace = make([]list.Evaluation, 0, len(response.RecordAdditions)+len(response.RecordRemovals))
+
+	var suppressed domain.Records
+
+	response.RecordAdditions, suppressed = applyLists(response, lists, response.RecordAdditions, false)
+	response.SuppressedAdditions = append(response.SuppressedAdditions, suppressed...)
+
+	response.RecordRemovals, suppressed = applyLists(response, lists, response.RecordRemovals, true)
+	response.SuppressedRemovals = append(response.SuppressedRemovals, suppressed...)
+}
+
+// applyLists applies each record's outcome, returning the records that
+// should still be alerted on and any suppressed records
+func applyLists(response *Job, lists []list.List, records domain.Records, removed bool) (domain.Records, domain.Records) {
+	var suppressed domain.Records
+
+	i := 0
+	for _, r := range records {
+		// records from workers do not carry their domain, which lists
+		// match on
+		r.Domain = response.Domain
+
+		e := list.Evaluate(lists, &r, removed)
+		response.ListTrace = append(response.ListTrace, e)
+
+		for _, tag := range e.Tags {
+			response.addTag(tag)
 		}
-		for _, r := range response.RecordRemovals {
-			if w.Match(&r) {
-				return nil
-			}
+
+		switch e.Action {
+		case list.Blacklist:
+			log.Printf("Removing record %s as matched %d", r.Fields, e.ListID)
+			continue
+
+		case list.Suppress:
+			log.Printf("Suppressing record %s as matched %d", r.Fields, e.ListID)
+			suppressed = append(suppressed, r)
+			continue
+
+		case list.Escalate:
+			response.Escalated = true
 		}
+
+		records[i] = r
+		i++
 	}
 
-	for _, b := range blacklists {
-		// if we match anything, delete the record as it
-		// is not to be matched
-
-		i := 0
-		for _, r := range response.RecordAdditions {
-			if !b.Match(&r) {
-				response.RecordAdditions[i] = r
-				i++
-			} else {
-				log.Printf("Removing recordAddition %s as matched %d", r.Fields, b.ID)
-			}
-		}
-		response.RecordAdditions = response.RecordAdditions[:i]
-
-		i = 0
-		for _, r := range response.RecordRemovals {
-			if !b.Match(&r) {
-				response.RecordRemovals[i] = r
-				i++
-			} else {
-				log.Printf("Removing recordRemoval %s as matched %d", r.Fields, b.ID)
-			}
+	return records[:i], suppressed
+}
+
+// addTag adds the tag to the job unless it already has it
+func (j *Job) addTag(tag string) {
+	for _, t := range j.Tags {
+		if t == tag {
+			return
 		}
-		response.RecordRemovals = response.RecordRemovals[:i]
 	}
-
-	return nil
+	j.Tags = append(j.Tags, tag)
 }
@@ -0,0 +1,74 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/list"
+	"github.com/miekg/dns"
+)
+
+func Test_HandleLists(t *testing.T) {
+	t.Parallel()
+
+	d := domain.Domain{ID: 3, Domain: "example.com", OwnerID: 2}
+
+	record := func(name string, rrtype uint16) domain.Record {
+		return domain.Record{Domain: d, Name: name, RRType: domain.JsonRRType{V: rrtype}}
+	}
+
+	response := Job{
+		Domain: d,
+		RecordAdditions: domain.Records{
+			record("_dmarc", dns.TypeTXT),
+			record("www", dns.TypeTXT),
+			record("www", dns.TypeA),
+		},
+		RecordRemovals: domain.Records{
+			record("mail", dns.TypeA),
+			record("@", dns.TypeMX),
+		},
+	}
+
+	lists := []list.List{
+		{ID: 1, ListType: list.Whitelist, Domain: "*", RRType: "TXT", Record: "_dmarc"},
+		{ID: 2, ListType: list.Blacklist, Domain: "*", RRType: "TXT", Record: "*"},
+		{ID: 3, ListType: list.Suppress, Domain: "*", RRType: "A", Record: "mail"},
+		{ID: 4, ListType: list.Tag, Domain: "*", RRType: "MX", Record: "*", Tag: "mail"},
+		{ID: 5, ListType: list.Escalate, Domain: "*", RRType: "MX", Record: "*"},
+	}
+
+	handleLists(&response, lists)
+
+	// the whitelist only keeps its own record, the other TXT is dropped
+	if len(response.RecordAdditions) != 2 || response.RecordAdditions[0].Name != "_dmarc" || response.RecordAdditions[1].Name != "www" {
+		t.Fatalf("unexpected additions %+v", response.RecordAdditions)
+	}
+
+	if len(response.RecordRemovals) != 1 || response.RecordRemovals[0].Name != "@" {
+		t.Fatalf("unexpected removals %+v", response.RecordRemovals)
+	}
+
+	if len(response.SuppressedRemovals) != 1 || response.SuppressedRemovals[0].Name != "mail" {
+		t.Fatalf("unexpected suppressed removals %+v", response.SuppressedRemovals)
+	}
+
+	if !response.Escalated {
+		t.Fatal("expected the job to be escalated")
+	}
+
+	if len(response.Tags) != 1 || response.Tags[0] != "mail" {
+		t.Fatalf("unexpected tags %v", response.Tags)
+	}
+
+	expected := []list.ListType{list.Whitelist, list.Blacklist, list.Unmatched, list.Suppress, list.Escalate}
+	if len(response.ListTrace) != len(expected) {
+		t.Fatalf("expected %d evaluations got %d", len(expected), len(response.ListTrace))
+	}
+
+	for i, action := range expected {
+		if response.ListTrace[i].Action != action {
+			t.Errorf("expected evaluation %d to be %s got %s", i, action, response.ListTrace[i].Action)
+		}
+	}
+}
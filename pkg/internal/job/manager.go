@@ -9,7 +9,9 @@ import (
 	"github.com/go-pg/pg/v10"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/jawr/whois-bi/pkg/internal/leader"
 	"github.com/jawr/whois-bi/pkg/internal/queue"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 )
@@ -20,9 +22,16 @@ type Manager struct {
 
 	publisher queue.Publisher
 	consumer  queue.Consumer
+	progress  queue.Consumer
+
+	// only one manager replica creates jobs and sends alerts
+	elector *leader.Elector
+
+	webhooks *webhook.Dispatcher
+	outbox   *emailer.Outbox
 }
 
-func NewManager(publisher queue.Publisher, consumer queue.Consumer, db *pg.DB, emailer *emailer.Emailer) (*Manager, error) {
+func NewManager(publisher queue.Publisher, consumer, progress queue.Consumer, db *pg.DB, emailer *emailer.Emailer) (*Manager, error) {
 
 	// setup manager
 	manager := Manager{
@@ -30,6 +39,10 @@ func NewManager(publisher queue.Publisher, consumer queue.Consumer, db *pg.DB, e
 		emailer:   emailer,
 		publisher: publisher,
 		consumer:  consumer,
+		progress:  progress,
+		elector:   leader.NewElector(db, "whois.bi/manager"),
+		webhooks:  webhook.NewDispatcher(db),
+		outbox:    emailer.NewOutbox(db, emailer),
 	}
 
 	return &manager, nil
@@ -38,17 +51,35 @@ func NewManager(publisher queue.Publisher, consumer queue.Consumer, db *pg.DB, e
 func (m *Manager) Run(ctx context.Context) error {
 	wg, ctx := errgroup.WithContext(ctx)
 
-	// handle creation of jobs
 	wg.Go(func() error {
-		return m.createJobs(ctx)
+		return m.publisher.Run(ctx)
 	})
 
+	// all replicas handle responses and progress
 	wg.Go(func() error {
-		return m.publisher.Run(ctx)
+		return m.consumer.Run(ctx, m.handleJobResponses)
 	})
 
 	wg.Go(func() error {
-		return m.consumer.Run(ctx, m.handleJobResponses)
+		return m.progress.Run(ctx, m.handleProgress)
+	})
+
+	// only the leader creates jobs, sends alerts and emails and delivers
+	// webhooks
+	wg.Go(func() error {
+		return m.elector.Run(ctx, m.lead)
+	})
+
+	return wg.Wait()
+}
+
+// lead runs the tasks that must only run on a single replica
+func (m *Manager) lead(ctx context.Context) error {
+	wg, ctx := errgroup.WithContext(ctx)
+
+	// handle creation of jobs
+	wg.Go(func() error {
+		return m.createJobs(ctx)
 	})
 
 	// handle alerts
@@ -56,6 +87,21 @@ func (m *Manager) Run(ctx context.Context) error {
 		return m.sendAlerts(ctx)
 	})
 
+	// handle scheduled digests
+	wg.Go(func() error {
+		return m.sendDigests(ctx)
+	})
+
+	// handle webhook deliveries
+	wg.Go(func() error {
+		return m.webhooks.Run(ctx)
+	})
+
+	// handle the email outbox
+	wg.Go(func() error {
+		return m.outbox.Run(ctx)
+	})
+
 	return wg.Wait()
 }
 
@@ -70,21 +116,38 @@ func (m *Manager) createJobs(ctx context.Context) error {
 		case <-ticker.C:
 		}
 
-		// search for new jobs and dispatch them
-		domains, err := domain.GetDomainsWhereLastJobBefore(m.db, time.Hour*24)
+		// search for domains that are due according to their schedules
+		now := time.Now()
+
+		domains, err := domain.GetDomainsDue(m.db, now)
 		if err != nil {
-			return errors.WithMessage(err, "GetdomainsWhereLastJobBefore")
+			return errors.WithMessage(err, "GetDomainsDue")
 		}
 
 		for _, d := range domains {
 			j := NewJob(d)
+			j.CheckWhois = d.WhoisDue(now)
 			if err := j.Insert(m.db); err != nil {
 				continue
 			}
 		}
 
-		// get all jobs without a started_at
+		// mark any jobs that have run out of attempts as dead
+		expired, err := expireJobs(m.db)
+		if err != nil {
+			return errors.WithMessage(err, "expireJobs")
+		}
 
+		for _, j := range expired {
+			m.deadLetter(ctx, j)
+		}
+
+		// progress is only useful while a job is being followed
+		if _, err := PruneProgress(m.db, now.Add(-progressRetention)); err != nil {
+			log.Printf("Error pruning progress: %s", err)
+		}
+
+		// get all jobs waiting to be dispatched
 		jobs, err := GetJobs(m.db)
 		if err != nil {
 			return errors.WithMessage(err, "GetJobs")
@@ -95,109 +158,209 @@ func (m *Manager) createJobs(ctx context.Context) error {
 		}
 
 		for _, j := range jobs {
-
-			currentRecords, err := j.Domain.GetRecords(m.db)
-			if err != nil {
-				return errors.WithMessage(err, "GetRecords")
-			}
-			j.CurrentRecords = currentRecords
-
-			if err := m.publisher.Publish(ctx, "job.queue", &j); err != nil {
-				return errors.WithMessage(err, "Publish")
-			}
-		}
-
-		if len(jobs) > 0 {
-			if _, err := m.db.Model(&jobs).Set("started_at = now()").WherePK().Update(); err != nil {
-				return errors.WithMessage(err, "Update started_at")
+			if err := Dispatch(ctx, m.db, m.publisher, j); err != nil {
+				log.Printf("Error dispatching job %d: %s", j.ID, err)
 			}
 		}
 	}
 	return nil
 }
 
-func (m *Manager) handleJobResponses(ctx context.Context, body []byte) {
+// deadLetter publishes a job that has run out of attempts so that it can
+// be inspected
+func (m *Manager) deadLetter(ctx context.Context, j Job) {
+	log.Printf("Job %d / %s is dead after %d attempts", j.ID, j.Domain, j.Attempts)
+
+	if err := m.publisher.Publish(ctx, QueueNormal+".dead", &j); err != nil {
+		log.Printf("Error dead lettering job %d: %s", j.ID, err)
+	}
+}
+
+func (m *Manager) handleJobResponses(ctx context.Context, body []byte) error {
 	var job Job
 	if err := json.Unmarshal(body, &job); err != nil {
-		return
+		return errors.Wrap(err, "Unmarshal")
+	}
+
+	var started, drifted bool
+
+	// the response is applied in one transaction so a failure part way
+	// through leaves the job to be redelivered without any of its changes
+	err := m.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		var err error
+
+		started, err = job.Start(tx)
+		if err != nil {
+			return errors.WithMessage(err, "Start")
+		}
+
+		if !started {
+			return nil
+		}
+
+		log.Printf(
+			"Job %d  / %s Found %d additions and %d removals",
+			job.ID,
+			job.Domain,
+			len(job.RecordAdditions),
+			len(job.RecordRemovals),
+		)
+
+		drifted, err = m.applyJobResponse(tx, &job)
+		return err
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "handling job %d", job.ID)
+	}
+
+	// the response has already been handled, either by another delivery
+	// of the same response or because the job has since been retried
+	if !started {
+		log.Printf("Job %d attempt %d already handled, ignoring response", job.ID, job.Attempts)
+		return nil
 	}
 
-	log.Printf(
-		"Job %d  / %s Found %d additions and %d removals",
-		job.ID,
-		job.Domain,
-		len(job.RecordAdditions),
-		len(job.RecordRemovals),
-	)
+	// alerts and webhooks are only queued once the changes are committed
+	if len(job.RecordAdditions) > 0 || len(job.RecordRemovals) > 0 {
+		m.queueAlert(ctx, job, AlertTypeChanges)
+	}
+
+	if drifted {
+		m.queueAlert(ctx, job, AlertTypeDrift)
+	}
+
+	m.queueWebhooks(job)
+
+	if job.Status == StatusDead {
+		m.deadLetter(ctx, job)
+	}
 
+	return nil
+}
+
+// applyJobResponse stores the changes found by a started job and
+// completes it. Returns true if the domain's records have drifted.
+func (m *Manager) applyJobResponse(tx *pg.Tx, job *Job) (bool, error) {
 	// handle removals
-	if err := job.RecordRemovals.Remove(m.db); err != nil {
-		log.Printf("Error RecordRemovals.Remove() job %d: %s", job.ID, err)
-		return
+	if err := job.RecordRemovals.Remove(tx); err != nil {
+		return false, errors.WithMessage(err, "RecordRemovals.Remove()")
 	}
 
 	// handle additions
-	if err := job.RecordAdditions.Insert(m.db); err != nil {
-		log.Printf("Error RecordAdditions.Insert() job %d: %s", job.ID, err)
-		return
+	if err := job.RecordAdditions.Insert(tx); err != nil {
+		return false, errors.WithMessage(err, "RecordAdditions.Insert()")
 	}
 
 	// handle whois
 	if job.Whois.Raw != nil {
-		if err := job.Whois.Insert(m.db); err != nil {
-			// means we hit a dupe
-			if err != pg.ErrNoRows {
-				log.Println(errors.WithMessage(err, "inserting whois"))
-			}
-		} else {
+		err := job.Whois.Insert(tx)
+		switch err {
+		case nil:
 			job.WhoisUpdated = true
+		case pg.ErrNoRows:
+			// means we hit a dupe
+		default:
+			return false, errors.WithMessage(err, "inserting whois")
 		}
 	}
 
 	// parse the record additions and removals through our lists to avoid sending alarm bells
-	if err := m.handleLists(&job); err != nil {
+	if err := m.handleLists(job); err != nil {
 		log.Printf("Error parsing lists for job %d: %s", job.ID, err)
 	}
 
-	// handle alert message
-	if len(job.RecordAdditions) > 0 || len(job.RecordRemovals) > 0 {
-		a := Alert{
-			OwnerID:  job.Domain.OwnerID,
-			Response: job,
+	// check the current records against any expected records, a failed
+	// job has not seen the live records so skip it
+	var drifted bool
+	if len(job.Errors) == 0 {
+		var err error
+		drifted, err = handleDrift(tx, job)
+		if err != nil {
+			return false, errors.WithMessage(err, "handleDrift")
 		}
+	}
 
-		if job.Domain.DontBatch {
-			if err := m.handleAlerts([]Alert{a}); err != nil {
-				log.Printf("Error handling alerts for job %d: %s", job.ID, err)
-			}
-		} else {
-			if _, err := m.db.Model(&a).Insert(); err != nil {
-				log.Printf("Error inserting alert for job %d: %s", job.ID, err)
-			}
+	// records match the expected records again
+	if len(job.Errors) == 0 && len(job.Drift) == 0 {
+		if err := resolveDrift(tx, job.DomainID); err != nil {
+			return false, errors.WithMessage(err, "resolveDrift")
 		}
 	}
 
-	_, err := m.db.Model(&job).
+	job.Status = StatusSucceeded
+	if len(job.Errors) > 0 {
+		job.Status = StatusFailed
+	}
+
+	_, err := tx.Model(job).
 		Set(
-			"errors = ?, started_at = ?, finished_at = ?, additions = ?, removals = ?, whois_updated = ?",
+			"status = ?, errors = ?, whois_error = ?, started_at = ?, finished_at = ?, additions = ?, removals = ?, whois_updated = ?, drift = ?, tags = ?, list_trace = ?",
+			job.Status,
 			job.Errors,
+			job.WhoisError,
 			job.StartedAt,
 			job.FinishedAt,
 			len(job.RecordAdditions),
 			len(job.RecordRemovals),
 			job.WhoisUpdated,
+			job.Drift,
+			job.Tags,
+			job.ListTrace,
 		).
 		WherePK().
 		Update()
-
 	if err != nil {
-		log.Printf("Error updating job %d: %s", job.ID, err)
-		return
+		return false, errors.WithMessage(err, "updating job")
+	}
+
+	// retry failed jobs
+	if job.Status == StatusFailed {
+		if _, err := job.Fail(tx); err != nil {
+			return false, errors.WithMessage(err, "Fail")
+		}
 	}
 
-	_, err = m.db.Model(&job.Domain).Set("last_updated_at = now()").WherePK().Update()
+	update := tx.Model(&job.Domain).Set("last_updated_at = now()")
+
+	// the whois lookup failed, put back when it was last looked up so it
+	// is retried with the domain's next scan
+	if len(job.WhoisError) > 0 {
+		log.Printf("Job %d / %s whois lookup failed: %s", job.ID, job.Domain, job.WhoisError)
+		update = update.Set("last_whois_at = ?", pg.NullTime{Time: job.Domain.LastWhoisAt})
+	}
+
+	_, err = update.WherePK().Update()
 	if err != nil {
-		log.Printf("Error updating domain %d: %s", job.ID, err)
-		return
+		return false, errors.WithMessagef(err, "updating domain %d", job.DomainID)
+	}
+
+	return drifted, nil
+}
+
+// queueAlert either sends the alert straight away or stores it to be
+// batched depending on the domain's settings and escalate lists. Alerts
+// that fail to send straight away are stored to be retried.
+func (m *Manager) queueAlert(ctx context.Context, job Job, alertType AlertType) {
+	a := Alert{
+		OwnerID:   job.Domain.OwnerID,
+		AlertType: alertType,
+		Response:  job,
+	}
+
+	if job.Domain.DontBatch || job.Escalated {
+		err := m.handleAlerts(ctx, []Alert{a})
+		if err == nil {
+			return
+		}
+
+		log.Printf("Error handling %s alerts for job %d: %s", alertType, job.ID, err)
+
+		// store the alert as a batch of its own so it is retried with the
+		// same key, skipping destinations that already have it
+		a.Batch = alertsKey([]Alert{a})
+	}
+
+	if _, err := m.db.Model(&a).Insert(); err != nil {
+		log.Printf("Error inserting %s alert for job %d: %s", alertType, job.ID, err)
 	}
 }
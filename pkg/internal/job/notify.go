@@ -0,0 +1,104 @@
+package job
+
+import (
+	"context"
+	"log"
+
+	"github.com/jawr/whois-bi/pkg/internal/notify"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// link to a domain in the frontend
+func (m *Manager) domainURL(name string) string {
+	return m.emailer.URL("/domain/", name)
+}
+
+// one click link to acknowledge an incident
+func (m *Manager) acknowledgeURL(i Incident) string {
+	return m.emailer.URL("/acknowledge/", i.Token)
+}
+
+// notify routes a notification about domains in the owner's account or an
+// organisation, using the rules in it, and sends it to the resulting
+// destinations. Changes that do not match a rule are sent to each of the
+// account or organisation's channels, only including the domains a channel
+// is configured for. If there are no channels it is emailed to the owner.
+func (m *Manager) notify(ctx context.Context, ownerID, organisationID int, n notify.Notification) error {
+	if ownerID == 0 || len(n.Items) == 0 {
+		return nil
+	}
+
+	var owner user.User
+
+	if err := m.db.Model(&owner).Where("id = ?", ownerID).Select(); err != nil {
+		return errors.WithMessage(err, "Select Owner")
+	}
+
+	channels, err := notify.GetChannels(m.db, ownerID, organisationID)
+	if err != nil {
+		return errors.WithMessage(err, "GetChannels")
+	}
+
+	rules, err := notify.GetRules(m.db, ownerID, organisationID)
+	if err != nil {
+		return errors.WithMessage(err, "GetRules")
+	}
+
+	router, err := notify.NewRouter(rules)
+	if err != nil {
+		return errors.WithMessage(err, "NewRouter")
+	}
+
+	byID := make(map[int]notify.Channel, len(channels))
+	for _, c := range channels {
+		byID[c.ID] = c
+	}
+
+	// try every destination, returning the first error
+	var firstErr error
+
+	send := func(c notify.Channel, n notify.Notification) {
+		if len(n.Items) == 0 {
+			return
+		}
+
+		notifier, err := notify.NewNotifier(m.db, c, m.emailer, owner.Locale)
+		if err == nil {
+			err = notifier.Notify(ctx, n)
+		}
+
+		if err != nil {
+			err = errors.WithMessagef(err, "%s channel %d", c.Kind, c.ID)
+			log.Printf("Error notifying owner %d: %s", ownerID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	for dest, routed := range router.Route(n) {
+		switch {
+		case dest.ChannelID > 0:
+			c, ok := byID[dest.ChannelID]
+			if !ok {
+				log.Printf("Owner %d routes to missing channel %d", ownerID, dest.ChannelID)
+				continue
+			}
+			send(c, routed)
+
+		case len(dest.Email) > 0:
+			send(notify.Channel{OwnerID: ownerID, Kind: notify.KindEmail, Target: dest.Email}, routed)
+
+		case len(channels) == 0:
+			send(notify.Channel{OwnerID: ownerID, Kind: notify.KindEmail, Target: owner.Email}, routed)
+
+		default:
+			for _, c := range channels {
+				send(c, routed.For(c.DomainID))
+			}
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,111 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+// queue workers publish progress to
+const QueueProgress = "job.progress"
+
+const (
+	// a worker has picked up the job
+	ProgressStarted = "started"
+	// all records have been gathered, count is the number found
+	ProgressRecords = "records"
+	// whois lookup has completed
+	ProgressWhois = "whois"
+	// the worker has finished and published its response
+	ProgressFinished = "finished"
+)
+
+// how long progress is kept once its job has finished, long enough for
+// anyone following the job to read it
+const progressRetention = time.Hour
+
+// Progress is published by workers as they process a job so that users
+// can follow along
+type Progress struct {
+	ID int `pg:",pk" json:"id"`
+
+	JobID int `pg:",notnull" json:"job_id"`
+	Job   Job `pg:"fk:job_id,rel:has-one" json:"-"`
+
+	Stage   string `pg:",notnull" json:"stage"`
+	Message string `pg:",notnull,use_zero" json:"message"`
+	Count   int    `pg:",notnull,use_zero" json:"count"`
+
+	CreatedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+}
+
+// create a new Progress for a job
+func NewProgress(jobID int, stage, message string, count int) Progress {
+	return Progress{
+		JobID:     jobID,
+		Stage:     stage,
+		Message:   message,
+		Count:     count,
+		CreatedAt: time.Now(),
+	}
+}
+
+// get progress for a job after the provided progress id
+func GetProgress(db *pg.DB, jobID, afterID int) ([]Progress, error) {
+	progress := make([]Progress, 0)
+	err := db.Model(&progress).
+		Where("job_id = ? AND id > ?", jobID, afterID).
+		Order("id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// PruneProgress deletes progress older than before for jobs that have
+// finished, returning how many were deleted
+func PruneProgress(db orm.DB, before time.Time) (int, error) {
+	res, err := db.Model((*Progress)(nil)).
+		Where("created_at < ?", before).
+		Where("job_id IN (SELECT id FROM jobs WHERE status IN (?, ?))", StatusSucceeded, StatusDead).
+		Delete()
+	if err != nil {
+		return 0, errors.Wrap(err, "Delete")
+	}
+	return res.RowsAffected(), nil
+}
+
+// Finished returns true if the job will not be processed any further
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusDead
+}
+
+// handleProgress stores progress published by workers
+func (m *Manager) handleProgress(ctx context.Context, body []byte) error {
+	var p Progress
+	if err := json.Unmarshal(body, &p); err != nil {
+		return errors.Wrap(err, "Unmarshal")
+	}
+
+	if _, err := m.db.Model(&p).Insert(); err != nil {
+		return errors.WithMessagef(err, "Insert progress for job %d", p.JobID)
+	}
+
+	// the worker has picked up the job
+	if p.Stage == ProgressStarted {
+		_, err := m.db.Model((*Job)(nil)).
+			Set("status = ?", StatusRunning).
+			Where("id = ? AND status = ?", p.JobID, StatusLeased).
+			Update()
+		if err != nil {
+			return errors.WithMessagef(err, "Update job %d", p.JobID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_PruneProgress(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	finished := createJob(t, tx, "finished.com", StatusSucceeded, 1, time.Time{})
+	running := createJob(t, tx, "running.com", StatusRunning, 1, time.Now().Add(time.Minute))
+
+	old := time.Now().Add(-progressRetention * 2)
+
+	progress := []Progress{
+		NewProgress(finished.ID, ProgressStarted, "", 0),
+		NewProgress(finished.ID, ProgressFinished, "", 0),
+		NewProgress(running.ID, ProgressStarted, "", 0),
+	}
+	progress[0].CreatedAt = old
+	progress[2].CreatedAt = old
+
+	if _, err := tx.Model(&progress).Insert(); err != nil {
+		t.Fatalf("Insert() expected nil got %q", err)
+	}
+
+	pruned, err := PruneProgress(tx, time.Now().Add(-progressRetention))
+	if err != nil {
+		t.Fatalf("PruneProgress() expected nil got %q", err)
+	}
+
+	// only the finished job's old progress is removed
+	if pruned != 1 {
+		t.Fatalf("PruneProgress() expected 1 got %d", pruned)
+	}
+
+	var remaining []Progress
+	if err := tx.Model(&remaining).Where("job_id IN (?, ?)", finished.ID, running.ID).Order("id").Select(); err != nil {
+		t.Fatalf("Select() expected nil got %q", err)
+	}
+
+	if len(remaining) != 2 || remaining[0].ID != progress[1].ID || remaining[1].ID != progress[2].ID {
+		t.Fatalf("PruneProgress() unexpected remaining progress %+v", remaining)
+	}
+}
@@ -0,0 +1,202 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/queue"
+	"github.com/pkg/errors"
+)
+
+// Status of a job, a job moves through the states as follows:
+//
+//	queued -> leased -> running -> succeeded
+//	                            -> failed -> queued (retry after backoff)
+//	                                      -> dead (out of attempts)
+//
+// A job becomes running when a worker reports it has started or when its
+// response is being processed. A leased or running job whose lease
+// expires is treated as failed.
+type Status = string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusLeased    Status = "leased"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusDead      Status = "dead"
+)
+
+const (
+	// how long a worker has to respond before a job is retried
+	leaseDuration = time.Minute * 30
+	// number of attempts before a job is marked as dead
+	maxAttempts = 5
+	// delay before the first retry, doubles on each attempt
+	retryBackoff = time.Minute
+	// longest delay between retries
+	maxRetryBackoff = time.Hour
+)
+
+// returns how long to wait before the next attempt
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	d := retryBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+
+	return d
+}
+
+// where clause for jobs that can be leased now
+const leasableCondition = `(
+	(job.status = 'queued' AND (job.next_attempt_at IS NULL OR job.next_attempt_at <= now()))
+	OR (job.status IN ('leased', 'running') AND job.lease_expires_at < now())
+)`
+
+// Lease attempts to take the job for dispatch, incrementing its attempts.
+// Returns false if the job is no longer leasable, i.e. another dispatcher
+// has already leased it.
+func (j *Job) Lease(db orm.DB) (bool, error) {
+	res, err := db.Model(j).
+		Set("status = ?", StatusLeased).
+		Set("attempts = job.attempts + 1").
+		Set("lease_expires_at = ?", time.Now().Add(leaseDuration)).
+		WherePK().
+		Where(leasableCondition).
+		Returning("status, attempts, lease_expires_at").
+		Update()
+	if err != nil {
+		return false, errors.Wrap(err, "Update")
+	}
+
+	return res.RowsAffected() == 1, nil
+}
+
+// Release puts a leased job back on the queue without using an attempt,
+// used when dispatching fails
+func (j *Job) Release(db orm.DB) error {
+	_, err := db.Model(j).
+		Set("status = ?", StatusQueued).
+		Set("attempts = GREATEST(job.attempts - 1, 0)").
+		WherePK().
+		Where("job.status = ?", StatusLeased).
+		Update()
+	return err
+}
+
+// Start claims a job's response for processing, marking it as running.
+// Only the attempt the job is leased for can be started, so when it is
+// called in the transaction that completes the job a second delivery of
+// the response waits for it and then no longer matches. Returns false if
+// the job has already completed or been retried.
+func (j *Job) Start(db orm.DB) (bool, error) {
+	res, err := db.Model(j).
+		Set("status = ?", StatusRunning).
+		Set("lease_expires_at = ?", time.Now().Add(leaseDuration)).
+		WherePK().
+		Where("job.status IN (?, ?) AND job.attempts = ?", StatusLeased, StatusRunning, j.Attempts).
+		Update()
+	if err != nil {
+		return false, errors.Wrap(err, "Update")
+	}
+
+	return res.RowsAffected() == 1, nil
+}
+
+// Fail marks the job as failed, scheduling a retry with backoff if it
+// has attempts remaining, otherwise marking it as dead. Returns the new
+// status.
+func (j *Job) Fail(db orm.DB) (Status, error) {
+	j.Status = StatusQueued
+	j.NextAttemptAt = time.Now().Add(backoff(j.Attempts))
+
+	if j.Attempts >= maxAttempts {
+		j.Status = StatusDead
+	}
+
+	_, err := db.Model(j).
+		Set("status = ?status, next_attempt_at = ?next_attempt_at").
+		WherePK().
+		Update()
+	if err != nil {
+		return "", errors.Wrap(err, "Update")
+	}
+
+	return j.Status, nil
+}
+
+// expire any leased or running jobs that have run out of attempts
+func expireJobs(db orm.DB) ([]Job, error) {
+	var jobs []Job
+	err := db.Model(&jobs).
+		Relation("Domain").
+		Where("job.status IN (?, ?) AND job.lease_expires_at < now() AND job.attempts >= ?", StatusLeased, StatusRunning, maxAttempts).
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, errors.Wrap(err, "Select")
+	}
+
+	expired := make([]Job, 0, len(jobs))
+
+	for _, j := range jobs {
+		res, err := db.Model(&j).
+			Set("status = ?", StatusDead).
+			WherePK().
+			Where("job.status IN (?, ?) AND job.lease_expires_at < now()", StatusLeased, StatusRunning).
+			Update()
+		if err != nil {
+			return nil, errors.Wrap(err, "Update")
+		}
+
+		if res.RowsAffected() == 1 {
+			j.Status = StatusDead
+			expired = append(expired, j)
+		}
+	}
+
+	return expired, nil
+}
+
+// Dispatch leases the job and publishes it to the workers, if the job
+// can not be published it is released back on to the queue. Jobs that
+// are already leased elsewhere are ignored.
+func Dispatch(ctx context.Context, db *pg.DB, publisher queue.Publisher, j Job) error {
+	leased, err := j.Lease(db)
+	if err != nil {
+		return errors.WithMessage(err, "Lease")
+	}
+
+	if !leased {
+		return nil
+	}
+
+	currentRecords, err := j.Domain.GetRecords(db)
+	if err != nil {
+		if rerr := j.Release(db); rerr != nil {
+			log.Printf("Error releasing job %d: %s", j.ID, rerr)
+		}
+		return errors.WithMessage(err, "GetRecords")
+	}
+	j.CurrentRecords = currentRecords
+
+	if err := publisher.Publish(ctx, j.Queue(), &j); err != nil {
+		if rerr := j.Release(db); rerr != nil {
+			log.Printf("Error releasing job %d: %s", j.ID, rerr)
+		}
+		return errors.WithMessage(err, "Publish")
+	}
+
+	return nil
+}
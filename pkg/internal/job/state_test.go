@@ -0,0 +1,293 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/db"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+)
+
+func createConnection(t *testing.T) *pg.DB {
+	t.Helper()
+	conn, err := db.SetupDatabase()
+	if err != nil {
+		t.Fatalf("SetupDatabase unexpected error %q", err)
+	}
+	return conn
+}
+
+func createTx(t *testing.T, conn *pg.DB) *pg.Tx {
+	t.Helper()
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("Begin expected nil got %q", err)
+	}
+	return tx
+}
+
+// createJob inserts a job for a new domain with the status, attempts and
+// lease expiry
+func createJob(t *testing.T, db orm.DB, name string, status Status, attempts int, leaseExpiresAt time.Time) Job {
+	t.Helper()
+
+	u, err := user.NewUser("hi@"+name, "SuperStrongPassword1")
+	if err != nil {
+		t.Fatalf("NewUser() expected nil got %q", err)
+	}
+	if err := u.Insert(db); err != nil {
+		t.Fatalf("User.Insert() expected nil got %q", err)
+	}
+
+	d := domain.NewDomain(name, u)
+	if err := d.Insert(db); err != nil {
+		t.Fatalf("Domain.Insert() expected nil got %q", err)
+	}
+
+	j := NewJob(d)
+	j.Status = status
+	j.Attempts = attempts
+	j.LeaseExpiresAt = leaseExpiresAt
+
+	if _, err := db.Model(&j).Returning("*").Insert(); err != nil {
+		t.Fatalf("Job.Insert() expected nil got %q", err)
+	}
+
+	return j
+}
+
+// reloadJob returns the job as stored
+func reloadJob(t *testing.T, db orm.DB, j Job) Job {
+	t.Helper()
+	var stored Job
+	if err := db.Model(&stored).Where("id = ?", j.ID).Select(); err != nil {
+		t.Fatalf("Select() expected nil got %q", err)
+	}
+	return stored
+}
+
+func Test_Backoff(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		attempts int
+		expected time.Duration
+	}
+
+	cases := []tcase{
+		tcase{0, time.Minute},
+		tcase{1, time.Minute},
+		tcase{2, time.Minute * 2},
+		tcase{3, time.Minute * 4},
+		tcase{5, time.Minute * 16},
+		tcase{7, time.Hour},
+		tcase{100, time.Hour},
+	}
+
+	for _, tc := range cases {
+		if got := backoff(tc.attempts); got != tc.expected {
+			t.Errorf("backoff(%d) expected %s got %s", tc.attempts, tc.expected, got)
+		}
+	}
+}
+
+func Test_Lease(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	j := createJob(t, tx, "lease.com", StatusQueued, 0, time.Time{})
+
+	leased, err := j.Lease(tx)
+	if err != nil {
+		t.Fatalf("Lease() expected nil got %q", err)
+	}
+	if !leased {
+		t.Fatal("Lease() expected a queued job to be leased")
+	}
+
+	stored := reloadJob(t, tx, j)
+	if stored.Status != StatusLeased || stored.Attempts != 1 || !stored.LeaseExpiresAt.After(time.Now()) {
+		t.Fatalf("Lease() unexpected job %+v", stored)
+	}
+
+	// another dispatcher can not take it while the lease is held
+	if leased, err := j.Lease(tx); err != nil || leased {
+		t.Fatalf("Lease() expected false, nil for a leased job got %t, %v", leased, err)
+	}
+
+	// an expired lease can be taken again using another attempt
+	if _, err := tx.Model(&j).Set("lease_expires_at = ?", time.Now().Add(-time.Minute)).WherePK().Update(); err != nil {
+		t.Fatalf("Update() expected nil got %q", err)
+	}
+
+	if leased, err := j.Lease(tx); err != nil || !leased {
+		t.Fatalf("Lease() expected true, nil for an expired lease got %t, %v", leased, err)
+	}
+
+	if stored := reloadJob(t, tx, j); stored.Attempts != 2 {
+		t.Fatalf("Lease() expected 2 attempts got %d", stored.Attempts)
+	}
+
+	// a retry is not leased before its backoff
+	waiting := createJob(t, tx, "waiting.com", StatusQueued, 1, time.Time{})
+	if _, err := tx.Model(&waiting).Set("next_attempt_at = ?", time.Now().Add(time.Minute)).WherePK().Update(); err != nil {
+		t.Fatalf("Update() expected nil got %q", err)
+	}
+
+	if leased, err := waiting.Lease(tx); err != nil || leased {
+		t.Fatalf("Lease() expected false, nil before the next attempt got %t, %v", leased, err)
+	}
+}
+
+func Test_Start(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	j := createJob(t, tx, "start.com", StatusLeased, 1, time.Now().Add(time.Minute))
+
+	started, err := j.Start(tx)
+	if err != nil {
+		t.Fatalf("Start() expected nil got %q", err)
+	}
+	if !started {
+		t.Fatal("Start() expected a leased job to start")
+	}
+
+	if stored := reloadJob(t, tx, j); stored.Status != StatusRunning {
+		t.Fatalf("Start() expected %q got %q", StatusRunning, stored.Status)
+	}
+
+	// a completed job is not started again by a late response
+	done := createJob(t, tx, "done.com", StatusSucceeded, 1, time.Time{})
+
+	if started, err := done.Start(tx); err != nil || started {
+		t.Fatalf("Start() expected false, nil for a succeeded job got %t, %v", started, err)
+	}
+
+	if stored := reloadJob(t, tx, done); stored.Status != StatusSucceeded {
+		t.Fatalf("Start() expected %q got %q", StatusSucceeded, stored.Status)
+	}
+
+	// a response from an earlier attempt is not started once the job has
+	// been retried
+	retried := createJob(t, tx, "retried.com", StatusLeased, 2, time.Now().Add(time.Minute))
+	retried.Attempts = 1
+
+	if started, err := retried.Start(tx); err != nil || started {
+		t.Fatalf("Start() expected false, nil for an earlier attempt got %t, %v", started, err)
+	}
+
+	// a failed job waiting to be retried is not started by a redelivered
+	// response
+	queued := createJob(t, tx, "queued.com", StatusQueued, 1, time.Time{})
+
+	if started, err := queued.Start(tx); err != nil || started {
+		t.Fatalf("Start() expected false, nil for a queued job got %t, %v", started, err)
+	}
+}
+
+func Test_Fail(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	j := createJob(t, tx, "fail.com", StatusRunning, 2, time.Now().Add(time.Minute))
+
+	status, err := j.Fail(tx)
+	if err != nil {
+		t.Fatalf("Fail() expected nil got %q", err)
+	}
+	if status != StatusQueued {
+		t.Fatalf("Fail() expected %q got %q", StatusQueued, status)
+	}
+
+	stored := reloadJob(t, tx, j)
+	if stored.Status != StatusQueued {
+		t.Fatalf("Fail() expected stored %q got %q", StatusQueued, stored.Status)
+	}
+
+	// retried after the backoff for its attempts
+	wait := stored.NextAttemptAt.Sub(time.Now())
+	if wait <= backoff(2)-time.Minute || wait > backoff(2) {
+		t.Fatalf("Fail() expected next attempt in about %s got %s", backoff(2), wait)
+	}
+
+	dead := createJob(t, tx, "dead.com", StatusRunning, maxAttempts, time.Now().Add(time.Minute))
+
+	if status, err := dead.Fail(tx); err != nil || status != StatusDead {
+		t.Fatalf("Fail() expected %q, nil out of attempts got %q, %v", StatusDead, status, err)
+	}
+
+	if stored := reloadJob(t, tx, dead); stored.Status != StatusDead {
+		t.Fatalf("Fail() expected stored %q got %q", StatusDead, stored.Status)
+	}
+}
+
+func Test_ExpireJobs(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	expired := createJob(t, tx, "expired.com", StatusRunning, maxAttempts, past)
+	// can still be retried by leasing it again
+	retry := createJob(t, tx, "retry.com", StatusLeased, 1, past)
+	// lease has not run out
+	held := createJob(t, tx, "held.com", StatusLeased, maxAttempts, future)
+	// already finished
+	finished := createJob(t, tx, "finished.com", StatusSucceeded, maxAttempts, past)
+
+	jobs, err := expireJobs(tx)
+	if err != nil {
+		t.Fatalf("expireJobs() expected nil got %q", err)
+	}
+
+	if len(jobs) != 1 || jobs[0].ID != expired.ID || jobs[0].Status != StatusDead {
+		t.Fatalf("expireJobs() expected job %d to be dead got %+v", expired.ID, jobs)
+	}
+
+	if jobs[0].Domain.Domain != "expired.com" {
+		t.Fatalf("expireJobs() expected the domain to be loaded got %+v", jobs[0].Domain)
+	}
+
+	expected := map[int]Status{
+		expired.ID:  StatusDead,
+		retry.ID:    StatusLeased,
+		held.ID:     StatusLeased,
+		finished.ID: StatusSucceeded,
+	}
+
+	for _, j := range []Job{expired, retry, held, finished} {
+		if stored := reloadJob(t, tx, j); stored.Status != expected[j.ID] {
+			t.Errorf("expireJobs() expected job %d to be %q got %q", j.ID, expected[j.ID], stored.Status)
+		}
+	}
+
+	// expiring again does nothing
+	if jobs, err := expireJobs(tx); err != nil || len(jobs) != 0 {
+		t.Fatalf("expireJobs() expected no jobs got %d, %v", len(jobs), err)
+	}
+}
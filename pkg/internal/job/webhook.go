@@ -0,0 +1,78 @@
+package job
+
+import (
+	"log"
+	"time"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
+)
+
+// data for webhook.EventRecordChanges
+type recordChanges struct {
+	JobID     int            `json:"job_id"`
+	Additions domain.Records `json:"additions"`
+	Removals  domain.Records `json:"removals"`
+	Tags      []string       `json:"tags,omitempty"`
+}
+
+// data for webhook.EventWhoisChange
+type whoisChange struct {
+	JobID          int       `json:"job_id"`
+	CreatedDate    time.Time `json:"created_date"`
+	UpdatedDate    time.Time `json:"updated_date"`
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// data for webhook.EventExpiration
+type expiration struct {
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// queueWebhooks enqueues events for changes found by a job
+func (m *Manager) queueWebhooks(job Job) {
+	ownerID := job.Domain.OwnerID
+	organisationID := job.Domain.OrganisationID
+	name := job.Domain.Domain
+
+	// suppressed changes are only hidden from alerts
+	additions := append(append(domain.Records{}, job.RecordAdditions...), job.SuppressedAdditions...)
+	removals := append(append(domain.Records{}, job.RecordRemovals...), job.SuppressedRemovals...)
+
+	if len(additions) > 0 || len(removals) > 0 {
+		event := webhook.NewEvent(webhook.EventRecordChanges, name, recordChanges{
+			JobID:     job.ID,
+			Additions: additions,
+			Removals:  removals,
+			Tags:      job.Tags,
+		})
+
+		if err := webhook.Enqueue(m.db, ownerID, organisationID, event); err != nil {
+			log.Printf("Error queueing webhooks for job %d: %s", job.ID, err)
+		}
+	}
+
+	if job.WhoisUpdated {
+		event := webhook.NewEvent(webhook.EventWhoisChange, name, whoisChange{
+			JobID:          job.ID,
+			CreatedDate:    job.Whois.CreatedDate,
+			UpdatedDate:    job.Whois.UpdatedDate,
+			ExpirationDate: job.Whois.ExpirationDate,
+		})
+
+		if err := webhook.Enqueue(m.db, ownerID, organisationID, event); err != nil {
+			log.Printf("Error queueing whois webhooks for job %d: %s", job.ID, err)
+		}
+	}
+}
+
+// queueExpirationWebhook enqueues an expiration warning for a domain
+func (m *Manager) queueExpirationWebhook(w domain.Whois) {
+	event := webhook.NewEvent(webhook.EventExpiration, w.Domain.Domain, expiration{
+		ExpirationDate: w.ExpirationDate,
+	})
+
+	if err := webhook.Enqueue(m.db, w.Domain.OwnerID, w.Domain.OrganisationID, event); err != nil {
+		log.Printf("Error queueing expiration webhooks for %s: %s", w.Domain.Domain, err)
+	}
+}
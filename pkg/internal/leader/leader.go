@@ -0,0 +1,177 @@
+// Package leader provides leader election between replicas using Postgres
+// session level advisory locks. The lock is held on a dedicated connection
+// so if the process dies, or the connection is lost, the lock is released
+// and another replica can take over.
+package leader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/segmentio/fasthash/fnv1a"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// how often a follower attempts to take the lock
+	defaultRetry = time.Second * 10
+	// how often the leader checks it still holds the lock
+	defaultHeartbeat = time.Second * 5
+)
+
+// holdsLockQuery checks the connection's session holds the advisory lock, a
+// bigint key is split in to classid and objid with objsubid 1. Answering at
+// all is not enough as the connection may have been reconnected, losing the
+// lock
+const holdsLockQuery = `SELECT EXISTS (
+	SELECT 1 FROM pg_locks
+	WHERE locktype = 'advisory'
+	AND pid = pg_backend_pid()
+	AND granted
+	AND classid::bigint = ?
+	AND objid::bigint = ?
+	AND objsubid = 1
+)`
+
+// LeaderFunc is run while leadership is held, the context is cancelled
+// when leadership is lost
+type LeaderFunc func(ctx context.Context) error
+
+// Elector elects a single leader between all Electors using the same
+// name and database
+type Elector struct {
+	db   *pg.DB
+	name string
+	key  int64
+
+	retry     time.Duration
+	heartbeat time.Duration
+}
+
+// NewElector creates an Elector for the named role
+func NewElector(db *pg.DB, name string) *Elector {
+	return &Elector{
+		db:        db,
+		name:      name,
+		key:       int64(fnv1a.HashString64(name)),
+		retry:     defaultRetry,
+		heartbeat: defaultHeartbeat,
+	}
+}
+
+// Run blocks until the context is cancelled or fn returns an error. Whilst
+// this Elector is the leader fn is run, if leadership is lost fn's context
+// is cancelled and the Elector goes back to trying to take the lock.
+func (e *Elector) Run(ctx context.Context, fn LeaderFunc) error {
+	for {
+		lost, err := e.lead(ctx, fn)
+		if err != nil {
+			return err
+		}
+
+		if lost {
+			log.Printf("Lost leadership of %q", e.name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.retry):
+		}
+	}
+}
+
+// lead attempts to take the lock and run fn, returns true if leadership
+// was held and then lost
+func (e *Elector) lead(ctx context.Context, fn LeaderFunc) (bool, error) {
+	conn := e.db.Conn()
+	defer conn.Close()
+
+	var locked bool
+	if _, err := conn.QueryOneContext(ctx, pg.Scan(&locked), "SELECT pg_try_advisory_lock(?)", e.key); err != nil {
+		// treat as not being able to get the lock, the database may be
+		// temporarily unavailable
+		log.Printf("Error trying lock for %q: %s", e.name, err)
+		return false, nil
+	}
+
+	if !locked {
+		return false, nil
+	}
+
+	log.Printf("Acquired leadership of %q", e.name)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg, leaderCtx := errgroup.WithContext(leaderCtx)
+
+	wg.Go(func() error {
+		err := fn(leaderCtx)
+		// stop the heartbeat
+		cancel()
+		return err
+	})
+
+	lost := make(chan struct{})
+
+	wg.Go(func() error {
+		ticker := time.NewTicker(e.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			held, err := e.holdsLock(leaderCtx, conn)
+			if err != nil && leaderCtx.Err() != nil {
+				return nil
+			}
+
+			if err != nil || !held {
+				log.Printf("Leader heartbeat for %q failed, lock held %t: %v", e.name, held, err)
+				close(lost)
+				cancel()
+				return nil
+			}
+		}
+	})
+
+	err := wg.Wait()
+
+	select {
+	case <-lost:
+		return true, nil
+	default:
+	}
+
+	// release the lock so another replica can take over straight away,
+	// closing the connection would also release it
+	if _, uerr := conn.Exec("SELECT pg_advisory_unlock(?)", e.key); uerr != nil {
+		log.Printf("Error releasing lock for %q: %s", e.name, uerr)
+	}
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	return false, err
+}
+
+// holdsLock returns true if the connection's session still holds the lock
+func (e *Elector) holdsLock(ctx context.Context, conn *pg.Conn) (bool, error) {
+	var held bool
+	_, err := conn.QueryOneContext(
+		ctx,
+		pg.Scan(&held),
+		holdsLockQuery,
+		int64(uint64(e.key)>>32),
+		int64(uint32(e.key)),
+	)
+	return held, err
+}
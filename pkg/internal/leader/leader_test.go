@@ -0,0 +1,171 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/jawr/whois-bi/pkg/internal/db"
+	"golang.org/x/sync/errgroup"
+)
+
+func createConnection(t *testing.T) *pg.DB {
+	t.Helper()
+	conn, err := db.SetupDatabase()
+	if err != nil {
+		t.Fatalf("SetupDatabase unexpected error %q", err)
+	}
+	return conn
+}
+
+func createElector(conn *pg.DB, name string) *Elector {
+	e := NewElector(conn, name)
+	e.retry = time.Millisecond * 50
+	e.heartbeat = time.Millisecond * 50
+	return e
+}
+
+func Test_SingleLeader(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	const name = "test/single-leader"
+
+	var leaders, maxLeaders int32
+
+	fn := func(ctx context.Context) error {
+		n := atomic.AddInt32(&leaders, 1)
+		defer atomic.AddInt32(&leaders, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxLeaders)
+			if n <= max || atomic.CompareAndSwapInt32(&maxLeaders, max, n) {
+				break
+			}
+		}
+
+		<-ctx.Done()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+
+	var wg errgroup.Group
+
+	for i := 0; i < 3; i++ {
+		e := createElector(conn, name)
+		wg.Go(func() error {
+			return e.Run(ctx, fn)
+		})
+	}
+
+	if err := wg.Wait(); err != context.DeadlineExceeded {
+		t.Fatalf("Run() expected DeadlineExceeded got %v", err)
+	}
+
+	if max := atomic.LoadInt32(&maxLeaders); max != 1 {
+		t.Fatalf("expected exactly 1 leader at a time got %d", max)
+	}
+}
+
+func Test_LeaderHandover(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	const name = "test/leader-handover"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := createElector(conn, name)
+
+	firstCtx, firstCancel := context.WithCancel(ctx)
+	leading := make(chan struct{})
+
+	var wg errgroup.Group
+
+	wg.Go(func() error {
+		return first.Run(firstCtx, func(ctx context.Context) error {
+			close(leading)
+			<-ctx.Done()
+			return nil
+		})
+	})
+
+	<-leading
+
+	second := createElector(conn, name)
+	took := make(chan struct{})
+
+	wg.Go(func() error {
+		return second.Run(ctx, func(ctx context.Context) error {
+			close(took)
+			<-ctx.Done()
+			return nil
+		})
+	})
+
+	// stop the first leader and the second should take over
+	firstCancel()
+
+	select {
+	case <-took:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected second elector to take leadership")
+	}
+
+	cancel()
+
+	if err := wg.Wait(); err != context.Canceled {
+		t.Fatalf("Run() expected Canceled got %v", err)
+	}
+}
+
+func Test_HoldsLock(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	e := createElector(conn, "test/holds-lock")
+
+	session := conn.Conn()
+	defer session.Close()
+
+	ctx := context.Background()
+
+	if held, err := e.holdsLock(ctx, session); err != nil || held {
+		t.Fatalf("holdsLock() expected false, nil before locking got %t, %v", held, err)
+	}
+
+	if _, err := session.Exec("SELECT pg_advisory_lock(?)", e.key); err != nil {
+		t.Fatalf("pg_advisory_lock expected nil got %q", err)
+	}
+
+	if held, err := e.holdsLock(ctx, session); err != nil || !held {
+		t.Fatalf("holdsLock() expected true, nil once locked got %t, %v", held, err)
+	}
+
+	// another session does not hold it
+	other := conn.Conn()
+	defer other.Close()
+
+	if held, err := e.holdsLock(ctx, other); err != nil || held {
+		t.Fatalf("holdsLock() expected false, nil on another session got %t, %v", held, err)
+	}
+
+	if _, err := session.Exec("SELECT pg_advisory_unlock(?)", e.key); err != nil {
+		t.Fatalf("pg_advisory_unlock expected nil got %q", err)
+	}
+
+	if held, err := e.holdsLock(ctx, session); err != nil || held {
+		t.Fatalf("holdsLock() expected false, nil once unlocked got %t, %v", held, err)
+	}
+}
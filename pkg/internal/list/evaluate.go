@@ -0,0 +1,83 @@
+package list
+
+import (
+	"sort"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+)
+
+// Unmatched is the action taken on a change that no list matched, it is
+// alerted on as normal
+const Unmatched ListType = "none"
+
+// Evaluation records how a single change was evaluated against a rule set
+type Evaluation struct {
+	Record  domain.Record `json:"record"`
+	Removed bool          `json:"removed"`
+
+	// the type of the list that decided the change or Unmatched
+	Action ListType `json:"action"`
+	// the list that decided the change, 0 if Unmatched
+	ListID int `json:"list_id"`
+
+	// added by tag lists matched along the way
+	Tags []string `json:"tags"`
+}
+
+// Sort orders lists by Position then ID, the order Evaluate expects
+func Sort(lists []List) {
+	sort.SliceStable(lists, func(i, j int) bool {
+		if lists[i].Position == lists[j].Position {
+			return lists[i].ID < lists[j].ID
+		}
+		return lists[i].Position < lists[j].Position
+	})
+}
+
+// Evaluate runs a single change through the lists in order:
+//
+//   - tag lists add their tag and evaluation carries on
+//   - the first whitelist, blacklist, suppress or escalate list to match
+//     decides the change and evaluation stops
+//   - a change matching none of these is Unmatched
+//
+// Each change is evaluated on its own, a whitelist matching one record has
+// no effect on any other record.
+func Evaluate(lists []List, record *domain.Record, removed bool) Evaluation {
+	e := Evaluation{
+		Record:  *record,
+		Removed: removed,
+		Action:  Unmatched,
+		Tags:    make([]string, 0),
+	}
+
+	for idx := range lists {
+		l := &lists[idx]
+
+		if !l.MatchChange(record, removed) {
+			continue
+		}
+
+		if l.ListType == Tag {
+			e.addTag(l.Tag)
+			continue
+		}
+
+		e.Action = l.ListType
+		e.ListID = l.ID
+
+		break
+	}
+
+	return e
+}
+
+// addTag adds the tag unless it already has it
+func (e *Evaluation) addTag(tag string) {
+	for _, t := range e.Tags {
+		if t == tag {
+			return
+		}
+	}
+	e.Tags = append(e.Tags, tag)
+}
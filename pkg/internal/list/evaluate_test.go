@@ -0,0 +1,83 @@
+package list
+
+import (
+	"testing"
+
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/miekg/dns"
+)
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	lists := []List{
+		{ID: 1, Position: 2, ListType: Blacklist, Domain: "*", RRType: "TXT", Record: "*"},
+		{ID: 2, Position: 1, ListType: Whitelist, Domain: "*", RRType: "TXT", Record: "_dmarc"},
+		{ID: 3, Position: 0, ListType: Tag, Domain: "*", RRType: "*", Record: "*", Tag: "dns"},
+		{ID: 4, Position: 0, ListType: Tag, Domain: "*", RRType: "MX", Record: "*", Tag: "mail"},
+		{ID: 5, Position: 3, ListType: Suppress, Domain: "*", RRType: "A", Record: "*", Direction: DirectionRemoval},
+		{ID: 6, Position: 3, ListType: Escalate, Domain: "*", RRType: "MX", Record: "*"},
+	}
+
+	Sort(lists)
+
+	type tcase struct {
+		record  domain.Record
+		removed bool
+		action  ListType
+		listID  int
+		tags    []string
+	}
+
+	tests := []tcase{
+		// the whitelist comes first so only affects its own record
+		{createRecord("whois.bi", "_dmarc", dns.TypeTXT), false, Whitelist, 2, []string{"dns"}},
+		{createRecord("whois.bi", "www", dns.TypeTXT), false, Blacklist, 1, []string{"dns"}},
+		{createRecord("whois.bi", "www", dns.TypeA), true, Suppress, 5, []string{"dns"}},
+		{createRecord("whois.bi", "www", dns.TypeA), false, Unmatched, 0, []string{"dns"}},
+		{createRecord("whois.bi", "@", dns.TypeMX), false, Escalate, 6, []string{"dns", "mail"}},
+	}
+
+	for _, tc := range tests {
+		e := Evaluate(lists, &tc.record, tc.removed)
+
+		if e.Action != tc.action || e.ListID != tc.listID {
+			t.Errorf("Evaluate(%s, %t) expected %s/%d got %s/%d", tc.record.String(), tc.removed, tc.action, tc.listID, e.Action, e.ListID)
+		}
+
+		if len(e.Tags) != len(tc.tags) {
+			t.Errorf("Evaluate(%s, %t) expected tags %v got %v", tc.record.String(), tc.removed, tc.tags, e.Tags)
+			continue
+		}
+
+		for i := range tc.tags {
+			if e.Tags[i] != tc.tags[i] {
+				t.Errorf("Evaluate(%s, %t) expected tags %v got %v", tc.record.String(), tc.removed, tc.tags, e.Tags)
+				break
+			}
+		}
+
+		if e.Record.Name != tc.record.Name || e.Removed != tc.removed {
+			t.Errorf("Evaluate(%s, %t) did not record the change", tc.record.String(), tc.removed)
+		}
+	}
+}
+
+func Test_Sort(t *testing.T) {
+	t.Parallel()
+
+	lists := []List{
+		{ID: 3, Position: 1},
+		{ID: 2, Position: 0},
+		{ID: 1, Position: 1},
+	}
+
+	Sort(lists)
+
+	expected := []int{2, 1, 3}
+	for i := range expected {
+		if lists[i].ID != expected[i] {
+			t.Fatalf("Sort() expected %d at %d got %d", expected[i], i, lists[i].ID)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/user"
 	"github.com/pkg/errors"
@@ -13,16 +14,44 @@ import (
 type ListType = string
 
 const (
+	// alert on the change
 	Whitelist ListType = "whitelist"
+	// ignore the change completely
 	Blacklist ListType = "blacklist"
+	// keep the change but do not alert on it
+	Suppress ListType = "suppress"
+	// alert on the change straight away, skipping batching
+	Escalate ListType = "escalate"
+	// add Tag to the change
+	Tag ListType = "tag"
 )
 
+// Direction limits a list to additions or removals
+type Direction = string
+
+const (
+	DirectionAny      Direction = ""
+	DirectionAddition Direction = "addition"
+	DirectionRemoval  Direction = "removal"
+)
+
+// names of domain.RecordSource used by lists
+var sources = map[string]domain.RecordSource{
+	"any":     domain.RecordSourceANY,
+	"axfr":    domain.RecordSourceAXFR,
+	"manual":  domain.RecordSourceManual,
+	"iterate": domain.RecordSourceIterate,
+}
+
 type List struct {
 	ID int `pg:",pk" json:"id"`
 
 	OwnerID int       `pg:",notnull,unique:list_type_domain_rrtype_record_owner_id" json:"owner_id"`
 	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
 
+	// 0 for lists in the owner's own account
+	OrganisationID int `pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id" json:"organisation_id"`
+
 	ListType ListType `pg:",notnull,type:text,unique:list_type_domain_rrtype_record_owner_id" json:"list_type"`
 
 	// fields to match
@@ -30,9 +59,27 @@ type List struct {
 	RRType string `json:"rr_type" pg:",notnull,unique:list_type_domain_rrtype_record_owner_id"`
 	Record string `json:"record" pg:",notnull,unique:list_type_domain_rrtype_record_owner_id"`
 
+	// optional fields to match, empty matches everything. Fields is
+	// matched against the record's value, e.g. the text of a TXT record
+	Fields    string    `json:"fields" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+	MinTTL    int       `json:"min_ttl" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+	MaxTTL    int       `json:"max_ttl" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+	Source    string    `json:"source" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+	Direction Direction `json:"direction" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+
+	// only match domains in the group, empty matches every domain
+	Group string `json:"group" pg:"domain_group,notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+
+	// added to matching changes by Tag lists
+	Tag string `json:"tag" pg:",notnull,use_zero,unique:list_type_domain_rrtype_record_owner_id"`
+
+	// lists are evaluated in Position then ID order, see Evaluate
+	Position int `json:"position" pg:",notnull,use_zero"`
+
 	domainMatch *regexp.Regexp
 	recordMatch *regexp.Regexp
 	rrtypeMatch *regexp.Regexp
+	fieldsMatch *regexp.Regexp
 	once        sync.Once
 
 	AddedAt   time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
@@ -43,6 +90,11 @@ func anchor(s string) string {
 	return "^" + s + "$"
 }
 
+// wildcard returns true if the pattern matches everything
+func wildcard(s string) bool {
+	return s == "*"
+}
+
 func (l List) Validate() error {
 	if len(l.Domain) == 0 || len(l.Record) == 0 || len(l.RRType) == 0 {
 		return errors.New("missing fields")
@@ -66,16 +118,53 @@ func (l List) Validate() error {
 		}
 	}
 
-	return nil
-}
+	if len(l.Fields) > 0 && !wildcard(l.Fields) {
+		if _, err := regexp.Compile(anchor(l.Fields)); err != nil {
+			return errors.WithMessage(err, "Fields")
+		}
+	}
 
-func (l *List) Match(record *domain.Record) bool {
-	if l.RRType == "*" && l.Domain == "*" && l.Record == "*" {
-		return true
+	if l.MinTTL < 0 || l.MaxTTL < 0 {
+		return errors.New("ttl can not be negative")
 	}
 
-	var matches int
+	if l.MaxTTL > 0 && l.MinTTL > l.MaxTTL {
+		return errors.New("min ttl is greater than max ttl")
+	}
 
+	if _, ok := sources[l.Source]; len(l.Source) > 0 && !ok {
+		return errors.Errorf("unknown source %q", l.Source)
+	}
+
+	if len(l.Group) > 0 && !domain.ValidTag(l.Group) {
+		return errors.Errorf("invalid group %q", l.Group)
+	}
+
+	switch l.Direction {
+	case DirectionAny, DirectionAddition, DirectionRemoval:
+	default:
+		return errors.Errorf("unknown direction %q", l.Direction)
+	}
+
+	switch l.ListType {
+	case "", Whitelist, Blacklist, Suppress, Escalate:
+		if len(l.Tag) > 0 {
+			return errors.New("tag is only used by tag lists")
+		}
+	case Tag:
+		if len(l.Tag) == 0 {
+			return errors.New("missing tag")
+		}
+	default:
+		return errors.Errorf("unknown list type %q", l.ListType)
+	}
+
+	return nil
+}
+
+// Match returns true if the record matches the list, regardless of whether
+// it was added or removed
+func (l *List) Match(record *domain.Record) bool {
 	// init regexps if not already done
 	l.once.Do(func() {
 		if l.Domain != "*" {
@@ -87,19 +176,69 @@ func (l *List) Match(record *domain.Record) bool {
 		if l.RRType != "*" {
 			l.rrtypeMatch = regexp.MustCompile(anchor(l.RRType))
 		}
+		if len(l.Fields) > 0 && !wildcard(l.Fields) {
+			l.fieldsMatch = regexp.MustCompile(anchor(l.Fields))
+		}
 	})
 
-	if l.Domain == "*" || (l.domainMatch != nil && l.domainMatch.MatchString(record.Domain.Domain)) {
-		matches++
+	if len(l.Group) > 0 && !record.Domain.HasTag(l.Group) {
+		return false
+	}
+
+	if l.Domain != "*" && (l.domainMatch == nil || !l.domainMatch.MatchString(record.Domain.Domain)) {
+		return false
 	}
 
-	if l.Record == "*" || (l.recordMatch != nil && l.recordMatch.MatchString(record.Name)) {
-		matches++
+	if l.Record != "*" && (l.recordMatch == nil || !l.recordMatch.MatchString(record.Name)) {
+		return false
 	}
 
-	if l.RRType == "*" || (l.rrtypeMatch != nil && l.rrtypeMatch.MatchString(record.RRType.String())) {
-		matches++
+	if l.RRType != "*" && (l.rrtypeMatch == nil || !l.rrtypeMatch.MatchString(record.RRType.String())) {
+		return false
 	}
 
-	return matches == 3
+	if l.fieldsMatch != nil && !l.fieldsMatch.MatchString(record.Fields) {
+		return false
+	}
+
+	if l.MinTTL > 0 && int64(record.TTL) < int64(l.MinTTL) {
+		return false
+	}
+
+	if l.MaxTTL > 0 && int64(record.TTL) > int64(l.MaxTTL) {
+		return false
+	}
+
+	if len(l.Source) > 0 && sources[l.Source] != record.RecordSource {
+		return false
+	}
+
+	return true
+}
+
+// MatchChange returns true if the record matches the list and the list
+// applies to the direction of the change
+func (l *List) MatchChange(record *domain.Record, removed bool) bool {
+	switch {
+	case l.Direction == DirectionAddition && removed:
+		return false
+	case l.Direction == DirectionRemoval && !removed:
+		return false
+	}
+
+	return l.Match(record)
+}
+
+// GetLists returns all of the owner's lists in the organisation, 0 for the
+// owner's own account, in evaluation order
+func GetLists(db orm.DB, ownerID, organisationID int) ([]List, error) {
+	lists := make([]List, 0)
+	err := db.Model(&lists).
+		Where("owner_id = ? AND organisation_id = ?", ownerID, organisationID).
+		Order("position", "id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
 }
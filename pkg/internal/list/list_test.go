@@ -219,3 +219,172 @@ func Test_ValidateSuccess(t *testing.T) {
 		t.Fatalf("Validate() expected nil got %q", err)
 	}
 }
+
+func Test_FieldsMatch(t *testing.T) {
+	t.Parallel()
+
+	l := createList("*", "TXT", "*")
+	l.Fields = `"google-site-verification=.*"`
+
+	pass := createRecord("whois.bi", "@", dns.TypeTXT)
+	pass.Fields = `"google-site-verification=abc123"`
+
+	fail := createRecord("whois.bi", "@", dns.TypeTXT)
+	fail.Fields = `"v=spf1 -all"`
+
+	if !l.Match(&pass) {
+		t.Errorf("expected a match: %s", pass.Fields)
+	}
+
+	if l.Match(&fail) {
+		t.Errorf("expected no match: %s", fail.Fields)
+	}
+}
+
+func Test_TTLMatch(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		min, max int
+		ttl      uint32
+		expected bool
+	}
+
+	cases := []tcase{
+		tcase{0, 0, 300, true},
+		tcase{300, 0, 300, true},
+		tcase{301, 0, 300, false},
+		tcase{0, 300, 300, true},
+		tcase{0, 299, 300, false},
+		tcase{60, 3600, 300, true},
+		tcase{60, 3600, 30, false},
+	}
+
+	for _, tc := range cases {
+		l := createList("*", "*", "*")
+		l.MinTTL = tc.min
+		l.MaxTTL = tc.max
+
+		r := createRecord("whois.bi", "www", dns.TypeA)
+		r.TTL = tc.ttl
+
+		if got := l.Match(&r); got != tc.expected {
+			t.Errorf("ttl %d in %d-%d expected %t got %t", tc.ttl, tc.min, tc.max, tc.expected, got)
+		}
+	}
+}
+
+func Test_SourceMatch(t *testing.T) {
+	t.Parallel()
+
+	l := createList("*", "*", "*")
+	l.Source = "manual"
+
+	manual := createRecord("whois.bi", "www", dns.TypeA)
+	manual.RecordSource = domain.RecordSourceManual
+
+	axfr := createRecord("whois.bi", "www", dns.TypeA)
+	axfr.RecordSource = domain.RecordSourceAXFR
+
+	if !l.Match(&manual) {
+		t.Error("expected manual record to match")
+	}
+
+	if l.Match(&axfr) {
+		t.Error("expected axfr record not to match")
+	}
+}
+
+func Test_DirectionMatch(t *testing.T) {
+	t.Parallel()
+
+	r := createRecord("whois.bi", "www", dns.TypeA)
+
+	l := createList("*", "*", "*")
+
+	if !l.MatchChange(&r, false) || !l.MatchChange(&r, true) {
+		t.Error("expected any direction to match additions and removals")
+	}
+
+	l.Direction = DirectionAddition
+
+	if !l.MatchChange(&r, false) || l.MatchChange(&r, true) {
+		t.Error("expected only additions to match")
+	}
+
+	l.Direction = DirectionRemoval
+
+	if l.MatchChange(&r, false) || !l.MatchChange(&r, true) {
+		t.Error("expected only removals to match")
+	}
+}
+
+func Test_GroupMatch(t *testing.T) {
+	t.Parallel()
+
+	l := createList("*", "*", "*")
+	l.Group = "prod"
+
+	prod := createRecord("whois.bi", "www", dns.TypeA)
+	prod.Domain.Tags = []string{"mail", "prod"}
+
+	staging := createRecord("mx.ax", "www", dns.TypeA)
+	staging.Domain.Tags = []string{"staging"}
+
+	if !l.Match(&prod) {
+		t.Error("expected a domain in the group to match")
+	}
+
+	if l.Match(&staging) {
+		t.Error("expected a domain outside the group not to match")
+	}
+}
+
+func Test_ValidateOptional(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		modify   func(*List)
+		expected string
+	}
+
+	cases := []tcase{
+		tcase{func(l *List) { l.Fields = "[A--]*" }, "Fields: error parsing regexp: invalid character class range: `A--`"},
+		tcase{func(l *List) { l.MinTTL = -1 }, "ttl can not be negative"},
+		tcase{func(l *List) { l.MinTTL, l.MaxTTL = 600, 300 }, "min ttl is greater than max ttl"},
+		tcase{func(l *List) { l.Source = "dns" }, `unknown source "dns"`},
+		tcase{func(l *List) { l.Direction = "sideways" }, `unknown direction "sideways"`},
+		tcase{func(l *List) { l.Group = "Not A Tag" }, `invalid group "Not A Tag"`},
+		tcase{func(l *List) { l.ListType = "greylist" }, `unknown list type "greylist"`},
+		tcase{func(l *List) { l.ListType = Tag }, "missing tag"},
+		tcase{func(l *List) { l.ListType, l.Tag = Suppress, "noisy" }, "tag is only used by tag lists"},
+	}
+
+	for _, tc := range cases {
+		l := createList("*", "*", "*")
+		tc.modify(&l)
+
+		err := l.Validate()
+		if err == nil {
+			t.Errorf("Validate() expected %q got nil", tc.expected)
+			continue
+		}
+		if err.Error() != tc.expected {
+			t.Errorf("Validate() expected %q got %q", tc.expected, err)
+		}
+	}
+
+	l := createList("*", "*", "*")
+	l.ListType = Tag
+	l.Tag = "verification"
+	l.Fields = `"google-site-verification=.*"`
+	l.MinTTL = 60
+	l.MaxTTL = 3600
+	l.Source = "axfr"
+	l.Direction = DirectionAddition
+	l.Group = "prod"
+
+	if err := l.Validate(); err != nil {
+		t.Fatalf("Validate() expected nil got %q", err)
+	}
+}
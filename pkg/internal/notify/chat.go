@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// postJSON sends a payload to a chat incoming webhook
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "Do")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// diff renders an item's records in diff format, limited to max bytes so
+// that it fits in to the chat service's message limits
+func diff(item Item, max int) string {
+	lines := make([]string, 0, len(item.Additions)+len(item.Removals)+len(item.Lines))
+
+	for _, r := range item.Additions {
+		lines = append(lines, "+ "+r.Raw)
+	}
+
+	for _, r := range item.Removals {
+		lines = append(lines, "- "+r.Raw)
+	}
+
+	lines = append(lines, item.Lines...)
+
+	var b strings.Builder
+	for idx, line := range lines {
+		more := fmt.Sprintf("... and %d more", len(lines)-idx)
+		if b.Len()+len(line)+len(more)+1 > max {
+			b.WriteString(more)
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
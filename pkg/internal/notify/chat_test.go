@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testNotification = Notification{
+	Subject: "ALARM BELLS - Changes to 2 domains",
+	Items: []Item{
+		{
+			Type:      ItemChanges,
+			DomainID:  1,
+			Domain:    "example.com",
+			URL:       "https://whois.bi/domain/example.com",
+			Message:   "New changes have been detected",
+			Additions: []Record{{"A", "example.com.\t300\tIN\tA\t127.0.0.1"}},
+			Removals:  []Record{{"TXT", "example.com.\t300\tIN\tTXT\t\"<script>\""}},
+		},
+		{
+			Type:     ItemDrift,
+			DomainID: 2,
+			Domain:   "example.org",
+			URL:      "https://whois.bi/domain/example.org",
+			Message:  "Records for example.org no longer match the expected records",
+			Lines:    []string{"missing\texample.org.\t300\tIN\tMX\t10 mx.example.org."},
+		},
+	},
+}
+
+func Test_For(t *testing.T) {
+	if n := testNotification.For(0); len(n.Items) != 2 {
+		t.Fatalf("For(0) expected 2 items got %d", len(n.Items))
+	}
+
+	n := testNotification.For(2)
+	if len(n.Items) != 1 || n.Items[0].Domain != "example.org" || n.Subject != testNotification.Subject {
+		t.Fatalf("For(2) unexpected notification: %+v", n)
+	}
+
+	if n := testNotification.For(3); len(n.Items) != 0 {
+		t.Fatalf("For(3) expected 0 items got %d", len(n.Items))
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	item := testNotification.Items[0]
+
+	d := diff(item, 1024)
+	expected := "+ " + item.Additions[0].Raw + "\n- " + item.Removals[0].Raw
+	if d != expected {
+		t.Fatalf("diff() expected %q got %q", expected, d)
+	}
+
+	d = diff(item, 60)
+	if !strings.HasSuffix(d, "... and 1 more") || len(d) > 60 {
+		t.Fatalf("diff() expected truncated output got %q", d)
+	}
+}
+
+// capture starts a server that records the body of the last request
+func capture(t *testing.T, status int) (*httptest.Server, *[]byte) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	return server, &body
+}
+
+func Test_SlackNotifier(t *testing.T) {
+	server, body := capture(t, http.StatusOK)
+	defer server.Close()
+
+	n := NewSlackNotifier(server.Client(), server.URL)
+	if err := n.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("Notify() expected nil got %q", err)
+	}
+
+	var msg slackMessage
+	if err := json.Unmarshal(*body, &msg); err != nil {
+		t.Fatalf("Unmarshal() expected nil got %q", err)
+	}
+
+	// header, then a section and diff for each domain
+	if msg.Text != testNotification.Subject || len(msg.Blocks) != 5 || msg.Blocks[0].Type != "header" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	if !strings.Contains(msg.Blocks[2].Text.Text, "&lt;script&gt;") {
+		t.Fatalf("expected diff to be escaped got %q", msg.Blocks[2].Text.Text)
+	}
+}
+
+func Test_TeamsNotifier(t *testing.T) {
+	server, body := capture(t, http.StatusOK)
+	defer server.Close()
+
+	n := NewTeamsNotifier(server.Client(), server.URL)
+	if err := n.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("Notify() expected nil got %q", err)
+	}
+
+	var card teamsCard
+	if err := json.Unmarshal(*body, &card); err != nil {
+		t.Fatalf("Unmarshal() expected nil got %q", err)
+	}
+
+	if card.Type != "MessageCard" || len(card.Sections) != 2 {
+		t.Fatalf("unexpected card: %+v", card)
+	}
+
+	if card.Sections[0].PotentialAction[0].Targets[0].URI != testNotification.Items[0].URL {
+		t.Fatalf("unexpected action: %+v", card.Sections[0].PotentialAction)
+	}
+}
+
+func Test_DiscordNotifier(t *testing.T) {
+	server, body := capture(t, http.StatusNoContent)
+	defer server.Close()
+
+	n := NewDiscordNotifier(server.Client(), server.URL)
+	if err := n.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("Notify() expected nil got %q", err)
+	}
+
+	var msg discordMessage
+	if err := json.Unmarshal(*body, &msg); err != nil {
+		t.Fatalf("Unmarshal() expected nil got %q", err)
+	}
+
+	if len(msg.Embeds) != 2 || !strings.Contains(msg.Embeds[0].Description, "```diff") {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func Test_NotifyError(t *testing.T) {
+	server, _ := capture(t, http.StatusBadRequest)
+	defer server.Close()
+
+	n := NewSlackNotifier(server.Client(), server.URL)
+	if err := n.Notify(context.Background(), testNotification); err == nil {
+		t.Fatal("Notify() expected error")
+	}
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// discord allows 10 embeds per message
+	discordMaxItems = 10
+	// and 4096 characters per embed description
+	discordMaxText = 3900
+	// orange
+	discordColor = 0xf0a030
+)
+
+// DiscordNotifier posts notifications to a Discord webhook using embeds
+type DiscordNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func NewDiscordNotifier(client *http.Client, url string) *DiscordNotifier {
+	return &DiscordNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+}
+
+type discordMessage struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+func (n *DiscordNotifier) Notify(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, n.client, n.url, renderDiscord(notification))
+}
+
+func renderDiscord(n Notification) discordMessage {
+	msg := discordMessage{
+		Content: n.Subject,
+		Embeds:  make([]discordEmbed, 0, len(n.Items)),
+	}
+
+	for idx, item := range n.Items {
+		if idx == discordMaxItems {
+			msg.Content += fmt.Sprintf(" (showing %d of %d domains)", idx, len(n.Items))
+			break
+		}
+
+		description := item.Summary()
+		if d := diff(item, discordMaxText); len(d) > 0 {
+			// stop records breaking out of the code block
+			d = strings.ReplaceAll(d, "```", "'''")
+			description += "\n```diff\n" + d + "\n```"
+		}
+
+		msg.Embeds = append(msg.Embeds, discordEmbed{
+			Title:       item.Domain,
+			URL:         item.URL,
+			Description: description,
+			Color:       discordColor,
+		})
+	}
+
+	return msg
+}
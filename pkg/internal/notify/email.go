@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/pkg/errors"
+	"github.com/segmentio/fasthash/fnv1a"
+)
+
+// EmailNotifier queues notifications in the outbox as a single email
+// rendered with the "alert" template
+type EmailNotifier struct {
+	db      orm.DB
+	emailer *emailer.Emailer
+	to      string
+	locale  string
+}
+
+func NewEmailNotifier(db orm.DB, e *emailer.Emailer, to, locale string) *EmailNotifier {
+	return &EmailNotifier{
+		db:      db,
+		emailer: e,
+		to:      to,
+		locale:  locale,
+	}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	key, err := n.key(notification)
+	if err != nil {
+		return err
+	}
+
+	return n.emailer.Queue(n.db, key, n.to, n.locale, "alert", notification)
+}
+
+// key is unique to the notification, recipient and contents. Routing can
+// send different parts of a notification to the same address, so the
+// contents are included to keep them apart.
+func (n *EmailNotifier) key(notification Notification) (string, error) {
+	if len(notification.Key) == 0 {
+		return "alert:" + uniuri.NewLen(32), nil
+	}
+
+	b, err := json.Marshal(&notification)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal")
+	}
+
+	return fmt.Sprintf("alert:%s:%s:%x", notification.Key, n.to, fnv1a.HashBytes64(b)), nil
+}
@@ -0,0 +1,217 @@
+// Package notify sends alerts to the channels configured by users, such
+// as email or chat incoming webhooks.
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/jawr/whois-bi/pkg/internal/webhook"
+	"github.com/pkg/errors"
+)
+
+type Kind = string
+
+const (
+	KindEmail   Kind = "email"
+	KindSlack   Kind = "slack"
+	KindTeams   Kind = "teams"
+	KindDiscord Kind = "discord"
+)
+
+// Notification is a channel agnostic alert, each Notifier renders it in its
+// own format
+type Notification struct {
+	// identifies the notification so each destination only receives it
+	// once, even if sending is retried
+	Key string
+
+	Subject string
+	Items   []Item
+}
+
+type ItemType = string
+
+const (
+	// records were added or removed, or whois was updated
+	ItemChanges ItemType = "changes"
+	// records differ from the expected records
+	ItemDrift ItemType = "drift"
+	// the domain is about to expire
+	ItemExpiry ItemType = "expiry"
+)
+
+// Record is a record that was added or removed
+type Record struct {
+	RRType string
+	Raw    string
+}
+
+// Item is the part of a Notification about a single domain
+type Item struct {
+	Type ItemType
+
+	DomainID int
+	Domain   string
+	// link to the domain in the frontend
+	URL string
+	// one click link to acknowledge the alert, can be empty
+	AcknowledgeURL string
+	// short description of what happened
+	Message string
+
+	// whois has been updated
+	Whois bool
+
+	// the domain's groups, used by routing rules
+	Groups []string
+
+	// added by the owner's tag lists
+	Tags []string
+
+	// records that were added or removed
+	Additions []Record
+	Removals  []Record
+
+	// other lines to show, such as drift from expected records
+	Lines []string
+}
+
+// Summary is the Message with any additional notes
+func (i Item) Summary() string {
+	summary := i.Message
+	if i.Whois {
+		summary += ", whois has been updated"
+	}
+	if len(i.Tags) > 0 {
+		summary += " [" + strings.Join(i.Tags, ", ") + "]"
+	}
+	return summary
+}
+
+// inGroup returns true if the item's domain is in the group
+func (i Item) inGroup(group string) bool {
+	for _, g := range i.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the Notification with only the Items for the domain, a
+// domainID of 0 returns all Items
+func (n Notification) For(domainID int) Notification {
+	if domainID == 0 {
+		return n
+	}
+
+	filtered := Notification{
+		Key:     n.Key,
+		Subject: n.Subject,
+	}
+
+	for _, item := range n.Items {
+		if item.DomainID == domainID {
+			filtered.Items = append(filtered.Items, item)
+		}
+	}
+
+	return filtered
+}
+
+// Notifier sends a Notification to a channel
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Channel is a destination for a user's alerts, optionally limited to a
+// single domain
+type Channel struct {
+	ID int `pg:",pk" json:"id"`
+
+	OwnerID int       `pg:",notnull" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	// 0 for channels in the owner's own account
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	// 0 means all of the account or organisation's domains
+	DomainID int `pg:",notnull,use_zero" json:"domain_id"`
+
+	Name string `pg:",notnull,use_zero" json:"name"`
+	Kind Kind   `pg:",notnull,type:text" json:"kind"`
+
+	// email address or incoming webhook url
+	Target string `pg:",notnull" json:"target"`
+
+	AddedAt   time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+	DeletedAt time.Time `pg:",soft_delete" json:"deleted_at"`
+}
+
+// Validate checks the target is valid for the kind of channel
+func (c Channel) Validate() error {
+	switch c.Kind {
+	case KindEmail:
+		if !strings.Contains(c.Target, "@") {
+			return errors.Errorf("invalid email address %q", c.Target)
+		}
+		return nil
+
+	case KindSlack, KindTeams, KindDiscord:
+		u, err := url.Parse(c.Target)
+		if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+			return errors.Errorf("invalid webhook url %q, expected https://host/...", c.Target)
+		}
+		return nil
+	}
+
+	return errors.Errorf("unknown channel kind %q", c.Kind)
+}
+
+// GetChannels returns all of the owner's channels
+func GetChannels(db orm.DB, ownerID, organisationID int) ([]Channel, error) {
+	channels := make([]Channel, 0)
+	err := db.Model(&channels).
+		Where("owner_id = ? AND organisation_id = ?", ownerID, organisationID).
+		Order("id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// used for all chat webhooks, urls are user supplied so it only connects
+// to public addresses
+var client = func() *http.Client {
+	c := webhook.NewClient()
+	c.Timeout = time.Second * 10
+	return c
+}()
+
+// NewNotifier creates a Notifier for the channel, emails are rendered in
+// the provided locale and queued in the outbox. Chat channels are only
+// sent a notification once, see Sent
+func NewNotifier(db orm.DB, c Channel, e *emailer.Emailer, locale string) (Notifier, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.Kind {
+	case KindSlack:
+		return &onceNotifier{db, c.ID, NewSlackNotifier(client, c.Target)}, nil
+	case KindTeams:
+		return &onceNotifier{db, c.ID, NewTeamsNotifier(client, c.Target)}, nil
+	case KindDiscord:
+		return &onceNotifier{db, c.ID, NewDiscordNotifier(client, c.Target)}, nil
+	}
+
+	return NewEmailNotifier(db, e, c.Target, locale), nil
+}
@@ -0,0 +1,382 @@
+package notify
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+type ChangeType = string
+
+const (
+	ChangeRecordAdd    ChangeType = "record_add"
+	ChangeRecordRemove ChangeType = "record_remove"
+	ChangeWhois        ChangeType = "whois"
+	ChangeExpiry       ChangeType = "expiry"
+	// any DNSSEC record was added or removed
+	ChangeDNSSEC ChangeType = "dnssec"
+	ChangeDrift  ChangeType = "drift"
+)
+
+var changeTypes = map[ChangeType]struct{}{
+	ChangeRecordAdd:    {},
+	ChangeRecordRemove: {},
+	ChangeWhois:        {},
+	ChangeExpiry:       {},
+	ChangeDNSSEC:       {},
+	ChangeDrift:        {},
+}
+
+type Severity = string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+var severities = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+var dnssecTypes = map[string]struct{}{
+	"DS":         {},
+	"DNSKEY":     {},
+	"RRSIG":      {},
+	"NSEC":       {},
+	"NSEC3":      {},
+	"NSEC3PARAM": {},
+	"CDS":        {},
+	"CDNSKEY":    {},
+}
+
+// record types that can take a domain offline or redirect its mail
+var warningTypes = map[string]struct{}{
+	"MX":  {},
+	"SOA": {},
+	"CAA": {},
+}
+
+// severity of a change, record changes are graded by their type
+func severity(changeType ChangeType, rrtype string) Severity {
+	switch changeType {
+	case ChangeExpiry, ChangeDNSSEC:
+		return SeverityCritical
+	case ChangeWhois, ChangeDrift:
+		return SeverityWarning
+	}
+
+	if rrtype == "NS" {
+		return SeverityCritical
+	}
+
+	if _, ok := warningTypes[rrtype]; ok {
+		return SeverityWarning
+	}
+
+	return SeverityInfo
+}
+
+// Rule routes matching changes to channels and recipients instead of the
+// default channels. Rules are evaluated in Position order and the first
+// matching rule wins, a rule without any channels or recipients drops the
+// change.
+type Rule struct {
+	ID int `pg:",pk" json:"id"`
+
+	OwnerID int       `pg:",notnull" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	// 0 for rules in the owner's own account
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	Position int    `pg:",notnull,use_zero" json:"position"`
+	Name     string `pg:",notnull,use_zero" json:"name"`
+
+	// regular expressions or *
+	Domain string `pg:",notnull" json:"domain"`
+	// only matches domains in the group unless empty
+	Group string `pg:"domain_group,notnull,use_zero" json:"group"`
+	// only matches record changes unless *
+	RRType string `pg:",notnull" json:"rr_type"`
+
+	// empty matches all change types
+	ChangeTypes []string `pg:",use_zero" json:"change_types"`
+	MinSeverity Severity `pg:",notnull,type:text,default:'info'" json:"min_severity"`
+
+	// where to send matching changes
+	ChannelIDs []int    `pg:",use_zero" json:"channel_ids"`
+	Recipients []string `pg:",use_zero" json:"recipients"`
+
+	AddedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+}
+
+func anchor(s string) string {
+	return "^" + s + "$"
+}
+
+func (r Rule) Validate() error {
+	if len(r.Domain) == 0 || len(r.RRType) == 0 {
+		return errors.New("missing fields")
+	}
+
+	if r.Domain != "*" {
+		if _, err := regexp.Compile(anchor(r.Domain)); err != nil {
+			return errors.WithMessage(err, "Domain")
+		}
+	}
+
+	if r.RRType != "*" {
+		if _, err := regexp.Compile(anchor(r.RRType)); err != nil {
+			return errors.WithMessage(err, "RRType")
+		}
+	}
+
+	if len(r.Group) > 0 && !domain.ValidTag(r.Group) {
+		return errors.Errorf("invalid group %q", r.Group)
+	}
+
+	for _, c := range r.ChangeTypes {
+		if _, ok := changeTypes[c]; !ok {
+			return errors.Errorf("unknown change type %q", c)
+		}
+	}
+
+	if _, ok := severities[r.MinSeverity]; !ok {
+		return errors.Errorf("unknown severity %q", r.MinSeverity)
+	}
+
+	for _, email := range r.Recipients {
+		if !strings.Contains(email, "@") {
+			return errors.Errorf("invalid recipient %q", email)
+		}
+	}
+
+	return nil
+}
+
+// GetRules returns the owner's rules in the organisation, 0 for the
+// owner's own account, in evaluation order
+func GetRules(db orm.DB, ownerID, organisationID int) ([]Rule, error) {
+	rules := make([]Rule, 0)
+	err := db.Model(&rules).
+		Where("owner_id = ? AND organisation_id = ?", ownerID, organisationID).
+		Order("position", "id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Destination is where routed changes are sent, either one of the owner's
+// channels or an email address
+type Destination struct {
+	ChannelID int
+	Email     string
+}
+
+// DefaultDestination receives changes that did not match any rule, they
+// are sent to the owner's channels
+var DefaultDestination = Destination{}
+
+type compiledRule struct {
+	Rule
+
+	domain      *regexp.Regexp
+	rrtype      *regexp.Regexp
+	changeTypes map[ChangeType]struct{}
+}
+
+// Router splits Notifications between Destinations using Rules
+type Router struct {
+	rules []compiledRule
+}
+
+// NewRouter compiles the rules, they are sorted in to evaluation order
+func NewRouter(rules []Rule) (*Router, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, errors.WithMessagef(err, "rule %d", r.ID)
+		}
+
+		c := compiledRule{
+			Rule:        r,
+			changeTypes: make(map[ChangeType]struct{}, len(r.ChangeTypes)),
+		}
+
+		if r.Domain != "*" {
+			c.domain = regexp.MustCompile(anchor(r.Domain))
+		}
+
+		if r.RRType != "*" {
+			c.rrtype = regexp.MustCompile(anchor(r.RRType))
+		}
+
+		for _, t := range r.ChangeTypes {
+			c.changeTypes[t] = struct{}{}
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		if compiled[i].Position == compiled[j].Position {
+			return compiled[i].ID < compiled[j].ID
+		}
+		return compiled[i].Position < compiled[j].Position
+	})
+
+	return &Router{rules: compiled}, nil
+}
+
+// change is the smallest part of an Item that can be routed
+type change struct {
+	changeType ChangeType
+	severity   Severity
+
+	record  Record
+	removed bool
+}
+
+// changes splits an item in to its changes
+func changes(item Item) []change {
+	switch item.Type {
+	case ItemDrift:
+		return []change{{changeType: ChangeDrift, severity: severity(ChangeDrift, "")}}
+	case ItemExpiry:
+		return []change{{changeType: ChangeExpiry, severity: severity(ChangeExpiry, "")}}
+	}
+
+	out := make([]change, 0, len(item.Additions)+len(item.Removals)+1)
+
+	if item.Whois {
+		out = append(out, change{changeType: ChangeWhois, severity: severity(ChangeWhois, "")})
+	}
+
+	records := func(records []Record, changeType ChangeType, removed bool) {
+		for _, r := range records {
+			t := changeType
+			if _, ok := dnssecTypes[r.RRType]; ok {
+				t = ChangeDNSSEC
+			}
+
+			out = append(out, change{
+				changeType: t,
+				severity:   severity(t, r.RRType),
+				record:     r,
+				removed:    removed,
+			})
+		}
+	}
+
+	records(item.Additions, ChangeRecordAdd, false)
+	records(item.Removals, ChangeRecordRemove, true)
+
+	return out
+}
+
+func (r compiledRule) match(item Item, c change) bool {
+	if r.domain != nil && !r.domain.MatchString(item.Domain) {
+		return false
+	}
+
+	if len(r.Group) > 0 && !item.inGroup(r.Group) {
+		return false
+	}
+
+	if r.rrtype != nil && (len(c.record.RRType) == 0 || !r.rrtype.MatchString(c.record.RRType)) {
+		return false
+	}
+
+	if len(r.changeTypes) > 0 {
+		if _, ok := r.changeTypes[c.changeType]; !ok {
+			return false
+		}
+	}
+
+	return severities[c.severity] >= severities[r.MinSeverity]
+}
+
+// destinations returns where a change should be sent
+func (r *Router) destinations(item Item, c change) []Destination {
+	for _, rule := range r.rules {
+		if !rule.match(item, c) {
+			continue
+		}
+
+		dests := make([]Destination, 0, len(rule.ChannelIDs)+len(rule.Recipients))
+		for _, id := range rule.ChannelIDs {
+			dests = append(dests, Destination{ChannelID: id})
+		}
+		for _, email := range rule.Recipients {
+			dests = append(dests, Destination{Email: email})
+		}
+		return dests
+	}
+
+	return []Destination{DefaultDestination}
+}
+
+// Route splits the notification between destinations, each change is sent
+// to the destinations of the first rule it matches
+func (r *Router) Route(n Notification) map[Destination]Notification {
+	routed := make(map[Destination]Notification)
+
+	for _, item := range n.Items {
+		// the part of this item going to each destination
+		parts := make(map[Destination]*Item)
+		order := make([]Destination, 0)
+
+		for _, c := range changes(item) {
+			for _, dest := range r.destinations(item, c) {
+				part, ok := parts[dest]
+				if !ok {
+					part = &Item{
+						Type:           item.Type,
+						DomainID:       item.DomainID,
+						Domain:         item.Domain,
+						URL:            item.URL,
+						AcknowledgeURL: item.AcknowledgeURL,
+						Message:        item.Message,
+						Groups:         item.Groups,
+						Tags:           item.Tags,
+						Lines:          item.Lines,
+					}
+					parts[dest] = part
+					order = append(order, dest)
+				}
+
+				switch {
+				case c.changeType == ChangeWhois:
+					part.Whois = true
+				case len(c.record.Raw) == 0:
+				case c.removed:
+					part.Removals = append(part.Removals, c.record)
+				default:
+					part.Additions = append(part.Additions, c.record)
+				}
+			}
+		}
+
+		for _, dest := range order {
+			out := routed[dest]
+			out.Key = n.Key
+			out.Subject = n.Subject
+			out.Items = append(out.Items, *parts[dest])
+			routed[dest] = out
+		}
+	}
+
+	return routed
+}
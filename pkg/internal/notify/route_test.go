@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"testing"
+)
+
+func Test_RuleValidate(t *testing.T) {
+	valid := Rule{Domain: "*", RRType: "MX|NS", MinSeverity: SeverityInfo}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() expected nil got %q", err)
+	}
+
+	invalid := []Rule{
+		{Domain: "", RRType: "*", MinSeverity: SeverityInfo},
+		{Domain: "(", RRType: "*", MinSeverity: SeverityInfo},
+		{Domain: "*", RRType: "*", MinSeverity: "loud"},
+		{Domain: "*", RRType: "*", MinSeverity: SeverityInfo, ChangeTypes: []string{"nope"}},
+		{Domain: "*", RRType: "*", MinSeverity: SeverityInfo, Recipients: []string{"nope"}},
+		{Domain: "*", RRType: "*", MinSeverity: SeverityInfo, Group: "Not A Tag"},
+	}
+
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Fatalf("Validate() expected error for %+v", r)
+		}
+	}
+}
+
+func Test_Route(t *testing.T) {
+	rules := []Rule{
+		// evaluated last
+		{ID: 1, Position: 10, Domain: ".*", RRType: "TXT", MinSeverity: SeverityInfo, Recipients: []string{"digest@example.com"}},
+		// mx changes on production domains page security
+		{ID: 2, Position: 0, Domain: "prod\\..*", RRType: "MX", MinSeverity: SeverityInfo, ChannelIDs: []int{7}, Recipients: []string{"security@example.com"}},
+		// drop anything else on staging
+		{ID: 3, Position: 1, Domain: "staging\\..*", RRType: "*", MinSeverity: SeverityInfo},
+		// whois and expiry to a channel
+		{ID: 4, Position: 2, Domain: "*", RRType: "*", MinSeverity: SeverityInfo, ChangeTypes: []string{ChangeWhois, ChangeExpiry}, ChannelIDs: []int{8}},
+	}
+
+	router, err := NewRouter(rules)
+	if err != nil {
+		t.Fatalf("NewRouter() expected nil got %q", err)
+	}
+
+	n := Notification{
+		Subject: "changes",
+		Items: []Item{
+			{
+				Type:   ItemChanges,
+				Domain: "prod.example.com",
+				Whois:  true,
+				Additions: []Record{
+					{"MX", "prod mx"},
+					{"TXT", "prod txt"},
+					{"A", "prod a"},
+				},
+				Removals: []Record{
+					{"MX", "prod old mx"},
+				},
+			},
+			{
+				Type:      ItemChanges,
+				Domain:    "staging.example.com",
+				Additions: []Record{{"MX", "staging mx"}},
+			},
+			{
+				Type:   ItemExpiry,
+				Domain: "staging.example.com",
+			},
+		},
+	}
+
+	routed := router.Route(n)
+
+	security := routed[Destination{Email: "security@example.com"}]
+	if len(security.Items) != 1 || len(security.Items[0].Additions) != 1 || len(security.Items[0].Removals) != 1 || security.Items[0].Whois {
+		t.Fatalf("unexpected security notification: %+v", security)
+	}
+
+	if channel := routed[Destination{ChannelID: 7}]; len(channel.Items) != 1 || channel.Items[0].Additions[0].Raw != "prod mx" {
+		t.Fatalf("unexpected channel 7 notification: %+v", channel)
+	}
+
+	digest := routed[Destination{Email: "digest@example.com"}]
+	if len(digest.Items) != 1 || len(digest.Items[0].Additions) != 1 || digest.Items[0].Additions[0].Raw != "prod txt" {
+		t.Fatalf("unexpected digest notification: %+v", digest)
+	}
+
+	// staging expiry is dropped as the staging rule is evaluated first
+	whois := routed[Destination{ChannelID: 8}]
+	if len(whois.Items) != 1 || !whois.Items[0].Whois || len(whois.Items[0].Additions) != 0 {
+		t.Fatalf("unexpected channel 8 notification: %+v", whois)
+	}
+
+	def := routed[DefaultDestination]
+	if len(def.Items) != 1 || len(def.Items[0].Additions) != 1 || def.Items[0].Additions[0].Raw != "prod a" || def.Subject != "changes" {
+		t.Fatalf("unexpected default notification: %+v", def)
+	}
+
+	if len(routed) != 5 {
+		t.Fatalf("expected 5 destinations got %d", len(routed))
+	}
+}
+
+func Test_RouteSeverity(t *testing.T) {
+	router, err := NewRouter([]Rule{
+		{Domain: "*", RRType: "*", MinSeverity: SeverityCritical, Recipients: []string{"oncall@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() expected nil got %q", err)
+	}
+
+	routed := router.Route(Notification{
+		Items: []Item{
+			{
+				Type:      ItemChanges,
+				Domain:    "example.com",
+				Additions: []Record{{"DS", "ds"}, {"NS", "ns"}, {"A", "a"}},
+			},
+		},
+	})
+
+	oncall := routed[Destination{Email: "oncall@example.com"}]
+	if len(oncall.Items) != 1 || len(oncall.Items[0].Additions) != 2 {
+		t.Fatalf("unexpected oncall notification: %+v", oncall)
+	}
+
+	if def := routed[DefaultDestination]; len(def.Items) != 1 || def.Items[0].Additions[0].Raw != "a" {
+		t.Fatalf("unexpected default notification: %+v", def)
+	}
+}
+
+func Test_RouteKeepsItemDetails(t *testing.T) {
+	router, err := NewRouter(nil)
+	if err != nil {
+		t.Fatalf("NewRouter() expected nil got %q", err)
+	}
+
+	routed := router.Route(Notification{
+		Key:     "alerts:1",
+		Subject: "changes",
+		Items: []Item{
+			{
+				Type:           ItemChanges,
+				Domain:         "example.com",
+				Message:        "New changes have been detected",
+				AcknowledgeURL: "https://whois.bi/acknowledge/abc",
+				Tags:           []string{"verification"},
+				Additions:      []Record{{"TXT", "txt"}},
+			},
+		},
+	})
+
+	n, ok := routed[DefaultDestination]
+	if !ok || n.Key != "alerts:1" || len(n.Items) != 1 {
+		t.Fatalf("unexpected routing %+v", routed)
+	}
+
+	item := n.Items[0]
+
+	if item.AcknowledgeURL != "https://whois.bi/acknowledge/abc" {
+		t.Errorf("expected the acknowledge url got %q", item.AcknowledgeURL)
+	}
+
+	if item.Summary() != "New changes have been detected [verification]" {
+		t.Errorf("unexpected summary %q", item.Summary())
+	}
+}
+
+func Test_RouteGroup(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Domain: "*", RRType: "*", Group: "prod", MinSeverity: SeverityInfo, ChannelIDs: []int{7}},
+	}
+
+	router, err := NewRouter(rules)
+	if err != nil {
+		t.Fatalf("NewRouter() expected nil got %q", err)
+	}
+
+	routed := router.Route(Notification{
+		Subject: "changes",
+		Items: []Item{
+			{Type: ItemChanges, Domain: "a.example.com", Groups: []string{"mail", "prod"}, Additions: []Record{{"A", "a"}}},
+			{Type: ItemChanges, Domain: "b.example.com", Groups: []string{"staging"}, Additions: []Record{{"A", "b"}}},
+		},
+	})
+
+	channel := routed[Destination{ChannelID: 7}]
+	if len(channel.Items) != 1 || channel.Items[0].Domain != "a.example.com" || len(channel.Items[0].Groups) != 2 {
+		t.Fatalf("unexpected channel routing %+v", channel)
+	}
+
+	fallback := routed[DefaultDestination]
+	if len(fallback.Items) != 1 || fallback.Items[0].Domain != "b.example.com" {
+		t.Fatalf("unexpected default routing %+v", fallback)
+	}
+}
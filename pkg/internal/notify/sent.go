@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+	"github.com/segmentio/fasthash/fnv1a"
+)
+
+// Sent records a notification posted to a chat channel. Emails are only
+// queued once by the outbox, chat posts are sent straight away so failed
+// notifications that are retried skip the channels that already have them
+type Sent struct {
+	tableName struct{} `pg:"notifications_sent,alias:sent"`
+
+	ID int `pg:",pk"`
+
+	Key       string `pg:",notnull,unique"`
+	ChannelID int    `pg:",notnull"`
+
+	CreatedAt time.Time `pg:",type:timestamptz,notnull,default:now()"`
+}
+
+// onceNotifier posts a notification to a chat channel at most once
+type onceNotifier struct {
+	db        orm.DB
+	channelID int
+	notifier  Notifier
+}
+
+func (n *onceNotifier) Notify(ctx context.Context, notification Notification) error {
+	// notifications without a key are never retried
+	if len(notification.Key) == 0 {
+		return n.notifier.Notify(ctx, notification)
+	}
+
+	key, err := sentKey(n.channelID, notification)
+	if err != nil {
+		return err
+	}
+
+	exists, err := n.db.Model((*Sent)(nil)).Where("key = ?", key).Exists()
+	if err != nil {
+		return errors.Wrap(err, "Exists")
+	}
+
+	if exists {
+		return nil
+	}
+
+	if err := n.notifier.Notify(ctx, notification); err != nil {
+		return err
+	}
+
+	sent := Sent{
+		Key:       key,
+		ChannelID: n.channelID,
+	}
+
+	if _, err := n.db.Model(&sent).OnConflict("(key) DO NOTHING").Insert(); err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+
+	return nil
+}
+
+// sentKey is unique to the notification, channel and contents. Routing can
+// send different parts of a notification to the same channel, so the
+// contents are included to keep them apart.
+func sentKey(channelID int, notification Notification) (string, error) {
+	b, err := json.Marshal(&notification)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal")
+	}
+
+	return fmt.Sprintf("%s:%d:%x", notification.Key, channelID, fnv1a.HashBytes64(b)), nil
+}
+
+// PruneSent removes records of notifications sent before the time, which
+// are too old to be retried
+func PruneSent(db orm.DB, before time.Time) (int, error) {
+	res, err := db.Model((*Sent)(nil)).Where("created_at < ?", before).Delete()
+	if err != nil {
+		return 0, errors.Wrap(err, "Delete")
+	}
+
+	return res.RowsAffected(), nil
+}
@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"testing"
+)
+
+func Test_SentKey(t *testing.T) {
+	n := testNotification
+	n.Key = "alerts:changes:1"
+
+	key, err := sentKey(1, n)
+	if err != nil {
+		t.Fatalf("sentKey() expected nil got %q", err)
+	}
+
+	again, err := sentKey(1, n)
+	if err != nil || again != key {
+		t.Fatalf("sentKey() expected %q got %q (%v)", key, again, err)
+	}
+
+	other, _ := sentKey(2, n)
+	if other == key {
+		t.Fatal("sentKey() expected channels to have different keys")
+	}
+
+	part, _ := sentKey(1, n.For(1))
+	if part == key {
+		t.Fatal("sentKey() expected different contents to have different keys")
+	}
+}
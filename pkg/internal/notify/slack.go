@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// slack allows 50 blocks per message
+	slackMaxItems = 24
+	// and 3000 characters per text object
+	slackMaxText = 2900
+)
+
+// SlackNotifier posts notifications to a Slack incoming webhook using
+// Block Kit
+type SlackNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func NewSlackNotifier(client *http.Client, url string) *SlackNotifier {
+	return &SlackNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackMessage struct {
+	// fallback for notifications
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, n.client, n.url, renderSlack(notification))
+}
+
+func renderSlack(n Notification) slackMessage {
+	msg := slackMessage{
+		Text: n.Subject,
+		Blocks: []slackBlock{
+			{
+				Type: "header",
+				Text: &slackText{Type: "plain_text", Text: n.Subject},
+			},
+		},
+	}
+
+	for idx, item := range n.Items {
+		if idx == slackMaxItems {
+			msg.Blocks = append(msg.Blocks, slackBlock{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("... and %d more domains", len(n.Items)-idx)},
+			})
+			break
+		}
+
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("*<%s|%s>*\n%s", item.URL, slackEscape(item.Domain), slackEscape(item.Summary())),
+			},
+		})
+
+		if d := diff(item, slackMaxText); len(d) > 0 {
+			msg.Blocks = append(msg.Blocks, slackBlock{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: "```" + slackEscape(d) + "```"},
+			})
+		}
+	}
+
+	return msg
+}
+
+// escape the control characters used by mrkdwn
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+)
+
+const (
+	// keep well within the 28KB message limit
+	teamsMaxItems = 10
+	teamsMaxText  = 2000
+)
+
+// TeamsNotifier posts notifications to a Microsoft Teams incoming webhook
+// as a MessageCard
+type TeamsNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func NewTeamsNotifier(client *http.Client, url string) *TeamsNotifier {
+	return &TeamsNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsSection struct {
+	ActivityTitle    string        `json:"activityTitle"`
+	ActivitySubtitle string        `json:"activitySubtitle"`
+	Text             string        `json:"text,omitempty"`
+	PotentialAction  []teamsAction `json:"potentialAction"`
+}
+
+type teamsCard struct {
+	Type     string         `json:"@type"`
+	Context  string         `json:"@context"`
+	Summary  string         `json:"summary"`
+	Title    string         `json:"title"`
+	Text     string         `json:"text,omitempty"`
+	Sections []teamsSection `json:"sections"`
+}
+
+func (n *TeamsNotifier) Notify(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, n.client, n.url, renderTeams(notification))
+}
+
+func renderTeams(n Notification) teamsCard {
+	card := teamsCard{
+		Type:     "MessageCard",
+		Context:  "http://schema.org/extensions",
+		Summary:  n.Subject,
+		Title:    n.Subject,
+		Sections: make([]teamsSection, 0, len(n.Items)),
+	}
+
+	for idx, item := range n.Items {
+		if idx == teamsMaxItems {
+			card.Text = fmt.Sprintf("Showing %d of %d domains", idx, len(n.Items))
+			break
+		}
+
+		section := teamsSection{
+			ActivityTitle:    item.Domain,
+			ActivitySubtitle: item.Summary(),
+			PotentialAction: []teamsAction{
+				{
+					Type:    "OpenUri",
+					Name:    "View domain",
+					Targets: []teamsTarget{{OS: "default", URI: item.URL}},
+				},
+			},
+		}
+
+		if d := diff(item, teamsMaxText); len(d) > 0 {
+			section.Text = "<pre>" + html.EscapeString(d) + "</pre>"
+		}
+
+		card.Sections = append(card.Sections, section)
+	}
+
+	return card
+}
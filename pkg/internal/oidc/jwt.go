@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// allowed difference between our clock and the provider's
+const clockSkew = time.Minute
+
+// how often the provider's keys can be fetched when a token uses a key
+// we do not know
+const keysRefresh = time.Minute
+
+// jwk is a JSON Web Key, only RSA and P-256 keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "DecodeString")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// publicKey returns the key as an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, errors.Errorf("unsupported key type %q", k.Kty)
+}
+
+// key returns the provider's signing key with the id, fetching the keys
+// again if it is not known
+func (p *Provider) key(kid string, now time.Time) (jwk, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	find := func() (jwk, bool) {
+		for _, k := range p.keys {
+			if k.Use == "enc" {
+				continue
+			}
+			// tokens without a kid are only accepted with a single key
+			if k.Kid == kid || (len(kid) == 0 && len(p.keys) == 1) {
+				return k, true
+			}
+		}
+		return jwk{}, false
+	}
+
+	if k, ok := find(); ok {
+		return k, nil
+	}
+
+	if now.Sub(p.keysFetched) < keysRefresh {
+		return jwk{}, errors.Errorf("unknown key %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := p.getJSON(p.metadata.JWKSURI, &set); err != nil {
+		return jwk{}, errors.WithMessage(err, "jwks")
+	}
+
+	p.keys = set.Keys
+	p.keysFetched = now
+
+	if k, ok := find(); ok {
+		return k, nil
+	}
+
+	return jwk{}, errors.Errorf("unknown key %q", kid)
+}
+
+// verifySignature checks the signature of the signed part of a JWS
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	sum := sha256.Sum256(signed)
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 needs an RSA key")
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature)
+
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ES256 needs an EC key")
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return errors.Errorf("unsupported algorithm %q", alg)
+}
+
+// audience returns the aud claim which may be a string or a list
+func audience(claims Claims) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		var list []string
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Verify checks the ID token's signature and claims, returning its claims
+func (p *Provider) Verify(raw, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "header")
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, errors.Wrap(err, "header")
+	}
+
+	k, err := p.key(header.Kid, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key decides the algorithm so a token can not pick a weaker one
+	if len(k.Alg) > 0 && k.Alg != header.Alg {
+		return nil, errors.Errorf("key %q is for %s not %s", k.Kid, k.Alg, header.Alg)
+	}
+
+	key, err := k.publicKey()
+	if err != nil {
+		return nil, errors.WithMessage(err, "publicKey")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "signature")
+	}
+
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, errors.WithMessage(err, "verifySignature")
+	}
+
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "payload")
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, errors.Wrap(err, "payload")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.metadata.Issuer {
+		return nil, errors.Errorf("unexpected issuer %q", iss)
+	}
+
+	aud := audience(claims)
+	found := false
+	for _, a := range aud {
+		if a == p.config.ClientID {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("unexpected audience %v", aud)
+	}
+
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, errors.Errorf("unexpected authorized party %q", azp)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("token has expired")
+	}
+
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("token was issued in the future")
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce || len(nonce) == 0 {
+		return nil, errors.New("unexpected nonce")
+	}
+
+	if len(claims.Subject()) == 0 {
+		return nil, errors.New("missing subject")
+	}
+
+	return claims, nil
+}
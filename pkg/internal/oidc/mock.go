@@ -0,0 +1,231 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/pkg/errors"
+)
+
+// DefaultMockEmail is logged in by the MockProvider unless the login_hint
+// parameter asks for another email
+const DefaultMockEmail = "sso@example.com"
+
+// MockProvider is a local OpenID Connect provider for development and
+// tests. It logs in whoever is asked for without a password
+type MockProvider struct {
+	// set to where the provider is served from
+	Issuer   string
+	ClientID string
+
+	// added to every ID token, overriding the defaults
+	Claims Claims
+
+	key *rsa.PrivateKey
+
+	sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	email       string
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// NewMockProvider creates a MockProvider for the client, Issuer needs to
+// be set once its address is known
+func NewMockProvider(clientID string) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "GenerateKey")
+	}
+
+	return &MockProvider{
+		ClientID: clientID,
+		Claims:   Claims{},
+		key:      key,
+		codes:    make(map[string]mockCode),
+	}, nil
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, metadata{
+			Issuer:                m.Issuer,
+			AuthorizationEndpoint: m.Issuer + "/authorize",
+			TokenEndpoint:         m.Issuer + "/token",
+			JWKSURI:               m.Issuer + "/jwks",
+		})
+
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string][]jwk{
+			"keys": {{
+				Kty: "RSA",
+				Kid: "mock",
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+
+	case "/authorize":
+		m.authorize(w, r)
+
+	case "/token":
+		m.token(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// authorize logs in the login_hint email and redirects straight back
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != m.ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid client or response type", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge_method") != "S256" || len(query.Get("code_challenge")) == 0 {
+		http.Error(w, "missing PKCE challenge", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	email := query.Get("login_hint")
+	if len(email) == 0 {
+		email = DefaultMockEmail
+	}
+
+	code := uniuri.NewLen(32)
+
+	m.Lock()
+	m.codes[code] = mockCode{
+		email:       email,
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		redirectURI: redirect.String(),
+	}
+	m.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.Lock()
+	c, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.Unlock()
+
+	switch {
+	case !ok || r.PostForm.Get("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+
+	case r.PostForm.Get("client_id") != m.ClientID || r.PostForm.Get("redirect_uri") != c.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+
+	case Challenge(r.PostForm.Get("code_verifier")) != c.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+
+	claims := Claims{
+		"iss":            m.Issuer,
+		"aud":            m.ClientID,
+		"sub":            "mock|" + c.email,
+		"email":          c.email,
+		"email_verified": true,
+		"nonce":          c.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute * 5).Unix(),
+	}
+
+	m.Lock()
+	for k, v := range m.Claims {
+		claims[k] = v
+	}
+	m.Unlock()
+
+	idToken, err := m.Sign(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": uniuri.NewLen(32),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// Sign returns an RS256 ID token with the claims
+func (m *MockProvider) Sign(claims Claims) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock", "typ": "JWT"})
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal header")
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal claims")
+	}
+
+	signed := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(payload),
+	}, ".")
+
+	sum := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", errors.Wrap(err, "SignPKCS1v15")
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
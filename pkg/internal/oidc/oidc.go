@@ -0,0 +1,302 @@
+// Package oidc logs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config describes the provider and how its claims map to a user
+type Config struct {
+	// discovery is done using Issuer/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// scopes requested, openid is always requested
+	Scopes []string
+
+	// claim holding the user's email, nested claims are separated by a .
+	EmailClaim string
+	// require the email_verified claim to be true
+	RequireVerifiedEmail bool
+	// only allow emails in these domains, empty allows any
+	AllowedDomains []string
+	// create users the first time they log in
+	Provision bool
+}
+
+// ConfigFromEnv returns the config from the environment and false if
+// OIDC_ISSUER is not set
+func ConfigFromEnv() (Config, bool) {
+	config := Config{
+		Issuer:               os.Getenv("OIDC_ISSUER"),
+		ClientID:             os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:         os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:          os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:               splitList(os.Getenv("OIDC_SCOPES")),
+		EmailClaim:           os.Getenv("OIDC_EMAIL_CLAIM"),
+		RequireVerifiedEmail: os.Getenv("OIDC_REQUIRE_VERIFIED_EMAIL") != "false",
+		AllowedDomains:       splitList(os.Getenv("OIDC_ALLOWED_DOMAINS")),
+		Provision:            os.Getenv("OIDC_PROVISION") != "false",
+	}
+
+	if len(config.RedirectURL) == 0 {
+		config.RedirectURL = strings.TrimSuffix(os.Getenv("BASE_URL"), "/") + "/api/oidc/callback"
+	}
+
+	return config, len(config.Issuer) > 0
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// metadata is the part of the provider's discovery document we use
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a discovered OpenID Connect provider
+type Provider struct {
+	client   *http.Client
+	config   Config
+	metadata metadata
+
+	sync.Mutex
+	keys        []jwk
+	keysFetched time.Time
+}
+
+// NewProvider discovers the provider described by config
+func NewProvider(client *http.Client, config Config) (*Provider, error) {
+	if len(config.Issuer) == 0 || len(config.ClientID) == 0 {
+		return nil, errors.New("missing issuer or client id")
+	}
+
+	if len(config.RedirectURL) == 0 {
+		return nil, errors.New("missing redirect url")
+	}
+
+	if len(config.EmailClaim) == 0 {
+		config.EmailClaim = "email"
+	}
+
+	for i := range config.AllowedDomains {
+		config.AllowedDomains[i] = strings.ToLower(config.AllowedDomains[i])
+	}
+
+	p := &Provider{
+		client: client,
+		config: config,
+	}
+
+	discovery := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(discovery, &p.metadata); err != nil {
+		return nil, errors.WithMessage(err, "discovery")
+	}
+
+	// stops a compromised discovery document from vouching for another
+	// issuer's tokens
+	if p.metadata.Issuer != config.Issuer {
+		return nil, errors.Errorf("discovery issuer %q does not match %q", p.metadata.Issuer, config.Issuer)
+	}
+
+	if len(p.metadata.AuthorizationEndpoint) == 0 || len(p.metadata.TokenEndpoint) == 0 || len(p.metadata.JWKSURI) == 0 {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	return p, nil
+}
+
+// Provision returns true if users are created the first time they log in
+func (p *Provider) Provision() bool {
+	return p.config.Provision
+}
+
+func (p *Provider) getJSON(u string, v interface{}) error {
+	response, err := p.client.Get(u)
+	if err != nil {
+		return errors.Wrap(err, "Get")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d from %s", response.StatusCode, u)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(v); err != nil {
+		return errors.Wrap(err, "Decode")
+	}
+
+	return nil
+}
+
+// Challenge returns the PKCE S256 challenge for the verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns where to send the user to log in, state and nonce
+// are checked when they return and verifier is used by Exchange
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(p.config.Scopes) == 0 {
+		scopes = append(scopes, "email")
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.config.ClientID)
+	values.Set("redirect_uri", p.config.RedirectURL)
+	values.Set("scope", strings.Join(scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", Challenge(verifier))
+	values.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.metadata.AuthorizationEndpoint + sep + values.Encode()
+}
+
+// Exchange swaps the code the user returned with for their verified claims
+func (p *Provider) Exchange(code, nonce, verifier string, now time.Time) (Claims, error) {
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.config.RedirectURL)
+	values.Set("client_id", p.config.ClientID)
+	values.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if len(p.config.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	response, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Do")
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadAll")
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, errors.Wrapf(err, "unexpected response %d", response.StatusCode)
+	}
+
+	if response.StatusCode != http.StatusOK || len(token.Error) > 0 {
+		return nil, errors.Errorf("token error %d: %s %s", response.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	if len(token.IDToken) == 0 {
+		return nil, errors.New("missing id_token")
+	}
+
+	return p.Verify(token.IDToken, nonce, now)
+}
+
+// Email returns the user's email from their claims
+func (p *Provider) Email(claims Claims) (string, error) {
+	email, ok := claims.Lookup(p.config.EmailClaim).(string)
+	if !ok || !strings.Contains(email, "@") {
+		return "", errors.Errorf("claim %q is not an email", p.config.EmailClaim)
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if p.config.RequireVerifiedEmail {
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return "", errors.Errorf("email %q is not verified", email)
+		}
+	}
+
+	if len(p.config.AllowedDomains) == 0 {
+		return email, nil
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, allowed := range p.config.AllowedDomains {
+		if domain == allowed {
+			return email, nil
+		}
+	}
+
+	return "", errors.Errorf("email %q is not in an allowed domain", email)
+}
+
+// Claims are the claims of a verified ID token
+type Claims map[string]interface{}
+
+// Lookup returns the claim at path, nested claims are separated by a .
+func (c Claims) Lookup(path string) interface{} {
+	var value interface{} = map[string]interface{}(c)
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// Subject returns the sub claim which identifies the user at the provider
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// String describes the claims for logging
+func (c Claims) String() string {
+	return fmt.Sprintf("sub=%q iss=%q", c.Subject(), c["iss"])
+}
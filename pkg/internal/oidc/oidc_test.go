@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID    = "whoisbi"
+	testRedirectURL = "http://localhost:5000/api/oidc/callback"
+)
+
+func createMock(t *testing.T) (*MockProvider, *httptest.Server) {
+	t.Helper()
+
+	mock, err := NewMockProvider(testClientID)
+	if err != nil {
+		t.Fatalf("NewMockProvider() expected nil got %q", err)
+	}
+
+	server := httptest.NewServer(mock)
+	mock.Issuer = server.URL
+
+	return mock, server
+}
+
+func createProvider(t *testing.T, server *httptest.Server, config Config) *Provider {
+	t.Helper()
+
+	config.Issuer = server.URL
+	config.ClientID = testClientID
+	config.RedirectURL = testRedirectURL
+
+	p, err := NewProvider(server.Client(), config)
+	if err != nil {
+		t.Fatalf("NewProvider() expected nil got %q", err)
+	}
+
+	return p
+}
+
+// login follows the authorization redirect, returning the code
+func login(t *testing.T, p *Provider, state, nonce, verifier, email string) string {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response, err := client.Get(p.AuthCodeURL(state, nonce, verifier) + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatalf("Get() expected nil got %q", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusFound {
+		t.Fatalf("authorize expected %d got %d", http.StatusFound, response.StatusCode)
+	}
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Parse() expected nil got %q", err)
+	}
+
+	if !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("authorize unexpected redirect %q", location)
+	}
+
+	if location.Query().Get("state") != state {
+		t.Fatalf("authorize expected state %q got %q", state, location.Query().Get("state"))
+	}
+
+	return location.Query().Get("code")
+}
+
+func Test_Login(t *testing.T) {
+	t.Parallel()
+
+	_, server := createMock(t)
+	defer server.Close()
+
+	p := createProvider(t, server, Config{RequireVerifiedEmail: true})
+
+	code := login(t, p, "state", "nonce", "verifier-verifier-verifier-verifier-verifier", "Jess@Example.com")
+
+	claims, err := p.Exchange(code, "nonce", "verifier-verifier-verifier-verifier-verifier", time.Now())
+	if err != nil {
+		t.Fatalf("Exchange() expected nil got %q", err)
+	}
+
+	if claims.Subject() != "mock|Jess@Example.com" {
+		t.Errorf("Exchange() unexpected subject %q", claims.Subject())
+	}
+
+	email, err := p.Email(claims)
+	if err != nil {
+		t.Fatalf("Email() expected nil got %q", err)
+	}
+
+	if email != "jess@example.com" {
+		t.Errorf("Email() expected %q got %q", "jess@example.com", email)
+	}
+
+	// codes can only be used once
+	if _, err := p.Exchange(code, "nonce", "verifier-verifier-verifier-verifier-verifier", time.Now()); err == nil {
+		t.Error("Exchange() expected an error reusing a code got nil")
+	}
+}
+
+func Test_LoginErrors(t *testing.T) {
+	t.Parallel()
+
+	_, server := createMock(t)
+	defer server.Close()
+
+	p := createProvider(t, server, Config{})
+
+	code := login(t, p, "state", "nonce", "verifier", DefaultMockEmail)
+	if _, err := p.Exchange(code, "nonce", "another verifier", time.Now()); err == nil {
+		t.Error("Exchange() expected an error with the wrong verifier got nil")
+	}
+
+	code = login(t, p, "state", "nonce", "verifier", DefaultMockEmail)
+	if _, err := p.Exchange(code, "another nonce", "verifier", time.Now()); err == nil {
+		t.Error("Exchange() expected an error with the wrong nonce got nil")
+	}
+
+	code = login(t, p, "state", "nonce", "verifier", DefaultMockEmail)
+	if _, err := p.Exchange(code, "nonce", "verifier", time.Now().Add(time.Hour)); err == nil {
+		t.Error("Exchange() expected an error with an expired token got nil")
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+
+	mock, server := createMock(t)
+	defer server.Close()
+
+	p := createProvider(t, server, Config{})
+
+	now := time.Now()
+
+	valid := func() Claims {
+		return Claims{
+			"iss":   server.URL,
+			"aud":   testClientID,
+			"sub":   "1234",
+			"nonce": "nonce",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute).Unix(),
+		}
+	}
+
+	type tcase struct {
+		name   string
+		modify func(Claims)
+		err    bool
+	}
+
+	tests := []tcase{
+		{"valid", func(Claims) {}, false},
+		{"audience list", func(c Claims) { c["aud"] = []string{"other", testClientID}; c["azp"] = testClientID }, false},
+		{"issuer", func(c Claims) { c["iss"] = "https://evil.example.com" }, true},
+		{"audience", func(c Claims) { c["aud"] = "other" }, true},
+		{"authorized party", func(c Claims) { c["aud"] = []string{"other", testClientID}; c["azp"] = "other" }, true},
+		{"expired", func(c Claims) { c["exp"] = now.Add(-time.Hour).Unix() }, true},
+		{"missing expiry", func(c Claims) { delete(c, "exp") }, true},
+		{"future", func(c Claims) { c["iat"] = now.Add(time.Hour).Unix() }, true},
+		{"nonce", func(c Claims) { c["nonce"] = "other" }, true},
+		{"subject", func(c Claims) { delete(c, "sub") }, true},
+	}
+
+	for _, tc := range tests {
+		claims := valid()
+		tc.modify(claims)
+
+		token, err := mock.Sign(claims)
+		if err != nil {
+			t.Fatalf("Sign() expected nil got %q", err)
+		}
+
+		_, err = p.Verify(token, "nonce", now)
+		if tc.err && err == nil {
+			t.Errorf("%s: Verify() expected an error got nil", tc.name)
+		} else if !tc.err && err != nil {
+			t.Errorf("%s: Verify() expected nil got %q", tc.name, err)
+		}
+	}
+
+	token, err := mock.Sign(valid())
+	if err != nil {
+		t.Fatalf("Sign() expected nil got %q", err)
+	}
+
+	parts := strings.Split(token, ".")
+
+	forged := valid()
+	forged["sub"] = "admin"
+	other, _ := mock.Sign(forged)
+
+	tampered := []string{
+		parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2],
+		parts[0] + "." + parts[1],
+		"eyJhbGciOiJub25lIn0." + parts[1] + ".",
+		parts[0] + "." + parts[1] + ".AAAA",
+	}
+
+	for i, tok := range tampered {
+		if _, err := p.Verify(tok, "nonce", now); err == nil {
+			t.Errorf("%d: Verify() expected an error for a tampered token got nil", i)
+		}
+	}
+}
+
+func Test_Email(t *testing.T) {
+	t.Parallel()
+
+	_, server := createMock(t)
+	defer server.Close()
+
+	type tcase struct {
+		config   Config
+		claims   Claims
+		expected string
+	}
+
+	tests := []tcase{
+		{Config{}, Claims{"email": " Jess@Example.com "}, "jess@example.com"},
+		{Config{RequireVerifiedEmail: true}, Claims{"email": "jess@example.com", "email_verified": true}, "jess@example.com"},
+		{Config{RequireVerifiedEmail: true}, Claims{"email": "jess@example.com", "email_verified": false}, ""},
+		{Config{RequireVerifiedEmail: true}, Claims{"email": "jess@example.com"}, ""},
+		{Config{EmailClaim: "upn"}, Claims{"email": "a@example.com", "upn": "b@example.com"}, "b@example.com"},
+		{Config{EmailClaim: "profile.mail"}, Claims{"profile": map[string]interface{}{"mail": "c@example.com"}}, "c@example.com"},
+		{Config{EmailClaim: "profile.mail"}, Claims{"profile": "c@example.com"}, ""},
+		{Config{}, Claims{"email": "not an email"}, ""},
+		{Config{AllowedDomains: []string{"Example.com"}}, Claims{"email": "jess@example.com"}, "jess@example.com"},
+		{Config{AllowedDomains: []string{"example.com"}}, Claims{"email": "jess@example.com.evil.com"}, ""},
+		{Config{AllowedDomains: []string{"example.com"}}, Claims{"email": "jess@sub.example.com"}, ""},
+	}
+
+	for i, tc := range tests {
+		p := createProvider(t, server, tc.config)
+
+		email, err := p.Email(tc.claims)
+		if len(tc.expected) == 0 {
+			if err == nil {
+				t.Errorf("%d: Email() expected an error got %q", i, email)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: Email() expected nil got %q", i, err)
+			continue
+		}
+
+		if email != tc.expected {
+			t.Errorf("%d: Email() expected %q got %q", i, tc.expected, email)
+		}
+	}
+}
+
+func Test_NewProviderIssuerMismatch(t *testing.T) {
+	t.Parallel()
+
+	mock, server := createMock(t)
+	defer server.Close()
+
+	mock.Issuer = "https://evil.example.com"
+
+	_, err := NewProvider(server.Client(), Config{
+		Issuer:      server.URL,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	if err == nil {
+		t.Fatal("NewProvider() expected an error got nil")
+	}
+}
@@ -0,0 +1,307 @@
+// Package org groups users in to organisations which own domains, each
+// member has a Role limiting what they can do.
+package org
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+// Role is what a member can do in an organisation, each role can do
+// everything the roles below it can
+type Role = string
+
+const (
+	// manage the organisation itself, there is only one owner
+	RoleOwner Role = "owner"
+	// manage members, invitations and delete domains
+	RoleAdmin Role = "admin"
+	// add and change domains, lists, channels and rules
+	RoleEditor Role = "editor"
+	// read only
+	RoleViewer Role = "viewer"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole returns true if the role is known
+func ValidRole(role Role) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// Allows returns true if a member with the role can do what needs the
+// required role
+func Allows(role, required Role) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[required]
+}
+
+// maximum length of an organisation's name
+const maxNameLength = 128
+
+// Organisation owns domains on behalf of its members. Its domains use the
+// lists, channels, rules, webhooks and plan of its owner's account
+type Organisation struct {
+	ID int `pg:",pk" json:"id"`
+
+	Name string `pg:",notnull" json:"name"`
+
+	OwnerID int       `pg:",notnull" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	// members must enable two factor authentication to use the
+	// organisation
+	RequireTwoFactor bool `pg:",notnull,use_zero" json:"require_two_factor"`
+
+	AddedAt   time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+	DeletedAt time.Time `pg:",soft_delete" json:"-"`
+}
+
+// Admits returns false if the organisation requires two factor
+// authentication and the user has not enabled it
+func (o Organisation) Admits(u user.User) bool {
+	return !o.RequireTwoFactor || u.TwoFactorEnabled()
+}
+
+// NormaliseName trims the name, returning an error if it is empty or too
+// long
+func NormaliseName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if len(name) == 0 || len(name) > maxNameLength {
+		return "", errors.Errorf("name must be between 1 and %d characters", maxNameLength)
+	}
+
+	return name, nil
+}
+
+// NewOrganisation creates an organisation owned by the user
+func NewOrganisation(name string, owner user.User) (Organisation, error) {
+	name, err := NormaliseName(name)
+	if err != nil {
+		return Organisation{}, err
+	}
+
+	return Organisation{
+		Name:    name,
+		OwnerID: owner.ID,
+		Owner:   owner,
+	}, nil
+}
+
+// Insert the organisation and its owner as a member
+func (o *Organisation) Insert(db orm.DB) error {
+	if _, err := db.Model(o).Returning("*").Insert(); err != nil {
+		return errors.WithMessage(err, "Insert organisation")
+	}
+
+	m := Member{
+		OrganisationID: o.ID,
+		UserID:         o.OwnerID,
+		Role:           RoleOwner,
+	}
+
+	if _, err := db.Model(&m).Insert(); err != nil {
+		return errors.WithMessage(err, "Insert owner")
+	}
+
+	return nil
+}
+
+// Member is a user's membership of an organisation
+type Member struct {
+	ID int `pg:",pk" json:"id"`
+
+	OrganisationID int          `pg:",notnull,unique:member_organisation_id_user_id" json:"organisation_id"`
+	Organisation   Organisation `pg:"fk:organisation_id,rel:has-one" json:"organisation"`
+
+	UserID int       `pg:",notnull,unique:member_organisation_id_user_id" json:"user_id"`
+	User   user.User `pg:"fk:user_id,rel:has-one" json:"-"`
+
+	Role Role `pg:",notnull,type:text" json:"role"`
+
+	AddedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+}
+
+// GetMember returns the user's membership of the organisation
+func GetMember(db orm.DB, organisationID, userID int) (Member, error) {
+	var m Member
+	err := db.Model(&m).
+		Relation("Organisation").
+		Where("member.organisation_id = ? AND member.user_id = ?", organisationID, userID).
+		Where("organisation.deleted_at IS NULL").
+		Select()
+	return m, err
+}
+
+// GetMemberships returns the organisations the user is a member of
+func GetMemberships(db orm.DB, userID int) ([]Member, error) {
+	members := make([]Member, 0)
+	err := db.Model(&members).
+		Relation("Organisation").
+		Where("member.user_id = ?", userID).
+		Where("organisation.deleted_at IS NULL").
+		Order("organisation.name", "member.id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// TwoFactorRequired returns true if any of the user's organisations
+// require two factor authentication
+func TwoFactorRequired(db orm.DB, userID int) (bool, error) {
+	return db.Model((*Member)(nil)).
+		Relation("Organisation").
+		Where("member.user_id = ?", userID).
+		Where("organisation.deleted_at IS NULL AND organisation.require_two_factor").
+		Exists()
+}
+
+// GetMembers returns the organisation's members with their users
+func GetMembers(db orm.DB, organisationID int) ([]Member, error) {
+	members := make([]Member, 0)
+	err := db.Model(&members).
+		Relation("User").
+		Where("member.organisation_id = ?", organisationID).
+		Order("member.id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// how long an invitation can be accepted for
+const invitationTTL = time.Hour * 24 * 7
+
+// Invitation asks someone to join an organisation, it is accepted by
+// the user with the same email
+type Invitation struct {
+	ID int `pg:",pk" json:"id"`
+
+	OrganisationID int          `pg:",notnull,unique:invitation_organisation_id_email" json:"organisation_id"`
+	Organisation   Organisation `pg:"fk:organisation_id,rel:has-one" json:"-"`
+
+	Email string `pg:",notnull,unique:invitation_organisation_id_email" json:"email"`
+	Role  Role   `pg:",notnull,type:text" json:"role"`
+
+	// used in the emailed link
+	Code string `pg:",notnull,unique" json:"-"`
+
+	InvitedByID int `pg:",notnull" json:"invited_by_id"`
+
+	CreatedAt  time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+	ExpiresAt  time.Time `pg:",type:timestamptz,notnull" json:"expires_at"`
+	AcceptedAt time.Time `pg:",type:timestamptz" json:"accepted_at"`
+}
+
+// NewInvitation invites the email to the organisation with the role, the
+// owner role can not be given out
+func NewInvitation(organisationID int, email string, role Role, invitedBy user.User, now time.Time) (Invitation, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if !strings.Contains(email, "@") {
+		return Invitation{}, errors.Errorf("invalid email %q", email)
+	}
+
+	if !ValidRole(role) || role == RoleOwner {
+		return Invitation{}, errors.Errorf("invalid role %q", role)
+	}
+
+	return Invitation{
+		OrganisationID: organisationID,
+		Email:          email,
+		Role:           role,
+		Code:           uniuri.NewLen(32),
+		InvitedByID:    invitedBy.ID,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(invitationTTL),
+	}, nil
+}
+
+// Insert the invitation, replacing any earlier invitation for the email
+func (i *Invitation) Insert(db orm.DB) error {
+	_, err := db.Model(i).
+		OnConflict("(organisation_id, email) DO UPDATE").
+		Set("role = EXCLUDED.role, code = EXCLUDED.code, invited_by_id = EXCLUDED.invited_by_id, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at, accepted_at = NULL").
+		Returning("*").
+		Insert()
+	return err
+}
+
+// Usable returns an error if the invitation can not be accepted by the
+// user at now
+func (i Invitation) Usable(u user.User, now time.Time) error {
+	if !i.AcceptedAt.IsZero() {
+		return errors.New("invitation has already been accepted")
+	}
+
+	if now.After(i.ExpiresAt) {
+		return errors.New("invitation has expired")
+	}
+
+	if !strings.EqualFold(i.Email, u.Email) {
+		return errors.New("invitation is for a different email")
+	}
+
+	return nil
+}
+
+// GetInvitation returns the invitation for the emailed code
+func GetInvitation(db orm.DB, code string) (Invitation, error) {
+	var i Invitation
+	err := db.Model(&i).Relation("Organisation").Where("invitation.code = ?", code).Select()
+	return i, err
+}
+
+// Accept adds the user to the organisation with the invitation's role,
+// an existing member keeps the higher of their roles
+func (i *Invitation) Accept(db orm.DB, u user.User, now time.Time) error {
+	if err := i.Usable(u, now); err != nil {
+		return err
+	}
+
+	m := Member{
+		OrganisationID: i.OrganisationID,
+		UserID:         u.ID,
+		Role:           i.Role,
+	}
+
+	existing, err := GetMember(db, i.OrganisationID, u.ID)
+	switch {
+	case err == pg.ErrNoRows:
+		if _, err := db.Model(&m).Insert(); err != nil {
+			return errors.WithMessage(err, "Insert member")
+		}
+
+	case err != nil:
+		return errors.WithMessage(err, "GetMember")
+
+	case !Allows(existing.Role, i.Role):
+		_, err = db.Model(&existing).Set("role = ?", i.Role).WherePK().Update()
+		if err != nil {
+			return errors.WithMessage(err, "Update member")
+		}
+	}
+
+	i.AcceptedAt = now
+
+	if _, err := db.Model(i).Set("accepted_at = ?accepted_at").WherePK().Update(); err != nil {
+		return errors.WithMessage(err, "Update invitation")
+	}
+
+	return nil
+}
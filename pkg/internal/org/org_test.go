@@ -0,0 +1,119 @@
+package org
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jawr/whois-bi/pkg/internal/user"
+)
+
+func Test_Allows(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		role, required Role
+		expected       bool
+	}
+
+	cases := []tcase{
+		tcase{RoleOwner, RoleAdmin, true},
+		tcase{RoleAdmin, RoleAdmin, true},
+		tcase{RoleAdmin, RoleOwner, false},
+		tcase{RoleEditor, RoleViewer, true},
+		tcase{RoleViewer, RoleEditor, false},
+		tcase{"", RoleViewer, false},
+		tcase{"root", RoleViewer, false},
+	}
+
+	for _, tc := range cases {
+		if got := Allows(tc.role, tc.required); got != tc.expected {
+			t.Errorf("Allows(%q, %q) expected %t got %t", tc.role, tc.required, tc.expected, got)
+		}
+	}
+}
+
+func Test_NewOrganisation(t *testing.T) {
+	t.Parallel()
+
+	o, err := NewOrganisation("  Acme ", user.User{ID: 3})
+	if err != nil {
+		t.Fatalf("NewOrganisation() expected nil got %q", err)
+	}
+
+	if o.Name != "Acme" || o.OwnerID != 3 {
+		t.Fatalf("unexpected organisation %+v", o)
+	}
+
+	if _, err := NewOrganisation(" ", user.User{ID: 3}); err == nil {
+		t.Fatal("NewOrganisation() expected an error got nil")
+	}
+}
+
+func Test_Invitation(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+	inviter := user.User{ID: 1}
+
+	if _, err := NewInvitation(1, "nope", RoleEditor, inviter, now); err == nil {
+		t.Error("NewInvitation() expected an error for an invalid email got nil")
+	}
+
+	if _, err := NewInvitation(1, "new@example.com", RoleOwner, inviter, now); err == nil {
+		t.Error("NewInvitation() expected an error for the owner role got nil")
+	}
+
+	i, err := NewInvitation(1, " New@Example.com", RoleEditor, inviter, now)
+	if err != nil {
+		t.Fatalf("NewInvitation() expected nil got %q", err)
+	}
+
+	if i.Email != "new@example.com" || len(i.Code) != 32 || !i.ExpiresAt.Equal(now.Add(invitationTTL)) {
+		t.Fatalf("unexpected invitation %+v", i)
+	}
+
+	invited := user.User{ID: 2, Email: "NEW@example.com"}
+
+	if err := i.Usable(invited, now.Add(time.Hour)); err != nil {
+		t.Errorf("Usable() expected nil got %q", err)
+	}
+
+	if err := i.Usable(user.User{ID: 3, Email: "other@example.com"}, now); err == nil {
+		t.Error("Usable() expected an error for a different email got nil")
+	}
+
+	if err := i.Usable(invited, now.Add(invitationTTL+time.Second)); err == nil {
+		t.Error("Usable() expected an error once expired got nil")
+	}
+
+	i.AcceptedAt = now
+	if err := i.Usable(invited, now); err == nil {
+		t.Error("Usable() expected an error once accepted got nil")
+	}
+}
+
+func Test_Admits(t *testing.T) {
+	t.Parallel()
+
+	enabled := user.User{TOTPEnabledAt: time.Now()}
+
+	type tcase struct {
+		require  bool
+		u        user.User
+		expected bool
+	}
+
+	cases := []tcase{
+		tcase{false, user.User{}, true},
+		tcase{false, enabled, true},
+		tcase{true, user.User{}, false},
+		tcase{true, enabled, true},
+	}
+
+	for i, tc := range cases {
+		o := Organisation{RequireTwoFactor: tc.require}
+		if got := o.Admits(tc.u); got != tc.expected {
+			t.Errorf("%d: Admits() expected %t got %t", i, tc.expected, got)
+		}
+	}
+}
@@ -3,9 +3,12 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"log"
 )
 
-type ConsumerHandler func(ctx context.Context, b []byte)
+// ConsumerHandler handles a raw message, returning an error if the message
+// could not be handled so that it can be rejected
+type ConsumerHandler func(ctx context.Context, b []byte) error
 
 // Consumer to a queue and consume raw messages from it
 type Consumer interface {
@@ -29,7 +32,9 @@ func (m *MemoryConsumer) Run(ctx context.Context, handler ConsumerHandler) error
 		case <-ctx.Done():
 			return ctx.Err()
 		case b := <-m.queue:
-			handler(ctx, b)
+			if err := handler(ctx, b); err != nil {
+				log.Printf("Error handling message: %s", err)
+			}
 		}
 	}
 
@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// PriorityConsumer consumes from multiple Consumers, handling one message
+// at a time and always preferring messages from earlier Consumers
+type PriorityConsumer struct {
+	consumers []Consumer
+}
+
+// NewPriorityConsumer creates a PriorityConsumer, consumers should be
+// provided from highest to lowest priority
+func NewPriorityConsumer(consumers ...Consumer) *PriorityConsumer {
+	return &PriorityConsumer{
+		consumers: consumers,
+	}
+}
+
+type delivery struct {
+	body   []byte
+	result chan error
+}
+
+// Run all consumers, passing their messages to handler in priority order
+func (p *PriorityConsumer) Run(ctx context.Context, handler ConsumerHandler) error {
+	wg, ctx := errgroup.WithContext(ctx)
+
+	deliveries := make([]chan delivery, len(p.consumers))
+
+	for idx := range p.consumers {
+		c := p.consumers[idx]
+		ch := make(chan delivery)
+		deliveries[idx] = ch
+
+		// each consumer blocks until its message has been handled so
+		// that it is not acknowledged early
+		wg.Go(func() error {
+			return c.Run(ctx, func(ctx context.Context, b []byte) error {
+				d := delivery{
+					body:   b,
+					result: make(chan error, 1),
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case ch <- d:
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case err := <-d.result:
+					return err
+				}
+			})
+		})
+	}
+
+	wg.Go(func() error {
+		for {
+			d, ok := next(ctx, deliveries)
+			if !ok {
+				return ctx.Err()
+			}
+
+			d.result <- handler(ctx, d.body)
+		}
+	})
+
+	return wg.Wait()
+}
+
+// next returns the highest priority delivery available, blocking until one
+// arrives or the context is cancelled
+func next(ctx context.Context, deliveries []chan delivery) (delivery, bool) {
+	for _, ch := range deliveries {
+		select {
+		case d := <-ch:
+			return d, true
+		default:
+		}
+	}
+
+	cases := make([]reflect.SelectCase, 0, len(deliveries)+1)
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	})
+
+	for _, ch := range deliveries {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ch),
+		})
+	}
+
+	chosen, value, _ := reflect.Select(cases)
+	if chosen == 0 {
+		return delivery{}, false
+	}
+
+	return value.Interface().(delivery), true
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func Test_PriorityConsumer(t *testing.T) {
+	t.Parallel()
+
+	high := NewMemoryConsumer()
+	normal := NewMemoryConsumer()
+
+	consumer := NewPriorityConsumer(high, normal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var lock sync.Mutex
+	handled := make([]string, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	handler := func(ctx context.Context, b []byte) error {
+		var msg string
+		if err := json.Unmarshal(b, &msg); err != nil {
+			return err
+		}
+
+		// block on the first message so the others queue up
+		if msg == "first" {
+			close(started)
+			<-release
+		}
+
+		lock.Lock()
+		handled = append(handled, msg)
+		if len(handled) == 3 {
+			close(done)
+		}
+		lock.Unlock()
+
+		return nil
+	}
+
+	var wg errgroup.Group
+
+	wg.Go(func() error {
+		return consumer.Run(ctx, handler)
+	})
+
+	if err := normal.Publish("first"); err != nil {
+		t.Fatalf("Publish() expected nil got %q", err)
+	}
+
+	<-started
+
+	// the normal consumer is busy with the first message so this will
+	// block until it has been handled
+	wg.Go(func() error {
+		return normal.Publish("normal")
+	})
+
+	if err := high.Publish("high"); err != nil {
+		t.Fatalf("Publish() expected nil got %q", err)
+	}
+
+	// give the consumers time to offer their messages
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected all messages to be handled")
+	}
+
+	expected := []string{"first", "high", "normal"}
+	for idx := range expected {
+		if handled[idx] != expected[idx] {
+			t.Fatalf("expected %v got %v", expected, handled)
+		}
+	}
+
+	cancel()
+
+	if err := wg.Wait(); err != context.Canceled {
+		t.Fatalf("Wait() expected Canceled, got: %s", err)
+	}
+}
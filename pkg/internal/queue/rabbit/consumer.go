@@ -64,13 +64,31 @@ func (c *Consumer) Run(ctx context.Context, handler queue.ConsumerHandler) error
 }
 
 func (c *Consumer) Declare(ctx context.Context, ch *amqp.Channel) error {
+	// rejected messages are routed to the dead letter queue
+	deadLetter := c.queue + ".dead"
+
 	_, err := ch.QueueDeclare(
+		deadLetter, // name
+		true,       // durable
+		false,      // delete when unused
+		false,      // exclusive
+		false,      // no-wait
+		nil,        // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = ch.QueueDeclare(
 		c.queue, // name
 		true,    // durable
 		false,   // delete when unused
 		false,   // exclusive
 		false,   // no-wait
-		nil,     // arguments
+		amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": deadLetter,
+		},
 	)
 	if err != nil {
 		return err
@@ -80,6 +98,12 @@ func (c *Consumer) Declare(ctx context.Context, ch *amqp.Channel) error {
 }
 
 func (c *Consumer) Consume(ctx context.Context, ch *amqp.Channel) error {
+	// only take one unacknowledged message at a time so that messages are
+	// spread between consumers and priorities are respected
+	if err := ch.Qos(1, 0, false); err != nil {
+		return err
+	}
+
 	msgs, err := ch.Consume(
 		c.queue, // queue
 		c.name,  // consumer name
@@ -105,10 +129,19 @@ func (c *Consumer) Consume(ctx context.Context, ch *amqp.Channel) error {
 				return amqp.ErrClosed
 			}
 
-			c.handler(ctx, msg.Body)
+			if err := c.handler(ctx, msg.Body); err != nil {
+				log.Printf("Error handling message from %s, rejecting: %s", c.queue, err)
 
-			// do we care about errors here
-			msg.Ack(false)
+				// reject without requeue so it is dead lettered
+				if err := msg.Nack(false, false); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := msg.Ack(false); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -0,0 +1,261 @@
+// Package schedule parses scan schedules, which can either be a fixed
+// interval ("24h", "@every 5m", "@daily") or a standard five field cron
+// expression ("*/5 * * * *").
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Schedule returns the next time something should run after t
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Interval runs every Every after the previous run
+type Interval struct {
+	Every time.Duration
+}
+
+// Next returns t + Every
+func (i Interval) Next(t time.Time) time.Time {
+	return t.Add(i.Every)
+}
+
+var descriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// Parse a schedule spec
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if len(spec) == 0 {
+		return nil, errors.New("empty schedule")
+	}
+
+	if cron, ok := descriptors[spec]; ok {
+		spec = cron
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		spec = strings.TrimSpace(strings.TrimPrefix(spec, "@every "))
+	}
+
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, errors.Errorf("invalid interval %q", spec)
+		}
+		return Interval{Every: d}, nil
+	}
+
+	return parseCron(spec)
+}
+
+// MinInterval returns the smallest gap between consecutive runs of the
+// schedule found within the next year from t
+func MinInterval(s Schedule, t time.Time) time.Duration {
+	if i, ok := s.(Interval); ok {
+		return i.Every
+	}
+
+	var min time.Duration
+
+	end := t.AddDate(1, 0, 0)
+	prev := s.Next(t)
+
+	// bounded so a pathological cron expression can't spin forever
+	for i := 0; i < 10000 && !prev.IsZero() && prev.Before(end); i++ {
+		next := s.Next(prev)
+		if next.IsZero() {
+			break
+		}
+
+		if gap := next.Sub(prev); min == 0 || gap < min {
+			min = gap
+		}
+
+		prev = next
+	}
+
+	return min
+}
+
+// Validate checks that the spec parses and runs no more often than min
+func Validate(spec string, min time.Duration) error {
+	s, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	if got := MinInterval(s, time.Now()); got < min {
+		return errors.Errorf("schedule %q runs every %s, minimum is %s", spec, got, min)
+	}
+
+	return nil
+}
+
+// Cron is a parsed five field cron expression, each field holds a bit
+// set of the values that match
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// true if the day of month or day of week field was a wildcard
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteBounds = bounds{"minute", 0, 59}
+	hourBounds   = bounds{"hour", 0, 23}
+	domBounds    = bounds{"day of month", 1, 31}
+	monthBounds  = bounds{"month", 1, 12}
+	dowBounds    = bounds{"day of week", 0, 7}
+)
+
+func parseCron(spec string) (Cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Cron{}, errors.Errorf("invalid schedule %q: expected a duration or 5 cron fields", spec)
+	}
+
+	var c Cron
+	var err error
+
+	if c.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return Cron{}, err
+	}
+	if c.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return Cron{}, err
+	}
+	if c.dom, err = parseField(fields[2], domBounds); err != nil {
+		return Cron{}, err
+	}
+	if c.month, err = parseField(fields[3], monthBounds); err != nil {
+		return Cron{}, err
+	}
+	if c.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return Cron{}, err
+	}
+
+	// sunday can be 0 or 7
+	if c.dow&(1<<7) > 0 {
+		c.dow |= 1
+	}
+
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return c, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		stepped := false
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid %s step %q", b.name, part)
+			}
+			part = part[:idx]
+			stepped = true
+		}
+
+		start, end := b.min, b.max
+
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			rng := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(rng[0])
+			end, err2 = strconv.Atoi(rng[1])
+			if err1 != nil || err2 != nil {
+				return 0, errors.Errorf("invalid %s range %q", b.name, part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errors.Errorf("invalid %s value %q", b.name, part)
+			}
+			start = v
+			// a single value with a step runs from the value to the max
+			if !stepped {
+				end = v
+			}
+		}
+
+		if start < b.min || end > b.max || start > end {
+			return 0, errors.Errorf("%s %q out of range %d-%d", b.name, part, b.min, b.max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (c Cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) > 0
+	dow := c.dow&(1<<uint(t.Weekday())) > 0
+
+	// standard cron behaviour, if both are restricted either can match
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the next matching minute strictly after t, in t's
+// location. Returns the zero time if nothing matches within five years.
+func (c Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
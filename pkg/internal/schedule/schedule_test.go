@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, spec string) Schedule {
+	t.Helper()
+
+	s, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q) expected nil got %q", spec, err)
+	}
+	return s
+}
+
+func Test_ParseInterval(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		spec     string
+		expected time.Duration
+	}
+
+	cases := []tcase{
+		tcase{"24h", time.Hour * 24},
+		tcase{"5m", time.Minute * 5},
+		tcase{"@every 1h30m", time.Minute * 90},
+	}
+
+	for _, tc := range cases {
+		s := mustParse(t, tc.spec)
+
+		i, ok := s.(Interval)
+		if !ok {
+			t.Fatalf("Parse(%q) expected an Interval", tc.spec)
+		}
+
+		if i.Every != tc.expected {
+			t.Errorf("Parse(%q) expected %s got %s", tc.spec, tc.expected, i.Every)
+		}
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	t.Parallel()
+
+	specs := []string{
+		"",
+		"0s",
+		"-5m",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected an error", spec)
+		}
+	}
+}
+
+func Test_CronNext(t *testing.T) {
+	t.Parallel()
+
+	// a monday
+	from := time.Date(2021, time.March, 1, 10, 7, 30, 0, time.UTC)
+
+	type tcase struct {
+		spec     string
+		expected time.Time
+	}
+
+	cases := []tcase{
+		tcase{"*/5 * * * *", time.Date(2021, time.March, 1, 10, 10, 0, 0, time.UTC)},
+		tcase{"0 * * * *", time.Date(2021, time.March, 1, 11, 0, 0, 0, time.UTC)},
+		tcase{"@daily", time.Date(2021, time.March, 2, 0, 0, 0, 0, time.UTC)},
+		tcase{"30 9 * * 1-5", time.Date(2021, time.March, 2, 9, 30, 0, 0, time.UTC)},
+		tcase{"0 0 * * 0", time.Date(2021, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		tcase{"0 0 * * 7", time.Date(2021, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		tcase{"0 12 1 * *", time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)},
+		tcase{"0 9 1 * *", time.Date(2021, time.April, 1, 9, 0, 0, 0, time.UTC)},
+		tcase{"15,45 10 * * *", time.Date(2021, time.March, 1, 10, 15, 0, 0, time.UTC)},
+		tcase{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		s := mustParse(t, tc.spec)
+
+		got := s.Next(from)
+		if !got.Equal(tc.expected) {
+			t.Errorf("Next(%q) expected %s got %s", tc.spec, tc.expected, got)
+		}
+	}
+}
+
+func Test_MinInterval(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2021, time.March, 1, 10, 7, 30, 0, time.UTC)
+
+	type tcase struct {
+		spec     string
+		expected time.Duration
+	}
+
+	cases := []tcase{
+		tcase{"5m", time.Minute * 5},
+		tcase{"*/5 * * * *", time.Minute * 5},
+		tcase{"0,10 * * * *", time.Minute * 10},
+		tcase{"@hourly", time.Hour},
+		tcase{"@weekly", time.Hour * 24 * 7},
+	}
+
+	for _, tc := range cases {
+		s := mustParse(t, tc.spec)
+
+		if got := MinInterval(s, from); got != tc.expected {
+			t.Errorf("MinInterval(%q) expected %s got %s", tc.spec, tc.expected, got)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+
+	if err := Validate("*/5 * * * *", time.Minute*5); err != nil {
+		t.Errorf("Validate() expected nil got %q", err)
+	}
+
+	if err := Validate("* * * * *", time.Minute*5); err == nil {
+		t.Error("Validate() expected an error got nil")
+	}
+
+	if err := Validate("1m", time.Hour); err == nil {
+		t.Error("Validate() expected an error got nil")
+	}
+}
@@ -0,0 +1,96 @@
+package user
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// send a batch once it has this many alerts
+	DefaultAlertMinDomains = 5
+	// or once its oldest alert is this many minutes old
+	DefaultAlertMaxDelay = 60
+)
+
+// MaxDelay returns how long alerts can wait to be batched
+func (u User) MaxDelay() time.Duration {
+	return time.Duration(u.AlertMaxDelay) * time.Minute
+}
+
+// InQuietHours returns true if now is within the user's quiet hours in
+// their timezone. Quiet hours can wrap around midnight.
+func (u User) InQuietHours(now time.Time) bool {
+	if len(u.QuietHoursStart) == 0 || len(u.QuietHoursEnd) == 0 {
+		return false
+	}
+
+	startHour, startMinute, err := parseClock(u.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+
+	endHour, endMinute, err := parseClock(u.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(u.Location())
+
+	current := local.Hour()*60 + local.Minute()
+	start := startHour*60 + startMinute
+	end := endHour*60 + endMinute
+
+	switch {
+	case start == end:
+		return false
+	case start < end:
+		return current >= start && current < end
+	default:
+		return current >= start || current < end
+	}
+}
+
+// AlertsDue returns true if a batch of count alerts, the oldest created at
+// oldest, should be sent now
+func (u User) AlertsDue(count int, oldest, now time.Time) bool {
+	if count == 0 || u.InQuietHours(now) {
+		return false
+	}
+
+	if count >= u.AlertMinDomains {
+		return true
+	}
+
+	return now.Sub(oldest) > u.MaxDelay()
+}
+
+// ValidateAlertSettings checks batching settings are valid, quiet hours
+// are either both empty or both in the form 15:04
+func ValidateAlertSettings(minDomains, maxDelay int, quietStart, quietEnd string) error {
+	if minDomains < 1 {
+		return errors.New("minimum domains must be at least 1")
+	}
+
+	if maxDelay < 0 {
+		return errors.New("maximum delay can not be negative")
+	}
+
+	if (len(quietStart) == 0) != (len(quietEnd) == 0) {
+		return errors.New("quiet hours need a start and an end")
+	}
+
+	if len(quietStart) == 0 {
+		return nil
+	}
+
+	if _, _, err := parseClock(quietStart); err != nil {
+		return err
+	}
+
+	if _, _, err := parseClock(quietEnd); err != nil {
+		return err
+	}
+
+	return nil
+}
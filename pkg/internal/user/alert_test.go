@@ -0,0 +1,72 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ValidateAlertSettings(t *testing.T) {
+	tests := []struct {
+		minDomains int
+		maxDelay   int
+		start, end string
+		valid      bool
+	}{
+		{5, 60, "", "", true},
+		{1, 0, "22:00", "07:00", true},
+		{0, 60, "", "", false},
+		{5, -1, "", "", false},
+		{5, 60, "22:00", "", false},
+		{5, 60, "22:00", "7am", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAlertSettings(tt.minDomains, tt.maxDelay, tt.start, tt.end)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateAlertSettings(%d, %d, %q, %q) expected valid %t got %v", tt.minDomains, tt.maxDelay, tt.start, tt.end, tt.valid, err)
+		}
+	}
+}
+
+func Test_AlertsDue(t *testing.T) {
+	now := time.Date(2020, 7, 1, 23, 30, 0, 0, time.UTC)
+
+	u := User{AlertMinDomains: 3, AlertMaxDelay: 30}
+
+	tests := []struct {
+		name   string
+		user   User
+		count  int
+		oldest time.Time
+		due    bool
+	}{
+		{"empty", u, 0, now, false},
+		{"below minimum", u, 2, now.Add(time.Minute * -10), false},
+		{"minimum reached", u, 3, now, true},
+		{"too old", u, 1, now.Add(time.Minute * -31), true},
+		{
+			name:  "quiet hours wrapping midnight",
+			user:  User{AlertMinDomains: 1, QuietHoursStart: "22:00", QuietHoursEnd: "07:00"},
+			count: 10,
+		},
+		{
+			name:  "outside quiet hours",
+			user:  User{AlertMinDomains: 1, QuietHoursStart: "09:00", QuietHoursEnd: "17:00"},
+			count: 1,
+			due:   true,
+		},
+		{
+			// 23:30 UTC is 09:30 in Sydney
+			name:  "quiet hours in timezone",
+			user:  User{AlertMinDomains: 1, Timezone: "Australia/Sydney", QuietHoursStart: "09:00", QuietHoursEnd: "17:00"},
+			count: 1,
+			due:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		if due := tt.user.AlertsDue(tt.count, tt.oldest, now); due != tt.due {
+			t.Errorf("%s: expected %t got %t", tt.name, tt.due, due)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+// AttemptKind is what an attempt was trying to do
+type AttemptKind = string
+
+const (
+	AttemptLogin     AttemptKind = "login"
+	AttemptTwoFactor AttemptKind = "two_factor"
+	AttemptRecover   AttemptKind = "recover"
+)
+
+const (
+	// failures an account can have before it is locked
+	accountFreeFailures = 5
+	// failures are forgotten after this long or a successful login
+	accountWindow = time.Hour * 24
+	// the longest an account can be locked for at a time
+	accountMaxLockout = time.Hour
+
+	// window used to limit attempts from an ip and recovery emails
+	attemptWindow = time.Minute * 15
+	// failed logins allowed from an ip in the window
+	ipMaxFailures = 30
+	// recoveries allowed from an ip in the window
+	ipMaxRecovers = 10
+	// recovery emails sent to an email in the window
+	emailMaxRecovers = 3
+)
+
+// LoginAttempt is an attempt to log in or recover an account, they are
+// kept as an audit trail and used to slow down guessing
+type LoginAttempt struct {
+	ID int `pg:",pk" json:"id"`
+
+	Kind AttemptKind `pg:",notnull,type:text" json:"kind"`
+
+	// as entered, an account does not need to exist
+	Email string `pg:",notnull,use_zero" json:"email"`
+	IP    string `pg:",notnull,use_zero" json:"ip"`
+
+	Success bool `pg:",notnull,use_zero" json:"success"`
+
+	CreatedAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+}
+
+// NormaliseEmail returns the email accounts are stored and looked up by
+// and attempts are recorded against
+func NormaliseEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RecordAttempt stores an attempt
+func RecordAttempt(db orm.DB, kind AttemptKind, email, ip string, success bool) error {
+	a := LoginAttempt{
+		Kind:    kind,
+		Email:   NormaliseEmail(email),
+		IP:      ip,
+		Success: success,
+	}
+	_, err := db.Model(&a).Insert()
+	return err
+}
+
+// accountLockout returns how long an account is locked for after
+// failures in a row, doubling with each failure past the free ones
+func accountLockout(failures int) time.Duration {
+	if failures < accountFreeFailures {
+		return 0
+	}
+
+	lockout := time.Minute
+	for i := accountFreeFailures; i < failures; i++ {
+		lockout *= 2
+		if lockout >= accountMaxLockout {
+			return accountMaxLockout
+		}
+	}
+
+	return lockout
+}
+
+// remaining returns how long is left of a wait that started at
+func remaining(at time.Time, wait time.Duration, now time.Time) time.Duration {
+	if left := at.Add(wait).Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// LoginDelay returns how long until the email can try to log in from the
+// ip again, 0 if it can try now. It does not matter if an account exists
+// for the email so the response does not give that away
+func LoginDelay(db orm.DB, email, ip string, now time.Time) (time.Duration, error) {
+	var ipFailures int
+	var ipFirst time.Time
+	err := db.Model((*LoginAttempt)(nil)).
+		ColumnExpr("count(*), min(created_at)").
+		Where("ip = ? AND NOT success AND created_at > ?", ip, now.Add(-attemptWindow)).
+		Where("kind IN (?, ?)", AttemptLogin, AttemptTwoFactor).
+		Select(&ipFailures, &ipFirst)
+	if err != nil {
+		return 0, errors.Wrap(err, "Select ip failures")
+	}
+
+	if ipFailures >= ipMaxFailures {
+		return remaining(ipFirst, attemptWindow, now), nil
+	}
+
+	email = NormaliseEmail(email)
+
+	// failures since the last successful login
+	since := db.Model((*LoginAttempt)(nil)).
+		ColumnExpr("max(created_at)").
+		Where("email = ? AND success AND kind = ?", email, AttemptLogin)
+
+	var failures int
+	var last time.Time
+	err = db.Model((*LoginAttempt)(nil)).
+		ColumnExpr("count(*), max(created_at)").
+		Where("email = ? AND NOT success AND created_at > ?", email, now.Add(-accountWindow)).
+		Where("kind IN (?, ?)", AttemptLogin, AttemptTwoFactor).
+		Where("created_at > coalesce((?), '-infinity')", since).
+		Select(&failures, &last)
+	if err != nil {
+		return 0, errors.Wrap(err, "Select account failures")
+	}
+
+	return remaining(last, accountLockout(failures), now), nil
+}
+
+// RecoverDelay returns how long until the ip can start another recovery
+// and whether another recovery email can be sent to the email
+func RecoverDelay(db orm.DB, email, ip string, now time.Time) (time.Duration, bool, error) {
+	var ipRecovers int
+	var ipFirst time.Time
+	err := db.Model((*LoginAttempt)(nil)).
+		ColumnExpr("count(*), min(created_at)").
+		Where("ip = ? AND kind = ? AND created_at > ?", ip, AttemptRecover, now.Add(-attemptWindow)).
+		Select(&ipRecovers, &ipFirst)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "Select ip recovers")
+	}
+
+	if ipRecovers >= ipMaxRecovers {
+		return remaining(ipFirst, attemptWindow, now), false, nil
+	}
+
+	sent, err := db.Model((*LoginAttempt)(nil)).
+		Where("email = ? AND kind = ? AND success AND created_at > ?", NormaliseEmail(email), AttemptRecover, now.Add(-attemptWindow)).
+		Count()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "Count email recovers")
+	}
+
+	return 0, sent < emailMaxRecovers, nil
+}
+
+// GetAttempts returns the most recent attempts for the email
+func GetAttempts(db orm.DB, email string, limit int) ([]LoginAttempt, error) {
+	attempts := make([]LoginAttempt, 0)
+	err := db.Model(&attempts).
+		Where("email = ?", NormaliseEmail(email)).
+		Order("created_at DESC", "id DESC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
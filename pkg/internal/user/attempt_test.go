@@ -0,0 +1,67 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_AccountLockout(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		failures int
+		expected time.Duration
+	}
+
+	tests := []tcase{
+		{0, 0},
+		{accountFreeFailures - 1, 0},
+		{accountFreeFailures, time.Minute},
+		{accountFreeFailures + 1, time.Minute * 2},
+		{accountFreeFailures + 2, time.Minute * 4},
+		{accountFreeFailures + 5, time.Minute * 32},
+		{accountFreeFailures + 6, accountMaxLockout},
+		{accountFreeFailures + 1000, accountMaxLockout},
+	}
+
+	for _, tc := range tests {
+		if got := accountLockout(tc.failures); got != tc.expected {
+			t.Errorf("accountLockout(%d) expected %s got %s", tc.failures, tc.expected, got)
+		}
+	}
+}
+
+func Test_Remaining(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type tcase struct {
+		at       time.Time
+		wait     time.Duration
+		expected time.Duration
+	}
+
+	tests := []tcase{
+		{now, time.Minute, time.Minute},
+		{now.Add(-time.Second * 30), time.Minute, time.Second * 30},
+		{now.Add(-time.Minute), time.Minute, 0},
+		{now.Add(-time.Hour), time.Minute, 0},
+		{time.Time{}, time.Minute, 0},
+		{now, 0, 0},
+	}
+
+	for i, tc := range tests {
+		if got := remaining(tc.at, tc.wait, now); got != tc.expected {
+			t.Errorf("%d: remaining() expected %s got %s", i, tc.expected, got)
+		}
+	}
+}
+
+func Test_NormaliseEmail(t *testing.T) {
+	t.Parallel()
+
+	if got := NormaliseEmail("  Jess@Example.COM "); got != "jess@example.com" {
+		t.Errorf("NormaliseEmail() expected %q got %q", "jess@example.com", got)
+	}
+}
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type DigestFrequency = string
+
+const (
+	DigestOff    DigestFrequency = ""
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// DefaultTimezone is used when a user has not chosen a timezone
+const DefaultTimezone = "UTC"
+
+// Location returns the user's timezone, falling back to UTC if it is
+// invalid
+func (u User) Location() *time.Location {
+	if loc, err := time.LoadLocation(u.Timezone); err == nil && len(u.Timezone) > 0 {
+		return loc
+	}
+	return time.UTC
+}
+
+// ValidateTimezone checks the timezone is a known IANA timezone
+func ValidateTimezone(tz string) error {
+	if len(tz) == 0 {
+		return errors.New("missing timezone")
+	}
+
+	if _, err := time.LoadLocation(tz); err != nil {
+		return errors.Errorf("unknown timezone %q", tz)
+	}
+
+	return nil
+}
+
+// parse a time of day in the form 15:04
+func parseClock(s string) (int, int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil {
+		return 0, 0, errors.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, errors.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	return hour, minute, nil
+}
+
+// ValidateDigest checks digest settings are valid
+func ValidateDigest(frequency DigestFrequency, at string, weekday int) error {
+	switch frequency {
+	case DigestOff:
+		return nil
+	case DigestDaily, DigestWeekly:
+	default:
+		return errors.Errorf("unknown frequency %q", frequency)
+	}
+
+	if _, _, err := parseClock(at); err != nil {
+		return err
+	}
+
+	if weekday < int(time.Sunday) || weekday > int(time.Saturday) {
+		return errors.Errorf("invalid weekday %d, expected 0 (Sunday) to 6", weekday)
+	}
+
+	return nil
+}
+
+// LastDigest returns the period covered by the most recent scheduled digest
+// at or before now, in the user's timezone
+func (u User) LastDigest(now time.Time) (time.Time, time.Time, bool) {
+	if u.DigestFrequency != DigestDaily && u.DigestFrequency != DigestWeekly {
+		return time.Time{}, time.Time{}, false
+	}
+
+	hour, minute, err := parseClock(u.DigestTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	local := now.In(u.Location())
+	end := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, local.Location())
+
+	days := 1
+	if u.DigestFrequency == DigestWeekly {
+		days = 7
+		// go back to the chosen weekday
+		end = end.AddDate(0, 0, -((int(end.Weekday()) - u.DigestWeekday + 7) % 7))
+	}
+
+	if end.After(local) {
+		end = end.AddDate(0, 0, -days)
+	}
+
+	return end.AddDate(0, 0, -days), end, true
+}
+
+// DigestDue returns the period of the digest that should be sent now, if
+// any
+func (u User) DigestDue(now time.Time) (time.Time, time.Time, bool) {
+	start, end, ok := u.LastDigest(now)
+	if !ok || !u.LastDigestAt.Before(end) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,97 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ValidateDigest(t *testing.T) {
+	tests := []struct {
+		frequency DigestFrequency
+		at        string
+		weekday   int
+		valid     bool
+	}{
+		{DigestOff, "", 0, true},
+		{DigestDaily, "08:00", 0, true},
+		{DigestWeekly, "23:59", 6, true},
+		{"hourly", "08:00", 0, false},
+		{DigestDaily, "24:00", 0, false},
+		{DigestDaily, "8am", 0, false},
+		{DigestWeekly, "08:00", 7, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateDigest(tt.frequency, tt.at, tt.weekday)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateDigest(%q, %q, %d) expected valid %t got %v", tt.frequency, tt.at, tt.weekday, tt.valid, err)
+		}
+	}
+}
+
+func Test_DigestDue(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	// 09:30 BST on Wednesday 2020-07-01 is 08:30 UTC
+	now := time.Date(2020, 7, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		user  User
+		start time.Time
+		end   time.Time
+		due   bool
+	}{
+		{
+			name: "off",
+			user: User{},
+		},
+		{
+			name:  "daily already passed today",
+			user:  User{Timezone: "Europe/London", DigestFrequency: DigestDaily, DigestTime: "09:00"},
+			start: time.Date(2020, 6, 30, 9, 0, 0, 0, london),
+			end:   time.Date(2020, 7, 1, 9, 0, 0, 0, london),
+			due:   true,
+		},
+		{
+			name:  "daily later today",
+			user:  User{Timezone: "Europe/London", DigestFrequency: DigestDaily, DigestTime: "10:00"},
+			start: time.Date(2020, 6, 29, 10, 0, 0, 0, london),
+			end:   time.Date(2020, 6, 30, 10, 0, 0, 0, london),
+			due:   true,
+		},
+		{
+			name: "daily already sent",
+			user: User{
+				Timezone:        "Europe/London",
+				DigestFrequency: DigestDaily,
+				DigestTime:      "09:00",
+				LastDigestAt:    time.Date(2020, 7, 1, 9, 0, 0, 0, london),
+			},
+		},
+		{
+			name:  "weekly on monday",
+			user:  User{DigestFrequency: DigestWeekly, DigestTime: "08:00", DigestWeekday: int(time.Monday)},
+			start: time.Date(2020, 6, 22, 8, 0, 0, 0, time.UTC),
+			end:   time.Date(2020, 6, 29, 8, 0, 0, 0, time.UTC),
+			due:   true,
+		},
+		{
+			name:  "weekly today",
+			user:  User{DigestFrequency: DigestWeekly, DigestTime: "08:00", DigestWeekday: int(time.Wednesday)},
+			start: time.Date(2020, 6, 24, 8, 0, 0, 0, time.UTC),
+			end:   time.Date(2020, 7, 1, 8, 0, 0, 0, time.UTC),
+			due:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		start, end, due := tt.user.DigestDue(now)
+		if due != tt.due || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s: expected %s - %s %t got %s - %s %t", tt.name, tt.start, tt.end, tt.due, start, end, due)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package user
+
+import "time"
+
+type Plan = string
+
+const (
+	PlanFree Plan = "free"
+	PlanPro  Plan = "pro"
+)
+
+// PlanLimits are the restrictions applied to a user's plan
+type PlanLimits struct {
+	// the most often a domain can be scanned
+	MinScanInterval time.Duration
+	// the most often a whois lookup can be performed on a domain
+	MinWhoisInterval time.Duration
+}
+
+var planLimits = map[Plan]PlanLimits{
+	PlanFree: {
+		MinScanInterval:  time.Hour,
+		MinWhoisInterval: time.Hour * 24,
+	},
+	PlanPro: {
+		MinScanInterval:  time.Minute * 5,
+		MinWhoisInterval: time.Hour,
+	},
+}
+
+// Limits returns the limits of the user's plan, unknown plans are
+// treated as the free plan
+func (u User) Limits() PlanLimits {
+	if limits, ok := planLimits[u.Plan]; ok {
+		return limits
+	}
+	return planLimits[PlanFree]
+}
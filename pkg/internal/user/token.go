@@ -0,0 +1,189 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+// TokenScope limits what a token can be used for
+type TokenScope = string
+
+const (
+	// read only requests
+	TokenScopeRead TokenScope = "read"
+	// any request
+	TokenScopeWrite TokenScope = "write"
+)
+
+// every token secret starts with this so they are easy to spot, for
+// example by secret scanners
+const tokenPrefix = "wbi_"
+
+// characters of the secret kept to identify a token
+const tokenPrefixLength = len(tokenPrefix) + 8
+
+// DefaultTokenTTL is how long a token lasts unless told otherwise,
+// MaxTokenTTL is the longest a token can last
+const (
+	DefaultTokenTTL = time.Hour * 24 * 90
+	MaxTokenTTL     = time.Hour * 24 * 365
+)
+
+// Token is an API token used with an Authorization: Bearer header. Only a
+// hash of the secret is stored. Organisation tokens can only act on
+// their organisation, personal tokens act as the user
+type Token struct {
+	ID int `pg:",pk" json:"id"`
+
+	UserID int  `pg:",notnull" json:"user_id"`
+	User   User `pg:"fk:user_id,rel:has-one" json:"-"`
+
+	// 0 for a personal token
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	Name  string     `pg:",notnull" json:"name"`
+	Scope TokenScope `pg:",notnull,type:text" json:"scope"`
+
+	// start of the secret shown to identify the token
+	Prefix string `pg:",notnull" json:"prefix"`
+	Hash   string `pg:",notnull,unique" json:"-"`
+
+	CreatedAt  time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+	ExpiresAt  time.Time `pg:",type:timestamptz,notnull" json:"expires_at"`
+	LastUsedAt time.Time `pg:",type:timestamptz" json:"last_used_at"`
+	RevokedAt  time.Time `pg:",type:timestamptz" json:"revoked_at"`
+}
+
+// ValidTokenScope returns true if the scope is known
+func ValidTokenScope(scope TokenScope) bool {
+	switch scope {
+	case TokenScopeRead, TokenScopeWrite:
+		return true
+	}
+	return false
+}
+
+// HashToken returns the hash stored for a token's secret
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewToken creates a token for the user and returns it with its secret,
+// which is not stored and can not be shown again. A ttl of 0 uses
+// DefaultTokenTTL
+func NewToken(userID, organisationID int, name string, scope TokenScope, ttl time.Duration, now time.Time) (Token, string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > 64 {
+		return Token{}, "", errors.New("name must be between 1 and 64 characters")
+	}
+
+	if !ValidTokenScope(scope) {
+		return Token{}, "", errors.Errorf("unknown scope %q", scope)
+	}
+
+	if ttl == 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	if ttl < 0 || ttl > MaxTokenTTL {
+		return Token{}, "", errors.Errorf("tokens can last at most %d days", MaxTokenTTL/(time.Hour*24))
+	}
+
+	secret := tokenPrefix + uniuri.NewLen(40)
+
+	t := Token{
+		UserID:         userID,
+		OrganisationID: organisationID,
+		Name:           name,
+		Scope:          scope,
+		Prefix:         secret[:tokenPrefixLength],
+		Hash:           HashToken(secret),
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+	}
+
+	return t, secret, nil
+}
+
+// Usable returns true if the token has not expired or been revoked
+func (t Token) Usable(now time.Time) bool {
+	return t.RevokedAt.IsZero() && now.Before(t.ExpiresAt)
+}
+
+// Insert the token
+func (t *Token) Insert(db orm.DB) error {
+	_, err := db.Model(t).Returning("*").Insert()
+	return err
+}
+
+// Touch records that the token has been used
+func (t Token) Touch(db orm.DB, now time.Time) error {
+	_, err := db.Model(&t).Set("last_used_at = ?", now).WherePK().Update()
+	return err
+}
+
+// GetToken returns the usable token for the secret along with its
+// verified user
+func GetToken(db orm.DB, secret string, now time.Time) (Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, errors.New("invalid token")
+	}
+
+	var t Token
+	err := db.Model(&t).
+		Relation("User").
+		Where("token.hash = ?", HashToken(secret)).
+		Where("token.revoked_at IS NULL").
+		Where(`"user".verified_at IS NOT NULL`).
+		Select()
+	if err != nil {
+		return Token{}, errors.WithMessage(err, "Select token")
+	}
+
+	if !t.Usable(now) {
+		return Token{}, errors.New("expired token")
+	}
+
+	return t, nil
+}
+
+// GetTokens returns the user's personal tokens that have not been revoked
+func GetTokens(db orm.DB, userID int) ([]Token, error) {
+	tokens := make([]Token, 0)
+	err := db.Model(&tokens).
+		Where("user_id = ? AND organisation_id = 0 AND revoked_at IS NULL", userID).
+		Order("id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetOrganisationTokens returns the organisation's tokens that have not
+// been revoked
+func GetOrganisationTokens(db orm.DB, organisationID int) ([]Token, error) {
+	tokens := make([]Token, 0)
+	err := db.Model(&tokens).
+		Where("organisation_id = ? AND revoked_at IS NULL", organisationID).
+		Order("id").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Revoke the token so it can no longer be used
+func (t *Token) Revoke(db orm.DB, now time.Time) error {
+	t.RevokedAt = now
+	_, err := db.Model(t).Set("revoked_at = ?revoked_at").WherePK().Update()
+	return err
+}
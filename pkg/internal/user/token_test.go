@@ -0,0 +1,111 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NewToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type tcase struct {
+		name    string
+		scope   TokenScope
+		ttl     time.Duration
+		expires time.Time
+		err     bool
+	}
+
+	tests := []tcase{
+		{"ci", TokenScopeWrite, 0, now.Add(DefaultTokenTTL), false},
+		{"dashboard", TokenScopeRead, time.Hour, now.Add(time.Hour), false},
+		{"  padded  ", TokenScopeRead, MaxTokenTTL, now.Add(MaxTokenTTL), false},
+		{"", TokenScopeRead, 0, time.Time{}, true},
+		{strings.Repeat("a", 65), TokenScopeRead, 0, time.Time{}, true},
+		{"admin", "admin", 0, time.Time{}, true},
+		{"forever", TokenScopeRead, MaxTokenTTL + time.Hour, time.Time{}, true},
+		{"past", TokenScopeRead, -time.Hour, time.Time{}, true},
+	}
+
+	for _, tc := range tests {
+		token, secret, err := NewToken(1, 2, tc.name, tc.scope, tc.ttl, now)
+		if tc.err {
+			if err == nil {
+				t.Errorf("NewToken(%q) expected an error got nil", tc.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("NewToken(%q) expected nil got %q", tc.name, err)
+			continue
+		}
+
+		if token.Name != strings.TrimSpace(tc.name) {
+			t.Errorf("NewToken(%q) expected name %q got %q", tc.name, strings.TrimSpace(tc.name), token.Name)
+		}
+
+		if !token.ExpiresAt.Equal(tc.expires) {
+			t.Errorf("NewToken(%q) expected expiry %s got %s", tc.name, tc.expires, token.ExpiresAt)
+		}
+
+		if !strings.HasPrefix(secret, tokenPrefix) || !strings.HasPrefix(secret, token.Prefix) {
+			t.Errorf("NewToken(%q) unexpected secret %q for prefix %q", tc.name, secret, token.Prefix)
+		}
+
+		if token.Hash != HashToken(secret) || strings.Contains(token.Hash, secret) {
+			t.Errorf("NewToken(%q) expected the hash of the secret got %q", tc.name, token.Hash)
+		}
+
+		if token.UserID != 1 || token.OrganisationID != 2 {
+			t.Errorf("NewToken(%q) unexpected owner %d/%d", tc.name, token.UserID, token.OrganisationID)
+		}
+	}
+}
+
+func Test_NewTokenUnique(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	a, secretA, err := NewToken(1, 0, "a", TokenScopeRead, 0, now)
+	if err != nil {
+		t.Fatalf("NewToken() expected nil got %q", err)
+	}
+
+	b, secretB, err := NewToken(1, 0, "b", TokenScopeRead, 0, now)
+	if err != nil {
+		t.Fatalf("NewToken() expected nil got %q", err)
+	}
+
+	if secretA == secretB || a.Hash == b.Hash {
+		t.Fatal("NewToken() expected unique secrets")
+	}
+}
+
+func Test_TokenUsable(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type tcase struct {
+		token    Token
+		expected bool
+	}
+
+	tests := []tcase{
+		{Token{ExpiresAt: now.Add(time.Minute)}, true},
+		{Token{ExpiresAt: now}, false},
+		{Token{ExpiresAt: now.Add(-time.Minute)}, false},
+		{Token{ExpiresAt: now.Add(time.Minute), RevokedAt: now.Add(-time.Minute)}, false},
+	}
+
+	for i, tc := range tests {
+		if got := tc.token.Usable(now); got != tc.expected {
+			t.Errorf("%d: Usable() expected %t got %t", i, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+// TOTPIssuer names the account in authenticator apps
+const TOTPIssuer = "whois.bi"
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	// steps either side of now that are accepted to allow for clock drift
+	totpSkew = 1
+)
+
+// how many recovery codes are given out at a time
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random base32 encoded secret
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "Read")
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI returns the otpauth URI for the secret, usually shown as a QR
+// code to add the account to an authenticator app
+func TOTPURI(secret, email string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", TOTPIssuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprintf("%d", totpDigits))
+	values.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	return "otpauth://totp/" + url.PathEscape(TOTPIssuer+":"+email) + "?" + values.Encode()
+}
+
+// totpStep returns the time step now falls in
+func totpStep(now time.Time) int64 {
+	return now.Unix() / totpPeriod
+}
+
+// hotp returns the code for the counter, see RFC 4226
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, code%mod)
+}
+
+// TOTPCode returns the secret's code at now, see RFC 6238
+func TOTPCode(secret string, now time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", errors.Wrap(err, "DecodeString")
+	}
+	return hotp(key, totpStep(now)), nil
+}
+
+// validTOTP returns the step the code was generated for if it is valid
+// at now and after the last step used
+func validTOTP(secret, code string, last int64, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	step := totpStep(now)
+	for i := step - totpSkew; i <= step+totpSkew; i++ {
+		if i <= last {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(key, i)), []byte(code)) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// normaliseRecoveryCode strips formatting users may or may not type
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	return code
+}
+
+// newRecoveryCodes returns recovery codes and the hashes to store
+func newRecoveryCodes() ([]string, []string) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code := uniuri.NewLenChars(10, []byte("abcdefghijkmnpqrstuvwxyz23456789"))
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashToken(code))
+	}
+
+	return codes, hashes
+}
+
+// TwoFactorEnabled returns true if logging in needs a second step
+func (u User) TwoFactorEnabled() bool {
+	return !u.TOTPEnabledAt.IsZero()
+}
+
+// EnrolTOTP starts enrolment with a new secret which is not used until it
+// is confirmed by EnableTOTP
+func (u *User) EnrolTOTP() (string, error) {
+	if u.TwoFactorEnabled() {
+		return "", errors.New("two factor authentication is already enabled")
+	}
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	u.TOTPSecret = secret
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = nil
+
+	return secret, nil
+}
+
+// EnableTOTP confirms enrolment with a code from the authenticator app,
+// returning recovery codes which are only ever shown once
+func (u *User) EnableTOTP(code string, now time.Time) ([]string, error) {
+	if u.TwoFactorEnabled() {
+		return nil, errors.New("two factor authentication is already enabled")
+	}
+
+	if len(u.TOTPSecret) == 0 {
+		return nil, errors.New("two factor authentication has not been started")
+	}
+
+	step, ok := validTOTP(u.TOTPSecret, strings.TrimSpace(code), u.TOTPLastStep, now)
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	u.TOTPEnabledAt = now
+	u.TOTPLastStep = step
+
+	return u.RegenerateRecoveryCodes(), nil
+}
+
+// DisableTOTP removes two factor authentication
+func (u *User) DisableTOTP() {
+	u.TOTPSecret = ""
+	u.TOTPEnabledAt = time.Time{}
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = nil
+}
+
+// RegenerateRecoveryCodes replaces any existing recovery codes
+func (u *User) RegenerateRecoveryCodes() []string {
+	codes, hashes := newRecoveryCodes()
+	u.RecoveryCodes = hashes
+	return codes
+}
+
+// checkTwoFactor returns the TOTP step the code was generated for, or the
+// hash of the recovery code, if it is valid and unused
+func (u User) checkTwoFactor(code string, now time.Time) (int64, string, bool) {
+	if !u.TwoFactorEnabled() {
+		return 0, "", false
+	}
+
+	code = strings.TrimSpace(code)
+
+	if step, ok := validTOTP(u.TOTPSecret, code, u.TOTPLastStep, now); ok {
+		return step, "", true
+	}
+
+	hash := HashToken(normaliseRecoveryCode(code))
+	for _, h := range u.RecoveryCodes {
+		if hmac.Equal([]byte(h), []byte(hash)) {
+			return 0, h, true
+		}
+	}
+
+	return 0, "", false
+}
+
+// spendTwoFactor stops the step or recovery code being used again
+func (u *User) spendTwoFactor(step int64, hash string) {
+	if len(hash) == 0 {
+		u.TOTPLastStep = step
+		return
+	}
+
+	for i, h := range u.RecoveryCodes {
+		if h == hash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// VerifyTwoFactor returns true if the code is a valid TOTP code or an
+// unused recovery code. Neither can be used again, UseTwoFactor does the
+// same and stores that the code has been used
+func (u *User) VerifyTwoFactor(code string, now time.Time) bool {
+	step, hash, ok := u.checkTwoFactor(code, now)
+	if ok {
+		u.spendTwoFactor(step, hash)
+	}
+	return ok
+}
+
+// UseTwoFactor verifies the code and stores that it has been used. The
+// update only succeeds if the code has not been used since the user was
+// loaded, so concurrent requests can not use the same code twice
+func (u *User) UseTwoFactor(db orm.DB, code string, now time.Time) (bool, error) {
+	step, hash, ok := u.checkTwoFactor(code, now)
+	if !ok {
+		return false, nil
+	}
+
+	query := db.Model(u).WherePK()
+
+	if len(hash) == 0 {
+		query = query.
+			Set("totp_last_step = ?", step).
+			Where("totp_secret = ? AND totp_last_step < ?", u.TOTPSecret, step)
+	} else {
+		// recovery codes are stored as a jsonb array
+		query = query.
+			Set("recovery_codes = recovery_codes - ?", hash).
+			Where("recovery_codes @> ?::jsonb", []string{hash})
+	}
+
+	res, err := query.Update()
+	if err != nil {
+		return false, errors.Wrap(err, "Update")
+	}
+
+	if res.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	u.spendTwoFactor(step, hash)
+
+	return true, nil
+}
+
+// UpdateRecoveryCodes stores the user's recovery codes without touching
+// the rest of their two factor state
+func (u *User) UpdateRecoveryCodes(db orm.DB) error {
+	_, err := db.Model(u).
+		Set("recovery_codes = ?recovery_codes").
+		WherePK().
+		Update()
+	return err
+}
+
+// UpdateTwoFactor stores the user's two factor authentication state
+func (u *User) UpdateTwoFactor(db orm.DB) error {
+	_, err := db.Model(u).
+		Set("totp_secret = ?totp_secret, totp_enabled_at = ?totp_enabled_at, totp_last_step = ?totp_last_step, recovery_codes = ?recovery_codes").
+		WherePK().
+		Update()
+	return err
+}
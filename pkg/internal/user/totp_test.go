@@ -0,0 +1,293 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// base32 of the RFC 6238 SHA1 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func Test_TOTPCode(t *testing.T) {
+	t.Parallel()
+
+	type tcase struct {
+		unix     int64
+		expected string
+	}
+
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := []tcase{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range tests {
+		code, err := TOTPCode(rfcSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d) expected nil got %q", tc.unix, err)
+		}
+
+		if code != tc.expected {
+			t.Errorf("TOTPCode(%d) expected %q got %q", tc.unix, tc.expected, code)
+		}
+	}
+}
+
+func Test_TOTPURI(t *testing.T) {
+	t.Parallel()
+
+	uri := TOTPURI("ABC", "jess@example.com")
+
+	expected := "otpauth://totp/whois.bi:jess@example.com?algorithm=SHA1&digits=6&issuer=whois.bi&period=30&secret=ABC"
+	if uri != expected {
+		t.Errorf("TOTPURI() expected %q got %q", expected, uri)
+	}
+}
+
+func enabledUser(t *testing.T, now time.Time) (User, []string) {
+	t.Helper()
+
+	var u User
+
+	secret, err := u.EnrolTOTP()
+	if err != nil {
+		t.Fatalf("EnrolTOTP() expected nil got %q", err)
+	}
+
+	if u.TwoFactorEnabled() {
+		t.Fatal("TwoFactorEnabled() expected false before confirming")
+	}
+
+	if _, err := u.EnableTOTP("000000", now); err == nil {
+		t.Fatal("EnableTOTP() expected an error for a wrong code got nil")
+	}
+
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode() expected nil got %q", err)
+	}
+
+	codes, err := u.EnableTOTP(code, now)
+	if err != nil {
+		t.Fatalf("EnableTOTP() expected nil got %q", err)
+	}
+
+	if !u.TwoFactorEnabled() {
+		t.Fatal("TwoFactorEnabled() expected true")
+	}
+
+	if len(codes) != recoveryCodeCount || len(u.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("EnableTOTP() expected %d recovery codes got %d/%d", recoveryCodeCount, len(codes), len(u.RecoveryCodes))
+	}
+
+	return u, codes
+}
+
+func Test_VerifyTwoFactorTOTP(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	u, _ := enabledUser(t, now)
+
+	// the code used to enable can not be used again
+	code, _ := TOTPCode(u.TOTPSecret, now)
+	if u.VerifyTwoFactor(code, now) {
+		t.Error("VerifyTwoFactor() expected a used code to fail")
+	}
+
+	next := now.Add(time.Second * totpPeriod)
+	code, _ = TOTPCode(u.TOTPSecret, next)
+
+	// allowed for clock drift
+	if !u.VerifyTwoFactor(" "+code+" ", now) {
+		t.Error("VerifyTwoFactor() expected the next code to pass")
+	}
+
+	if u.VerifyTwoFactor(code, now) {
+		t.Error("VerifyTwoFactor() expected a replayed code to fail")
+	}
+
+	later := now.Add(time.Minute * 10)
+	code, _ = TOTPCode(u.TOTPSecret, later)
+	if u.VerifyTwoFactor(code, now) {
+		t.Error("VerifyTwoFactor() expected a code outside the skew to fail")
+	}
+
+	if !u.VerifyTwoFactor(code, later) {
+		t.Error("VerifyTwoFactor() expected a later code to pass")
+	}
+}
+
+func Test_VerifyTwoFactorRecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	u, codes := enabledUser(t, now)
+
+	if !u.VerifyTwoFactor(strings.ToUpper(codes[3]), now) {
+		t.Fatal("VerifyTwoFactor() expected a recovery code to pass")
+	}
+
+	if u.VerifyTwoFactor(codes[3], now) {
+		t.Error("VerifyTwoFactor() expected a used recovery code to fail")
+	}
+
+	if len(u.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("VerifyTwoFactor() expected %d recovery codes left got %d", recoveryCodeCount-1, len(u.RecoveryCodes))
+	}
+
+	if !u.VerifyTwoFactor(strings.Replace(codes[0], "-", "", 1), now) {
+		t.Error("VerifyTwoFactor() expected a recovery code without the dash to pass")
+	}
+
+	regenerated := u.RegenerateRecoveryCodes()
+	if u.VerifyTwoFactor(codes[1], now) {
+		t.Error("VerifyTwoFactor() expected a replaced recovery code to fail")
+	}
+
+	if !u.VerifyTwoFactor(regenerated[1], now) {
+		t.Error("VerifyTwoFactor() expected a new recovery code to pass")
+	}
+
+	u.DisableTOTP()
+	if u.TwoFactorEnabled() || u.VerifyTwoFactor(regenerated[2], now) {
+		t.Error("DisableTOTP() expected two factor to be disabled")
+	}
+}
+
+func Test_EnrolTOTPEnabled(t *testing.T) {
+	t.Parallel()
+
+	u, _ := enabledUser(t, time.Now())
+
+	if _, err := u.EnrolTOTP(); err == nil {
+		t.Fatal("EnrolTOTP() expected an error when enabled got nil")
+	}
+}
+
+func Test_UseTwoFactor(t *testing.T) {
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	enabled, codes := enabledUser(t, now)
+
+	u := createUser(t, tx)
+	u.TOTPSecret = enabled.TOTPSecret
+	u.TOTPEnabledAt = enabled.TOTPEnabledAt
+	u.TOTPLastStep = enabled.TOTPLastStep
+	u.RecoveryCodes = enabled.RecoveryCodes
+
+	if err := u.UpdateTwoFactor(tx); err != nil {
+		t.Fatalf("UpdateTwoFactor() expected nil got %q", err)
+	}
+
+	// two requests loaded the user at the same time
+	first, second := u, u
+	second.RecoveryCodes = append([]string{}, u.RecoveryCodes...)
+
+	next := now.Add(time.Second * totpPeriod)
+	code, _ := TOTPCode(u.TOTPSecret, next)
+
+	if ok, err := first.UseTwoFactor(tx, code, next); err != nil || !ok {
+		t.Fatalf("UseTwoFactor() expected true got %t (%v)", ok, err)
+	}
+
+	if ok, err := second.UseTwoFactor(tx, code, next); err != nil || ok {
+		t.Fatalf("UseTwoFactor() expected a concurrently used code to fail got %t (%v)", ok, err)
+	}
+
+	if ok, err := first.UseTwoFactor(tx, codes[2], next); err != nil || !ok {
+		t.Fatalf("UseTwoFactor() expected a recovery code to pass got %t (%v)", ok, err)
+	}
+
+	if ok, err := second.UseTwoFactor(tx, codes[2], next); err != nil || ok {
+		t.Fatalf("UseTwoFactor() expected a concurrently used recovery code to fail got %t (%v)", ok, err)
+	}
+
+	var stored User
+	if err := tx.Model(&stored).Where("id = ?", u.ID).Select(); err != nil {
+		t.Fatalf("Select() expected nil got %q", err)
+	}
+
+	if stored.TOTPLastStep != totpStep(next) || len(stored.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Fatalf("unexpected two factor state step %d with %d recovery codes", stored.TOTPLastStep, len(stored.RecoveryCodes))
+	}
+
+	// replacing the recovery codes keeps the step
+	second.RegenerateRecoveryCodes()
+	if err := second.UpdateRecoveryCodes(tx); err != nil {
+		t.Fatalf("UpdateRecoveryCodes() expected nil got %q", err)
+	}
+
+	if err := tx.Model(&stored).Where("id = ?", u.ID).Select(); err != nil {
+		t.Fatalf("Select() expected nil got %q", err)
+	}
+
+	if stored.TOTPLastStep != totpStep(next) || len(stored.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("unexpected two factor state step %d with %d recovery codes", stored.TOTPLastStep, len(stored.RecoveryCodes))
+	}
+}
+
+func Test_UseTwoFactorRecoveryCode(t *testing.T) {
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	enabled, codes := enabledUser(t, now)
+
+	u := createUser(t, tx)
+	u.TOTPSecret = enabled.TOTPSecret
+	u.TOTPEnabledAt = enabled.TOTPEnabledAt
+	u.TOTPLastStep = enabled.TOTPLastStep
+	u.RecoveryCodes = enabled.RecoveryCodes
+
+	if err := u.UpdateTwoFactor(tx); err != nil {
+		t.Fatalf("UpdateTwoFactor() expected nil got %q", err)
+	}
+
+	// each login loads the user from the database
+	load := func() User {
+		t.Helper()
+		var loaded User
+		if err := tx.Model(&loaded).Where("id = ?", u.ID).Select(); err != nil {
+			t.Fatalf("Select() expected nil got %q", err)
+		}
+		return loaded
+	}
+
+	login := load()
+	if ok, err := login.UseTwoFactor(tx, codes[4], now); err != nil || !ok {
+		t.Fatalf("UseTwoFactor() expected a recovery code to pass got %t (%v)", ok, err)
+	}
+
+	again := load()
+	if ok, err := again.UseTwoFactor(tx, codes[4], now); err != nil || ok {
+		t.Fatalf("UseTwoFactor() expected a used recovery code to fail got %t (%v)", ok, err)
+	}
+
+	if len(again.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Fatalf("expected %d recovery codes left got %d", recoveryCodeCount-1, len(again.RecoveryCodes))
+	}
+
+	other := load()
+	if ok, err := other.UseTwoFactor(tx, codes[5], now); err != nil || !ok {
+		t.Fatalf("UseTwoFactor() expected another recovery code to pass got %t (%v)", ok, err)
+	}
+}
@@ -25,6 +25,45 @@ type User struct {
 	VerifiedCode string `pg:",notnull"`
 
 	LastLoginAt time.Time
+
+	// billing plan, used to enforce limits
+	Plan Plan `pg:",notnull,type:text,default:'free'"`
+
+	// used to choose email templates
+	Locale string `pg:",notnull,default:'en'"`
+
+	// IANA timezone used for scheduling digests
+	Timezone string `pg:",notnull,default:'UTC'"`
+
+	// opt in digest emails, see DigestDue
+	DigestFrequency DigestFrequency `pg:",notnull,use_zero"`
+	// local time of day in the form 15:04
+	DigestTime string `pg:",notnull,default:'08:00'"`
+	// day of the week for weekly digests, 0 is Sunday
+	DigestWeekday int `pg:",notnull,default:1"`
+	// end of the last digest period sent
+	LastDigestAt time.Time
+
+	// alert batching, see AlertsDue
+	AlertMinDomains int `pg:",notnull,default:5"`
+	// minutes
+	AlertMaxDelay int `pg:",notnull,default:60"`
+	// local times in the form 15:04, empty disables quiet hours
+	QuietHoursStart string `pg:",notnull,use_zero"`
+	QuietHoursEnd   string `pg:",notnull,use_zero"`
+
+	// two factor authentication, see VerifyTwoFactor
+	TOTPSecret string `pg:",notnull,use_zero"`
+	// zero until enrolment is confirmed
+	TOTPEnabledAt time.Time
+	// last time step used, stops a code from being used twice
+	TOTPLastStep int64 `pg:",notnull,use_zero"`
+	// hashes of unused recovery codes
+	RecoveryCodes []string `pg:",use_zero"`
+
+	// identifies the user at the OpenID Connect provider once they have
+	// logged in with SSO
+	OIDCSubject string `pg:",notnull,use_zero"`
 }
 
 var passwordValidation = map[string][]*unicode.RangeTable{
@@ -74,9 +113,35 @@ func NewUser(email, password string) (User, error) {
 	verifiedCode := uniuri.NewLen(32)
 
 	user := User{
-		Email:        email,
+		Email:        NormaliseEmail(email),
 		Password:     passwordHash,
 		VerifiedCode: verifiedCode,
+
+		AlertMinDomains: DefaultAlertMinDomains,
+		AlertMaxDelay:   DefaultAlertMaxDelay,
+	}
+
+	return user, nil
+}
+
+// NewSSOUser creates a verified user for someone logging in with SSO for
+// the first time, they are given a random password which can be changed
+// by recovering their account
+func NewSSOUser(email, subject string, now time.Time) (User, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(uniuri.NewLen(32)), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, errors.WithMessage(err, "bcrypt")
+	}
+
+	user := User{
+		Email:        NormaliseEmail(email),
+		Password:     passwordHash,
+		VerifiedAt:   now,
+		VerifiedCode: uniuri.NewLen(32),
+		OIDCSubject:  subject,
+
+		AlertMinDomains: DefaultAlertMinDomains,
+		AlertMaxDelay:   DefaultAlertMaxDelay,
 	}
 
 	return user, nil
@@ -90,9 +155,11 @@ func (u *User) Insert(db orm.DB) error {
 	return nil
 }
 
+// GetUser returns the user with the email, ignoring case as accounts
+// created before emails were normalised may have mixed case
 func GetUser(db orm.DB, email string) (User, error) {
 	var user User
-	if err := db.Model(&user).Where("email = ?", email).Select(); err != nil {
+	if err := db.Model(&user).Where("lower(email) = ?", NormaliseEmail(email)).Select(); err != nil {
 		return User{}, err
 	}
 
@@ -112,6 +112,61 @@ func Test_NewUser(t *testing.T) {
 	}
 }
 
+func Test_GetUserCase(t *testing.T) {
+	t.Parallel()
+
+	conn := createConnection(t)
+	defer conn.Close()
+
+	tx := createTx(t, conn)
+	defer tx.Rollback()
+
+	user, err := NewUser(" MixedCase@Place.com", password)
+	if err != nil {
+		t.Fatalf("NewUser expected nil got %q", err)
+	}
+
+	if user.Email != "mixedcase@place.com" {
+		t.Fatalf("NewUser expected email %q got %q", "mixedcase@place.com", user.Email)
+	}
+
+	if err := user.Insert(tx); err != nil {
+		t.Fatalf("Insert expected nil got %q", err)
+	}
+
+	// an account stored before emails were normalised
+	legacy, err := NewUser("legacy@place.com", password)
+	if err != nil {
+		t.Fatalf("NewUser expected nil got %q", err)
+	}
+	legacy.Email = "Legacy@Place.com"
+
+	if err := legacy.Insert(tx); err != nil {
+		t.Fatalf("Insert expected nil got %q", err)
+	}
+
+	tests := []struct {
+		email    string
+		expected int
+	}{
+		{"MIXEDCASE@place.com", user.ID},
+		{"mixedcase@place.com ", user.ID},
+		{"legacy@place.com", legacy.ID},
+		{"LEGACY@PLACE.COM", legacy.ID},
+	}
+
+	for _, tt := range tests {
+		got, err := GetUser(tx, tt.email)
+		if err != nil {
+			t.Fatalf("GetUser(%q) expected nil got %q", tt.email, err)
+		}
+
+		if got.ID != tt.expected {
+			t.Errorf("GetUser(%q) expected user %d got %d", tt.email, tt.expected, got.ID)
+		}
+	}
+}
+
 func Test_DuplicateUser(t *testing.T) {
 	t.Parallel()
 
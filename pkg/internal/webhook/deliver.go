@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// attempts before a delivery is marked as failed
+	maxAttempts = 8
+	// first retry delay, doubled for each attempt
+	retryBackoff = time.Minute
+	// cap on the retry delay
+	maxRetryBackoff = time.Hour * 6
+	// how long an endpoint has to respond
+	deliveryTimeout = time.Second * 10
+	// truncate stored error messages
+	maxErrorLength = 512
+	// most of a response body read so the connection can be reused
+	maxDrainLength = 1 << 16
+)
+
+// backoff returns how long to wait before the next attempt
+func backoff(attempts int) time.Duration {
+	d := retryBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return d
+}
+
+// deliver posts the delivery's payload to its webhook and records the
+// outcome on the delivery. An error is returned if the endpoint did not
+// accept the payload.
+func deliver(ctx context.Context, client *http.Client, d *Delivery, now time.Time) error {
+	d.Attempts++
+
+	err := post(ctx, client, d, now)
+	if err == nil {
+		d.Status = DeliveryDelivered
+		d.Error = ""
+		d.DeliveredAt = now
+		return nil
+	}
+
+	d.Error = truncate(err.Error())
+
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryFailed
+	} else {
+		d.Status = DeliveryPending
+		d.NextAttemptAt = now.Add(backoff(d.Attempts))
+	}
+
+	return err
+}
+
+func post(ctx context.Context, client *http.Client, d *Delivery, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "whois.bi webhooks")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, d.EventID)
+	req.Header.Set(SignatureHeader, Sign(d.Webhook.Secret, now, d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		d.StatusCode = 0
+		return err
+	}
+	defer resp.Body.Close()
+
+	d.StatusCode = resp.StatusCode
+
+	// drain so the connection can be reused, the body is not kept as users
+	// can read the delivery log
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainLength))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+	return s
+}
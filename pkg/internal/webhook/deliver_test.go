@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, time.Minute * 2},
+		{3, time.Minute * 4},
+		{20, maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.expected {
+			t.Errorf("backoff(%d) expected %s got %s", tt.attempts, tt.expected, got)
+		}
+	}
+}
+
+func Test_Deliver(t *testing.T) {
+	var received []byte
+	var verifyErr error
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = body
+		verifyErr = Verify("secret", r.Header.Get(SignatureHeader), body, time.Minute)
+
+		if r.Header.Get(EventHeader) != EventPing || r.Header.Get(DeliveryHeader) != "event" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := Delivery{
+		Webhook:   Webhook{URL: server.URL, Secret: "secret"},
+		EventID:   "event",
+		EventType: EventPing,
+		Payload:   []byte(`{"id":"event"}`),
+		Status:    DeliveryPending,
+	}
+
+	now := time.Now()
+
+	if err := deliver(context.Background(), server.Client(), &d, now); err != nil {
+		t.Fatalf("deliver() expected nil got %q", err)
+	}
+
+	if verifyErr != nil {
+		t.Fatalf("Verify() expected nil got %q", verifyErr)
+	}
+
+	if string(received) != string(d.Payload) {
+		t.Fatalf("expected payload %q got %q", d.Payload, received)
+	}
+
+	if d.Status != DeliveryDelivered || d.Attempts != 1 || d.StatusCode != http.StatusNoContent || !d.DeliveredAt.Equal(now) {
+		t.Fatalf("unexpected delivery state: %+v", d)
+	}
+}
+
+func Test_DeliverRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := Delivery{
+		Webhook: Webhook{URL: server.URL, Secret: "secret"},
+		Payload: []byte(`{}`),
+		Status:  DeliveryPending,
+	}
+
+	now := time.Now()
+
+	if err := deliver(context.Background(), server.Client(), &d, now); err == nil {
+		t.Fatal("deliver() expected error")
+	}
+
+	if d.Status != DeliveryPending || d.StatusCode != http.StatusInternalServerError || d.Error == "" {
+		t.Fatalf("unexpected delivery state: %+v", d)
+	}
+
+	if strings.Contains(d.Error, "broken") {
+		t.Fatalf("expected the response body not to be recorded got %q", d.Error)
+	}
+
+	if !d.NextAttemptAt.Equal(now.Add(retryBackoff)) {
+		t.Fatalf("expected next attempt at %s got %s", now.Add(retryBackoff), d.NextAttemptAt)
+	}
+
+	// run out of attempts
+	d.Attempts = maxAttempts - 1
+
+	if err := deliver(context.Background(), server.Client(), &d, now); err == nil {
+		t.Fatal("deliver() expected error")
+	}
+
+	if d.Status != DeliveryFailed {
+		t.Fatalf("expected status %q got %q", DeliveryFailed, d.Status)
+	}
+}
+
+func Test_DeliverPrivate(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	d := Delivery{
+		Webhook: Webhook{URL: server.URL, Secret: "secret"},
+		Payload: []byte(`{}`),
+		Status:  DeliveryPending,
+	}
+
+	if err := deliver(context.Background(), NewClient(), &d, time.Now()); err == nil {
+		t.Fatal("deliver() to a loopback address expected error")
+	}
+
+	if called || d.StatusCode != 0 || d.Error == "" {
+		t.Fatalf("unexpected delivery state: %+v", d)
+	}
+}
+
+func Test_PublicIP(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"100.64.0.1":      false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+
+	for addr, expected := range tests {
+		if got := publicIP(net.ParseIP(addr)); got != expected {
+			t.Errorf("publicIP(%s) expected %t got %t", addr, expected, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ranges webhooks can not be delivered to, as well as loopback, link local
+// and multicast addresses
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"fc00::/7",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// publicIP returns false for addresses on the server's own networks, which
+// users must not be able to make it send requests to
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	// IPv4 mapped IPv6 addresses are checked as IPv4
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkHost returns an error if the url's host is an address or name that
+// is never public, names are checked again when they are dialled
+func checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.Errorf("host %q is not allowed", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return errors.Errorf("address %s is not allowed", ip)
+	}
+
+	return nil
+}
+
+// control rejects connections to addresses that are not public. It runs
+// after the name is resolved, so a name that resolves to a private address
+// when it is delivered to is refused as well
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "SplitHostPort")
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return errors.Errorf("address %s is not allowed", host)
+	}
+
+	return nil
+}
+
+// NewClient returns a client that only connects to public addresses, used
+// to deliver to user supplied urls
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   deliveryTimeout,
+		KeepAlive: time.Second * 30,
+		Control:   control,
+	}
+
+	transport := &http.Transport{
+		// a proxy would make the connection for us, bypassing the check
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: deliveryTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     time.Second * 90,
+	}
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// how often pending deliveries are checked
+	dispatchInterval = time.Second * 10
+	// maximum deliveries attempted per tick
+	dispatchBatch = 100
+	// maximum deliveries attempted per webhook per tick, so one busy
+	// webhook can not use the whole batch
+	dispatchPerWebhook = 10
+	// number of webhooks delivered to at once, each webhook's deliveries
+	// are sent in order so a slow endpoint only holds up its own
+	dispatchWorkers = 8
+)
+
+// Enqueue stores a delivery of the event for each of the owner's webhooks
+// in the organisation, 0 for the owner's own account, that are subscribed
+// to it, they are sent by the Dispatcher
+func Enqueue(db orm.DB, ownerID, organisationID int, event Event) error {
+	var webhooks []Webhook
+	err := db.Model(&webhooks).
+		Where("owner_id = ? AND organisation_id = ?", ownerID, organisationID).
+		Select()
+	if err != nil {
+		return errors.WithMessage(err, "Select webhooks")
+	}
+
+	return enqueue(db, webhooks, event)
+}
+
+func enqueue(db orm.DB, webhooks []Webhook, event Event) error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	deliveries := make([]Delivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		if !w.Wants(event.Type) {
+			continue
+		}
+
+		deliveries = append(deliveries, Delivery{
+			WebhookID:     w.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			CreatedAt:     event.CreatedAt,
+			NextAttemptAt: event.CreatedAt,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if _, err := db.Model(&deliveries).Insert(); err != nil {
+		return errors.WithMessage(err, "Insert deliveries")
+	}
+
+	return nil
+}
+
+// Ping enqueues a ping event to a single webhook
+func (w Webhook) Ping(db orm.DB) (Event, error) {
+	event := NewEvent(EventPing, "", map[string]string{"message": "pong"})
+	return event, enqueue(db, []Webhook{w}, event)
+}
+
+// GetWebhook returns a webhook belonging to the owner in the organisation,
+// 0 for the owner's own account
+func GetWebhook(db orm.DB, ownerID, organisationID, id int) (Webhook, error) {
+	var w Webhook
+	err := db.Model(&w).
+		Where("id = ? AND owner_id = ? AND organisation_id = ?", id, ownerID, organisationID).
+		Select()
+	return w, err
+}
+
+// GetDeliveries returns the most recent deliveries for a webhook
+func (w Webhook) GetDeliveries(db orm.DB, limit int) ([]Delivery, error) {
+	deliveries := make([]Delivery, 0)
+	err := db.Model(&deliveries).
+		Where("webhook_id = ?", w.ID).
+		Order("created_at DESC", "id DESC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Dispatcher sends pending deliveries, retrying failures with backoff
+type Dispatcher struct {
+	db     *pg.DB
+	client *http.Client
+}
+
+// NewDispatcher creates a Dispatcher, only one should be run at a time
+func NewDispatcher(db *pg.DB) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: NewClient(),
+	}
+}
+
+// Run sends pending deliveries until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := d.dispatch(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) error {
+	now := time.Now()
+
+	var deliveries []Delivery
+	err := d.db.Model(&deliveries).
+		Relation("Webhook").
+		Where(
+			`delivery.id IN (
+				SELECT id FROM (
+					SELECT id, row_number() OVER (PARTITION BY webhook_id ORDER BY next_attempt_at, id) AS n
+					FROM ?TableName
+					WHERE status = ? AND next_attempt_at <= ?
+				) AS due
+				WHERE due.n <= ?
+			)`,
+			DeliveryPending,
+			now,
+			dispatchPerWebhook,
+		).
+		Order("delivery.next_attempt_at", "delivery.id").
+		Limit(dispatchBatch).
+		Select()
+	if err != nil {
+		return errors.WithMessage(err, "Select deliveries")
+	}
+
+	work := make(chan []Delivery)
+	errs := make(chan error, dispatchWorkers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < dispatchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var firstErr error
+			for batch := range work {
+				if err := d.send(ctx, batch); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			errs <- firstErr
+		}()
+	}
+
+	for _, batch := range byWebhook(deliveries) {
+		work <- batch
+	}
+	close(work)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// byWebhook groups the deliveries by webhook keeping their order
+func byWebhook(deliveries []Delivery) [][]Delivery {
+	batches := make([][]Delivery, 0)
+	index := make(map[int]int)
+
+	for _, delivery := range deliveries {
+		idx, ok := index[delivery.WebhookID]
+		if !ok {
+			idx = len(batches)
+			index[delivery.WebhookID] = idx
+			batches = append(batches, nil)
+		}
+
+		batches[idx] = append(batches[idx], delivery)
+	}
+
+	return batches
+}
+
+// send attempts a webhook's deliveries in order, storing the result of
+// each attempt
+func (d *Dispatcher) send(ctx context.Context, deliveries []Delivery) error {
+	for idx := range deliveries {
+		delivery := &deliveries[idx]
+
+		// the webhook was removed after the event was queued
+		if delivery.Webhook.ID == 0 || !delivery.Webhook.DeletedAt.IsZero() {
+			delivery.Status = DeliveryFailed
+			delivery.Error = "webhook deleted"
+		} else if err := deliver(ctx, d.client, delivery, time.Now()); err != nil {
+			log.Printf(
+				"Error delivering %s to webhook %d (attempt %d): %s",
+				delivery.EventID,
+				delivery.WebhookID,
+				delivery.Attempts,
+				err,
+			)
+		}
+
+		_, err := d.db.Model(delivery).
+			Set(
+				"status = ?status, attempts = ?attempts, status_code = ?status_code, error = ?error, next_attempt_at = ?next_attempt_at, delivered_at = ?delivered_at",
+			).
+			WherePK().
+			Update()
+		if err != nil {
+			return errors.WithMessagef(err, "Update delivery %d", delivery.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package webhook
+
+import "testing"
+
+func Test_ByWebhook(t *testing.T) {
+	deliveries := []Delivery{
+		{ID: 1, WebhookID: 10},
+		{ID: 2, WebhookID: 20},
+		{ID: 3, WebhookID: 10},
+		{ID: 4, WebhookID: 30},
+		{ID: 5, WebhookID: 20},
+	}
+
+	batches := byWebhook(deliveries)
+
+	expected := [][]int{{1, 3}, {2, 5}, {4}}
+
+	if len(batches) != len(expected) {
+		t.Fatalf("byWebhook() expected %d batches got %d", len(expected), len(batches))
+	}
+
+	for i, batch := range batches {
+		if len(batch) != len(expected[i]) {
+			t.Fatalf("byWebhook() batch %d expected %d deliveries got %d", i, len(expected[i]), len(batch))
+		}
+
+		for j, delivery := range batch {
+			if delivery.ID != expected[i][j] {
+				t.Errorf("byWebhook() batch %d expected delivery %d got %d", i, expected[i][j], delivery.ID)
+			}
+		}
+	}
+}
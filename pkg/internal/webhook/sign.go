@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// header containing the signature of the payload
+	SignatureHeader = "X-Whoisbi-Signature"
+	// header containing the event type
+	EventHeader = "X-Whoisbi-Event"
+	// header containing the event id, it is the same for every attempt
+	DeliveryHeader = "X-Whoisbi-Delivery"
+)
+
+// Sign returns the value of the signature header for a payload sent at
+// the provided time. The signature is the hex encoded HMAC-SHA256 of
+// "<unix timestamp>.<payload>" using the webhook secret:
+//
+//	t=1600000000,v1=<hex encoded hmac>
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := t.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac(secret, ts, payload)))
+}
+
+func mac(secret string, ts int64, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", ts)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Verify checks a signature header created by Sign, signatures older than
+// tolerance are rejected to prevent replays
+func Verify(secret, header string, payload []byte, tolerance time.Duration) error {
+	var ts int64
+	var sig []byte
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid signature header %q", header)
+		}
+
+		var err error
+		switch kv[0] {
+		case "t":
+			ts, err = strconv.ParseInt(kv[1], 10, 64)
+		case "v1":
+			sig, err = hex.DecodeString(kv[1])
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "invalid signature header %q", header)
+		}
+	}
+
+	if ts == 0 || len(sig) == 0 {
+		return errors.Errorf("invalid signature header %q", header)
+	}
+
+	if age := time.Since(time.Unix(ts, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return errors.Errorf("signature timestamp outside of tolerance: %s", age)
+	}
+
+	if !hmac.Equal(sig, mac(secret, ts, payload)) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SignVerify(t *testing.T) {
+	payload := []byte(`{"id":"abc","type":"ping"}`)
+
+	header := Sign("secret", time.Now(), payload)
+
+	if err := Verify("secret", header, payload, time.Minute); err != nil {
+		t.Fatalf("Verify() expected nil got %q", err)
+	}
+
+	if err := Verify("other", header, payload, time.Minute); err == nil {
+		t.Fatal("Verify() with wrong secret expected error")
+	}
+
+	if err := Verify("secret", header, []byte(`{"id":"abc","type":"pong"}`), time.Minute); err == nil {
+		t.Fatal("Verify() with tampered payload expected error")
+	}
+
+	old := Sign("secret", time.Now().Add(time.Hour*-1), payload)
+	if err := Verify("secret", old, payload, time.Minute); err == nil {
+		t.Fatal("Verify() with old timestamp expected error")
+	}
+
+	for _, header := range []string{"", "t=1", "v1=abcd", "t=abc,v1=abcd", "t=1,v1=zz"} {
+		if err := Verify("secret", header, payload, 0); err == nil {
+			t.Fatalf("Verify() with header %q expected error", header)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package webhook delivers signed JSON events to endpoints registered by
+// users. Deliveries are stored and retried with backoff until they succeed
+// or run out of attempts.
+package webhook
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/jawr/whois-bi/pkg/internal/user"
+	"github.com/pkg/errors"
+)
+
+type EventType = string
+
+const (
+	// records were added or removed
+	EventRecordChanges EventType = "records.changed"
+	// a new whois version was found
+	EventWhoisChange EventType = "whois.changed"
+	// the domain is about to expire
+	EventExpiration EventType = "domain.expiring"
+	// sent when testing a webhook
+	EventPing EventType = "ping"
+)
+
+var eventTypes = map[EventType]struct{}{
+	EventRecordChanges: {},
+	EventWhoisChange:   {},
+	EventExpiration:    {},
+}
+
+// Webhook is an endpoint that receives events for all of the domains in
+// its owner's account or organisation
+type Webhook struct {
+	ID int `pg:",pk" json:"id"`
+
+	OwnerID int       `pg:",notnull" json:"owner_id"`
+	Owner   user.User `pg:"fk:owner_id,rel:has-one" json:"-"`
+
+	// 0 for webhooks in the owner's own account
+	OrganisationID int `pg:",notnull,use_zero" json:"organisation_id"`
+
+	URL string `pg:",notnull" json:"url"`
+
+	// used to sign payloads, see Sign. Only shown when the webhook is
+	// created
+	Secret string `pg:",notnull" json:"-"`
+
+	// events to send, empty means all events
+	Events []string `pg:",use_zero" json:"events"`
+
+	AddedAt   time.Time `pg:",type:timestamptz,notnull,default:now()" json:"added_at"`
+	DeletedAt time.Time `pg:",soft_delete" json:"deleted_at"`
+}
+
+// create a new webhook with a random secret
+func NewWebhook(owner user.User, endpoint string, events []string) (Webhook, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return Webhook{}, errors.WithMessage(err, "invalid url")
+	}
+
+	if (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return Webhook{}, errors.Errorf("invalid url %q, expected http(s)://host", endpoint)
+	}
+
+	if err := checkHost(u.Hostname()); err != nil {
+		return Webhook{}, errors.WithMessage(err, "invalid url")
+	}
+
+	for _, e := range events {
+		if _, ok := eventTypes[e]; !ok {
+			return Webhook{}, errors.Errorf("unknown event %q", e)
+		}
+	}
+
+	if events == nil {
+		events = make([]string, 0)
+	}
+
+	w := Webhook{
+		OwnerID: owner.ID,
+		Owner:   owner,
+		URL:     u.String(),
+		Secret:  uniuri.NewLen(32),
+		Events:  events,
+	}
+
+	return w, nil
+}
+
+// Wants returns true if the webhook is subscribed to the event type
+func (w Webhook) Wants(eventType EventType) bool {
+	if eventType == EventPing || len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event is the JSON payload posted to webhooks
+type Event struct {
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	Domain    string      `json:"domain"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// create a new event with a unique id
+func NewEvent(eventType EventType, domain string, data interface{}) Event {
+	return Event{
+		ID:        uniuri.NewLen(24),
+		Type:      eventType,
+		Domain:    domain,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+}
+
+type DeliveryStatus = string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is an attempt to send an event to a webhook, it is kept as a
+// log that users can inspect
+type Delivery struct {
+	ID int `pg:",pk" json:"id"`
+
+	WebhookID int     `pg:",notnull" json:"webhook_id"`
+	Webhook   Webhook `pg:"fk:webhook_id,rel:has-one" json:"-"`
+
+	EventID   string    `pg:",notnull" json:"event_id"`
+	EventType EventType `pg:",notnull" json:"event_type"`
+	Payload   []byte    `pg:",notnull" json:"payload"`
+
+	Status     DeliveryStatus `pg:",notnull,type:text,default:'pending'" json:"status"`
+	Attempts   int            `pg:",notnull,use_zero" json:"attempts"`
+	StatusCode int            `pg:",notnull,use_zero" json:"status_code"`
+	Error      string         `pg:",notnull,use_zero" json:"error"`
+
+	CreatedAt     time.Time `pg:",type:timestamptz,notnull,default:now()" json:"created_at"`
+	NextAttemptAt time.Time `pg:",type:timestamptz,notnull,default:now()" json:"next_attempt_at"`
+	DeliveredAt   time.Time `pg:",type:timestamptz" json:"delivered_at"`
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jawr/whois-bi/pkg/internal/user"
+)
+
+func Test_NewWebhook(t *testing.T) {
+	owner := user.User{ID: 1}
+
+	w, err := NewWebhook(owner, "https://example.com/hook", nil)
+	if err != nil {
+		t.Fatalf("NewWebhook() expected nil got %q", err)
+	}
+
+	if len(w.Secret) != 32 || w.OwnerID != 1 || w.Events == nil {
+		t.Fatalf("unexpected webhook: %+v", w)
+	}
+
+	for _, endpoint := range []string{
+		"", "example.com", "ftp://example.com", "https://",
+		"http://localhost/hook", "http://127.0.0.1:8080", "http://10.0.0.1",
+		"http://169.254.169.254/latest/meta-data", "http://[::1]/", "http://[fd00::1]/",
+	} {
+		if _, err := NewWebhook(owner, endpoint, nil); err == nil {
+			t.Fatalf("NewWebhook(%q) expected error", endpoint)
+		}
+	}
+
+	if _, err := NewWebhook(owner, "https://example.com", []string{"nope"}); err == nil {
+		t.Fatal("NewWebhook() with unknown event expected error")
+	}
+}
+
+func Test_Wants(t *testing.T) {
+	all := Webhook{}
+	if !all.Wants(EventRecordChanges) || !all.Wants(EventExpiration) {
+		t.Fatal("expected webhook with no events to want all events")
+	}
+
+	some := Webhook{Events: []string{EventWhoisChange}}
+	if some.Wants(EventRecordChanges) || !some.Wants(EventWhoisChange) || !some.Wants(EventPing) {
+		t.Fatalf("unexpected Wants for %v", some.Events)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jawr/whois-bi/pkg/internal/dns"
+	"github.com/jawr/whois-bi/pkg/internal/domain"
 	"github.com/jawr/whois-bi/pkg/internal/job"
 	"github.com/jawr/whois-bi/pkg/internal/queue"
 	"github.com/pkg/errors"
@@ -21,6 +22,9 @@ type Worker struct {
 	consumer  queue.Consumer
 
 	dnsClient dns.Client
+
+	// publish progress as jobs are processed
+	progress bool
 }
 
 // NewWorker creates a worker using the provided dnsClient, publisher
@@ -33,6 +37,12 @@ func NewWorker(dnsClient dns.Client, publisher queue.Publisher, consumer queue.C
 	}
 }
 
+// EnableProgress makes the worker publish progress events on to the
+// progress queue as it processes jobs
+func (w *Worker) EnableProgress() {
+	w.progress = true
+}
+
 // Run starts the consumer and publishers stopping on any encountered
 // errors, or when the context is cancelled
 func (w *Worker) Run(ctx context.Context) error {
@@ -50,39 +60,81 @@ func (w *Worker) Run(ctx context.Context) error {
 }
 
 // handleJob decodes a Job and attempts to process it. Any errors
-// encountered are pushed on to the response's Errors field
-func (w *Worker) handleJob(ctx context.Context, body []byte) {
-	var job job.Job
-
-	if err := json.Unmarshal(body, &job); err != nil {
-		return
+// encountered are pushed on to the response's Errors field, or its
+// WhoisError field for the whois lookup, an error is only returned if the
+// job can not be decoded or the response published
+func (w *Worker) handleJob(ctx context.Context, body []byte) error {
+	var j job.Job
+
+	if err := json.Unmarshal(body, &j); err != nil {
+		return errors.Wrap(err, "Unmarshal")
 	}
 
-	job.StartedAt = time.Now()
+	report := w.reporter(ctx, j.ID)
+
+	j.StartedAt = time.Now()
+
+	report(job.ProgressStarted, j.Domain.Domain, 0)
+
+	var live domain.Records
+	var err error
+
+	if pc, ok := w.dnsClient.(dns.ProgressClient); ok && w.progress {
+		live, err = pc.GetLiveProgress(j.Domain, j.CurrentRecords, report)
+	} else {
+		live, err = w.dnsClient.GetLive(j.Domain, j.CurrentRecords)
+	}
 
-	live, err := w.dnsClient.GetLive(
-		job.Domain,
-		job.CurrentRecords,
-	)
 	if err != nil {
-		job.Errors = append(
-			job.Errors,
+		j.Errors = append(
+			j.Errors,
 			errors.Wrap(err, "GetLive").Error(),
 		)
 	} else {
 		// only proceed if we had no errors otherwise we will
 		// remove everything
-		additions, removals := delta(job.CurrentRecords, live)
+		additions, removals := delta(j.CurrentRecords, live)
 
-		job.RecordAdditions = additions
-		job.RecordRemovals = removals
+		j.RecordAdditions = additions
+		j.RecordRemovals = removals
+
+		report(job.ProgressRecords, "", len(live))
+	}
+
+	if j.CheckWhois {
+		whois, err := domain.NewWhois(j.Domain)
+		if err != nil {
+			j.WhoisError = errors.Wrap(err, "NewWhois").Error()
+		} else {
+			j.Whois = whois
+			report(job.ProgressWhois, "", 0)
+		}
 	}
 
-	job.FinishedAt = time.Now()
+	j.FinishedAt = time.Now()
 
-	err = w.publisher.Publish(ctx, "job.response", &job)
+	err = w.publisher.Publish(ctx, job.QueueResponse, &j)
 	if err != nil {
-		log.Printf("Error handling job %d, unable to publish: %s", job.ID, err)
-		return
+		return errors.WithMessagef(err, "unable to publish job %d", j.ID)
+	}
+
+	report(job.ProgressFinished, "", len(j.Errors))
+
+	return nil
+}
+
+// reporter returns a dns.Progress that publishes progress for the job if
+// progress is enabled. Failing to publish progress does not fail the job.
+func (w *Worker) reporter(ctx context.Context, jobID int) dns.Progress {
+	return func(stage, message string, count int) {
+		if !w.progress {
+			return
+		}
+
+		p := job.NewProgress(jobID, stage, message, count)
+
+		if err := w.publisher.Publish(ctx, job.QueueProgress, &p); err != nil {
+			log.Printf("Error publishing progress for job %d: %s", jobID, err)
+		}
 	}
 }
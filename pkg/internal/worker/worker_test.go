@@ -322,3 +322,76 @@ func Test_RunLiveError(t *testing.T) {
 		t.Fatalf("Wait() expected Canceled, got: %s", err)
 	}
 }
+
+func Test_RunProgress(t *testing.T) {
+	t.Parallel()
+
+	w := createNewWorker()
+	w.EnableProgress()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg errgroup.Group
+
+	wg.Go(func() error {
+		return w.Run(ctx)
+	})
+
+	j := createJob()
+
+	w.dnsClient.(*mockDnsClient).live = domain.Records{
+		domain.NewRecord(j.Domain, mustCreateRR(t, "whois.bi.	43200	IN	MX	10 ehlo.mx.ax."), domain.RecordSourceIterate),
+		domain.NewRecord(j.Domain, mustCreateRR(t, "www.whois.bi.	300	IN	CNAME	traefik.jl.lu."), domain.RecordSourceIterate),
+	}
+
+	if err := w.consumer.(*queue.MemoryConsumer).Publish(&j); err != nil {
+		t.Fatalf("Publish() expected nil got %s", err)
+	}
+
+	readProgress := func(stage string) job.Progress {
+		t.Helper()
+
+		body := <-w.publisher.(*queue.MemoryPublisher).Channel
+
+		var p job.Progress
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Fatalf("Unmarshal() unexpected error: %s", err)
+		}
+
+		if p.Stage != stage {
+			t.Fatalf("expected progress stage %q got %q", stage, p.Stage)
+		}
+
+		if p.JobID != j.ID {
+			t.Fatalf("expected progress job id %d got %d", j.ID, p.JobID)
+		}
+
+		return p
+	}
+
+	readProgress(job.ProgressStarted)
+
+	if p := readProgress(job.ProgressRecords); p.Count != 2 {
+		t.Fatalf("expected records progress count to be 2 got %d", p.Count)
+	}
+
+	responseBody := <-w.publisher.(*queue.MemoryPublisher).Channel
+
+	var response job.Job
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		t.Fatalf("Unmarshal() unexpected error: %s", err)
+	}
+
+	if len(response.RecordAdditions) != 2 {
+		t.Fatalf("Expected RecordAdditions to be 2, got %d", len(response.RecordAdditions))
+	}
+
+	readProgress(job.ProgressFinished)
+
+	// shutdown and check error
+	cancel()
+
+	if err := wg.Wait(); err != context.Canceled {
+		t.Fatalf("Wait() expected Canceled, got: %s", err)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/jawr/whois-bi/pkg/internal/api"
 	"github.com/jawr/whois-bi/pkg/internal/db"
 	"github.com/jawr/whois-bi/pkg/internal/emailer"
+	"github.com/jawr/whois-bi/pkg/internal/oidc"
+	"github.com/jawr/whois-bi/pkg/internal/queue/rabbit"
 	"github.com/pkg/errors"
 )
 
@@ -24,7 +29,11 @@ func run() error {
 	}
 	defer dbConn.Close()
 
-	sender := emailer.NewSMTPSenderFromEnv()
+	sender, err := emailer.NewSenderFromEnv()
+	if err != nil {
+		return errors.WithMessage(err, "NewSenderFromEnv")
+	}
+
 	emailer, err := emailer.NewEmailer(
 		os.Getenv("SMTP_FROM_NAME"),
 		os.Getenv("SMTP_EMAIL"),
@@ -34,7 +43,32 @@ func run() error {
 		return errors.WithMessage(err, "NewEmailer")
 	}
 
-	server := api.NewServer(dbConn, emailer)
+	addr := os.Getenv("RABBITMQ_URI")
+	if len(addr) == 0 {
+		return errors.New("No RABBITMQ_URI")
+	}
+
+	publisher := rabbit.NewPublisher(addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := publisher.Run(ctx); err != nil && err != context.Canceled {
+			fmt.Fprintf(os.Stderr, "publisher: %s\n", err)
+		}
+	}()
+
+	// SSO is optional
+	var provider *oidc.Provider
+	if config, ok := oidc.ConfigFromEnv(); ok {
+		provider, err = oidc.NewProvider(&http.Client{Timeout: time.Second * 30}, config)
+		if err != nil {
+			return errors.WithMessage(err, "NewProvider")
+		}
+	}
+
+	server := api.NewServer(dbConn, emailer, publisher, provider)
 
 	if err := server.Run(os.Getenv("HTTP_API_ADDR")); err != nil {
 		return errors.Wrap(err, "Run")
@@ -26,7 +26,8 @@ func run() error {
 	}
 
 	publisher := rabbit.NewPublisher(addr)
-	consumer := rabbit.NewConsumer("", "job.response", addr)
+	consumer := rabbit.NewConsumer("", job.QueueResponse, addr)
+	progress := rabbit.NewConsumer("", job.QueueProgress, addr)
 
 	dbConn, err := db.SetupDatabase()
 	if err != nil {
@@ -34,7 +35,11 @@ func run() error {
 	}
 	defer dbConn.Close()
 
-	sender := emailer.NewSMTPSenderFromEnv()
+	sender, err := emailer.NewSenderFromEnv()
+	if err != nil {
+		return errors.WithMessage(err, "NewSenderFromEnv")
+	}
+
 	emailer, err := emailer.NewEmailer(
 		os.Getenv("SMTP_FROM_NAME"),
 		os.Getenv("SMTP_EMAIL"),
@@ -44,7 +49,7 @@ func run() error {
 		return errors.WithMessage(err, "NewEmailer")
 	}
 
-	manager, err := job.NewManager(publisher, consumer, dbConn, emailer)
+	manager, err := job.NewManager(publisher, consumer, progress, dbConn, emailer)
 	if err != nil {
 		return errors.WithMessage(err, "NewManager")
 	}
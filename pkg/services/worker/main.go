@@ -8,6 +8,8 @@ import (
 	"syscall"
 
 	"github.com/jawr/whois-bi/pkg/internal/dns"
+	"github.com/jawr/whois-bi/pkg/internal/job"
+	"github.com/jawr/whois-bi/pkg/internal/queue"
 	"github.com/jawr/whois-bi/pkg/internal/queue/rabbit"
 	"github.com/jawr/whois-bi/pkg/internal/worker"
 	"github.com/pkg/errors"
@@ -29,9 +31,15 @@ func run() error {
 
 	dnsClient := dns.NewDNSClient()
 	publisher := rabbit.NewPublisher(addr)
-	consumer := rabbit.NewConsumer("", "job.queue", addr)
+
+	// drain user triggered jobs before scheduled ones
+	consumer := queue.NewPriorityConsumer(
+		rabbit.NewConsumer("", job.QueueHigh, addr),
+		rabbit.NewConsumer("", job.QueueNormal, addr),
+	)
 
 	wrk := worker.NewWorker(dnsClient, publisher, consumer)
+	wrk.EnableProgress()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()